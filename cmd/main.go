@@ -1,9 +1,12 @@
 package main
 
 import (
+	"flag"
+
 	"github.com/georgri/sledopyt_addresses/pkg/telegrambot"
 )
 
 func main() {
+	flag.Parse()
 	telegrambot.RunForever()
 }
@@ -0,0 +1,229 @@
+// Package chart renders simple line charts as PNG images without external dependencies
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	DefaultWidth  = 800
+	DefaultHeight = 480
+
+	marginLeft   = 72
+	marginRight  = 24
+	marginTop    = 36 // legend
+	marginBottom = 28
+
+	yTicks = 5
+	xTicks = 6
+)
+
+var (
+	background = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	axisColor  = color.RGBA{R: 96, G: 96, B: 96, A: 255}
+	gridColor  = color.RGBA{R: 224, G: 224, B: 224, A: 255}
+
+	// Palette series colors in order, repeated if there are more series
+	Palette = []color.RGBA{
+		{R: 31, G: 119, B: 180, A: 255},
+		{R: 255, G: 127, B: 14, A: 255},
+		{R: 44, G: 160, B: 44, A: 255},
+		{R: 214, G: 39, B: 40, A: 255},
+		{R: 148, G: 103, B: 189, A: 255},
+		{R: 140, G: 86, B: 75, A: 255},
+	}
+)
+
+type Point struct {
+	X time.Time
+	Y float64
+}
+
+type Series struct {
+	Label  string // shown in the legend, e.g. "2r"
+	Points []Point
+}
+
+// Chart line chart with time on the X axis
+type Chart struct {
+	Width  int
+	Height int
+	Series []Series
+
+	// YFormat axis label, e.g. 334300 => "334k"
+	YFormat func(y float64) string
+}
+
+// FormatThousands example: 334300 => "334k"
+func FormatThousands(y float64) string {
+	return fmt.Sprintf("%.0fk", y/1_000)
+}
+
+// FormatMillions example: 12345678 => "12.35M"
+func FormatMillions(y float64) string {
+	return fmt.Sprintf("%.2fM", y/1_000_000)
+}
+
+func (c Chart) Empty() bool {
+	for _, series := range c.Series {
+		if len(series.Points) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bounds of all points, never empty
+func (c Chart) bounds() (time.Time, time.Time, float64, float64) {
+	var minX, maxX time.Time
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, series := range c.Series {
+		for _, point := range series.Points {
+			if minX.IsZero() || point.X.Before(minX) {
+				minX = point.X
+			}
+			if maxX.IsZero() || point.X.After(maxX) {
+				maxX = point.X
+			}
+			minY, maxY = math.Min(minY, point.Y), math.Max(maxY, point.Y)
+		}
+	}
+
+	if !maxX.After(minX) {
+		minX, maxX = minX.Add(-12*time.Hour), maxX.Add(12*time.Hour)
+	}
+	padding := (maxY - minY) * 0.05
+	if padding == 0 {
+		padding = math.Max(math.Abs(maxY)*0.05, 1)
+	}
+	return minX, maxX, minY - padding, maxY + padding
+}
+
+// Render draws the chart, the series are drawn in the order given
+func (c Chart) Render() *image.RGBA {
+	width, height := c.Width, c.Height
+	if width == 0 || height == 0 {
+		width, height = DefaultWidth, DefaultHeight
+	}
+	yFormat := c.YFormat
+	if yFormat == nil {
+		yFormat = func(y float64) string { return fmt.Sprintf("%.0f", y) }
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	fillRect(img, 0, 0, width, height, background)
+	if c.Empty() {
+		return img
+	}
+
+	left, right := marginLeft, width-marginRight
+	top, bottom := marginTop, height-marginBottom
+	minX, maxX, minY, maxY := c.bounds()
+
+	toPixel := func(point Point) (int, int) {
+		x := float64(left) + float64(right-left)*float64(point.X.Sub(minX))/float64(maxX.Sub(minX))
+		y := float64(bottom) - float64(bottom-top)*(point.Y-minY)/(maxY-minY)
+		return int(math.Round(x)), int(math.Round(y))
+	}
+
+	// grid and axis labels
+	for i := 0; i <= yTicks; i++ {
+		y := bottom - (bottom-top)*i/yTicks
+		fillRect(img, left, y, right-left, 1, gridColor)
+		label := yFormat(minY + (maxY-minY)*float64(i)/yTicks)
+		drawText(img, left-6-textWidth(label), y-glyphHeight/2, label, axisColor)
+	}
+	for i := 0; i <= xTicks; i++ {
+		x := left + (right-left)*i/xTicks
+		fillRect(img, x, bottom, 1, 4, axisColor)
+		label := minX.Add(time.Duration(float64(maxX.Sub(minX)) * float64(i) / xTicks)).Format("01-02")
+		drawText(img, x-textWidth(label)/2, bottom+8, label, axisColor)
+	}
+	fillRect(img, left, top, 1, bottom-top+1, axisColor)
+	fillRect(img, left, bottom, right-left+1, 1, axisColor)
+
+	// legend and lines
+	legendX := left
+	for i, series := range c.Series {
+		seriesColor := Palette[i%len(Palette)]
+		if len(series.Label) > 0 {
+			fillRect(img, legendX, 12, glyphHeight, glyphHeight, seriesColor)
+			drawText(img, legendX+glyphHeight+6, 12, series.Label, axisColor)
+			legendX += glyphHeight + 6 + textWidth(series.Label) + 16
+		}
+
+		points := make([]Point, len(series.Points))
+		copy(points, series.Points)
+		sort.SliceStable(points, func(i, j int) bool { return points[i].X.Before(points[j].X) })
+		for j, point := range points {
+			x, y := toPixel(point)
+			fillRect(img, x-2, y-2, 5, 5, seriesColor)
+			if j > 0 {
+				prevX, prevY := toPixel(points[j-1])
+				drawLine(img, prevX, prevY, x, y, seriesColor)
+			}
+		}
+	}
+
+	return img
+}
+
+// PNG the rendered chart encoded as PNG
+func (c Chart) PNG() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := png.Encode(buf, c.Render())
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func fillRect(img *image.RGBA, x int, y int, width int, height int, c color.Color) {
+	for i := x; i < x+width; i++ {
+		for j := y; j < y+height; j++ {
+			img.Set(i, j, c)
+		}
+	}
+}
+
+// drawLine 2px wide Bresenham line
+func drawLine(img *image.RGBA, x0 int, y0 int, x1 int, y1 int, c color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		fillRect(img, x0, y0, 2, 2, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,75 @@
+package chart
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images in testdata")
+
+func TestChartGolden(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name  string
+		chart Chart
+	}{
+		{"empty", Chart{Width: 200, Height: 120}},
+		{"rooms", Chart{Width: 480, Height: 280, YFormat: FormatThousands, Series: []Series{
+			{Label: "1r", Points: []Point{{day(1), 330_000}, {day(8), 325_000}, {day(15), 331_000}, {day(22), 340_000}}},
+			{Label: "2r", Points: []Point{{day(1), 300_000}, {day(8), 310_000}, {day(15), 305_000}, {day(22), 298_000}}},
+			{Label: "3r", Points: []Point{{day(15), 280_000}, {day(22), 285_000}}},
+		}}},
+		{"flat", Chart{Width: 480, Height: 280, YFormat: FormatMillions, Series: []Series{
+			{Points: []Point{{day(3), 12_500_000}, {day(1), 12_000_000}, {day(20), 11_800_000}}},
+		}}},
+		{"single", Chart{Width: 300, Height: 200, YFormat: FormatMillions, Series: []Series{
+			{Points: []Point{{day(3), 12_500_000}}},
+		}}},
+	}
+
+	for i, test := range tests {
+		content, err := test.chart.PNG()
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+
+		golden := filepath.Join("testdata", test.name+".png")
+		if *update {
+			require.NoError(t, os.WriteFile(golden, content, 0644))
+		}
+		expected, err := os.ReadFile(golden)
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+
+		// the encoder output may change between Go versions, the pixels may not
+		expectedImage, err := png.Decode(bytes.NewReader(expected))
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+		actualImage, err := png.Decode(bytes.NewReader(content))
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+		require.True(t, samePixels(expectedImage, actualImage), fmt.Sprintf("failed case %v: %v differs, run with -update to accept", i, golden))
+	}
+}
+
+func samePixels(a, b image.Image) bool {
+	if a.Bounds() != b.Bounds() {
+		return false
+	}
+	for y := a.Bounds().Min.Y; y < a.Bounds().Max.Y; y++ {
+		for x := a.Bounds().Min.X; x < a.Bounds().Max.X; x++ {
+			if color.NRGBAModel.Convert(a.At(x, y)) != color.NRGBAModel.Convert(b.At(x, y)) {
+				return false
+			}
+		}
+	}
+	return true
+}
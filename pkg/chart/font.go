@@ -0,0 +1,59 @@
+package chart
+
+import (
+	"image"
+	"image/color"
+)
+
+// glyphs 3x5 bitmap font for the axis labels and the legend, unknown runes are drawn as spaces
+var glyphs = map[rune][5]string{
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"###", "..#", "###", "#..", "###"},
+	'3': {"###", "..#", "###", "..#", "###"},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "###", "..#", "###"},
+	'6': {"###", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", "..#", "..#", "..#"},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "###"},
+	'.': {"...", "...", "...", "...", ".#."},
+	'-': {"...", "...", "###", "...", "..."},
+	'+': {"...", ".#.", "###", ".#.", "..."},
+	'/': {"..#", "..#", ".#.", "#..", "#.."},
+	':': {"...", ".#.", "...", ".#.", "..."},
+	'%': {"#.#", "..#", ".#.", "#..", "#.#"},
+	'k': {"#..", "#.#", "##.", "#.#", "#.#"},
+	'm': {"...", "##.", "###", "#.#", "#.#"},
+	'r': {"...", "##.", "#..", "#..", "#.."},
+	'M': {"#.#", "###", "###", "#.#", "#.#"},
+}
+
+const (
+	glyphScale   = 2
+	glyphWidth   = 3 * glyphScale
+	glyphHeight  = 5 * glyphScale
+	glyphAdvance = glyphWidth + glyphScale
+)
+
+// textWidth in pixels
+func textWidth(text string) int {
+	return len([]rune(text)) * glyphAdvance
+}
+
+// drawText top left corner of the text at (x, y)
+func drawText(img *image.RGBA, x int, y int, text string, c color.Color) {
+	for _, r := range text {
+		glyph, ok := glyphs[r]
+		if ok {
+			for row, line := range glyph {
+				for col, pixel := range line {
+					if pixel == '#' {
+						fillRect(img, x+col*glyphScale, y+row*glyphScale, glyphScale, glyphScale, c)
+					}
+				}
+			}
+		}
+		x += glyphAdvance
+	}
+}
@@ -1,10 +1,12 @@
 package downloader
 
 import (
+	"errors"
 	"fmt"
 	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
 	"io"
 	"net/http"
+	"time"
 )
 
 const (
@@ -13,6 +15,8 @@ const (
 
 	flatPageFlag = "flatPage"
 
+	FlatDetailsUrl = "https://flat.pik-service.ru/api/v1/flat"
+
 	// TODO: download this url to monitor new projects
 	BlocksUrl = "https://flat.pik-service.ru/api/v1/filter/block?type=1,2&location=2,3&flatLimit=50&blockLimit=1000&geoBox=55.33638001424489,56.14056105282492-36.96336293218961,38.11418080328337"
 )
@@ -46,12 +50,55 @@ func GetFlatsSinglePage(url string) (*flatstorage.MessageData, error) {
 	return msgData, nil
 }
 
-func GetFlats(chatID int64, blockID int64) (message string, filtered int, updateCallback func() error, err error) {
+// GetPlan the floor plan (svg) by Flat.PlanURL
+func GetPlan(planURL string) ([]byte, error) {
+	if len(planURL) == 0 {
+		return nil, fmt.Errorf("empty plan url")
+	}
+	body, err := GetUrl(planURL)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting plan %v: %v", planURL, err)
+	}
+	return body, nil
+}
+
+// GetFlatDetails single flat details, cached in the local file
+func GetFlatDetails(id int64) (*flatstorage.FlatDetails, error) {
+	cached, ok := flatstorage.GetCachedFlatDetails(id)
+	if ok && cached.Fresh(time.Now()) {
+		return cached, nil
+	}
+
+	url := fmt.Sprintf("%v/%v", FlatDetailsUrl, id)
+	body, err := GetUrl(url)
+	if err == nil {
+		var details *flatstorage.FlatDetails
+		details, err = flatstorage.UnmarshallFlatDetails(body)
+		if err == nil {
+			err = flatstorage.CacheFlatDetails(details)
+			if err != nil {
+				return nil, fmt.Errorf("failed to cache details of flat %v: %v", id, err)
+			}
+			return details, nil
+		}
+	}
+
+	if ok {
+		// stale details are better than nothing
+		return cached, nil
+	}
+	return nil, fmt.Errorf("error while getting flat details %v: %v", url, err)
+}
+
+// ErrNoFlats the block has no flats on sale, e.g. it is sold out
+var ErrNoFlats = errors.New("got 0 Flats from url")
+
+func GetFlats(chatID int64, blockID int64) (update *flatstorage.BlockUpdate, filtered int, updateCallback func() error, err error) {
 	url := fmt.Sprintf("%v/%v?%v", PikUrl, blockID, UrlParams)
 
 	msgData, err := GetFlatsSinglePage(url)
 	if err != nil {
-		return "", 0, nil, err
+		return nil, 0, nil, err
 	}
 
 	if msgData.LastPage > 1 {
@@ -59,32 +106,40 @@ func GetFlats(chatID int64, blockID int64) (message string, filtered int, update
 			addUrl := fmt.Sprintf("%v&%v=%v", url, flatPageFlag, i)
 			addMsgData, err := GetFlatsSinglePage(addUrl)
 			if err != nil {
-				return "", 0, nil, err
+				return nil, 0, nil, err
 			}
 			msgData.Flats = append(msgData.Flats, addMsgData.Flats...)
 		}
 	}
 
 	if len(msgData.Flats) == 0 {
-		return "", 0, nil, fmt.Errorf("got 0 Flats from url")
+		return nil, 0, nil, ErrNoFlats
 	}
 
 	origMsgData := msgData.Copy()
 
+	// detect block-wide repricing before the local file gets updated
+	repricing, err := flatstorage.DetectRepricingWithFlatStorage(origMsgData, chatID)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("err while comparing prices with local Flats file: %v", err)
+	}
+
 	// filter through local file (MVP)
 	sizeBefore := len(msgData.Flats)
 	msgData, err = flatstorage.FilterWithFlatStorage(msgData, chatID)
 	if err != nil {
-		return "", 0, nil, fmt.Errorf("err while reading/updating local Flats file: %v", err)
+		return nil, 0, nil, fmt.Errorf("err while reading/updating local Flats file: %v", err)
 	}
 
-	// convert Flats to human-readable message
-	msg := msgData.String()
+	update = &flatstorage.BlockUpdate{
+		NewFlats:  msgData,
+		Repricing: repricing,
+	}
 
 	updateCallback = func() error {
 		_, err = flatstorage.UpdateFlatStorage(origMsgData, chatID)
 		return err
 	}
 
-	return msg, sizeBefore - len(msgData.Flats), updateCallback, nil
+	return update, sizeBefore - len(msgData.Flats), updateCallback, nil
 }
@@ -0,0 +1,67 @@
+package flatstorage
+
+import (
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"strings"
+)
+
+// BlockUpdate everything found in a block between two polls
+type BlockUpdate struct {
+	NewFlats  *MessageData
+	Repricing *RepricingSummary
+}
+
+// Empty true if there is nothing to notify about
+func (u *BlockUpdate) Empty() bool {
+	if u == nil {
+		return true
+	}
+	return (u.NewFlats == nil || len(u.NewFlats.Flats) == 0) && !u.Repricing.IsWave()
+}
+
+// ForBulks the same update with new flats and repricing scoped to the given bulks; empty bulks means the whole block
+func (u *BlockUpdate) ForBulks(bulks []string) *BlockUpdate {
+	if u == nil {
+		return nil
+	}
+	return &BlockUpdate{
+		NewFlats:  u.NewFlats.FilterBulks(bulks),
+		Repricing: u.Repricing.ForBulks(bulks),
+	}
+}
+
+// Reject returns a copy without the hidden new flats, the repricing summary is kept as is
+func (u *BlockUpdate) Reject(hidden func(flat *Flat) bool) *BlockUpdate {
+	if u == nil {
+		return nil
+	}
+	return &BlockUpdate{
+		NewFlats:  u.NewFlats.Reject(hidden),
+		Repricing: u.Repricing,
+	}
+}
+
+// String repricing summary (if any) followed by the new flats
+func (u *BlockUpdate) String() string {
+	return u.Format(i18n.En)
+}
+
+func (u *BlockUpdate) Format(lang i18n.Lang) string {
+	return u.FormatDisplay(lang, Display{})
+}
+
+// FormatDisplay Format with the new flats sorted and formatted as the display says
+func (u *BlockUpdate) FormatDisplay(lang i18n.Lang, d Display) string {
+	if u == nil {
+		return ""
+	}
+	var res []string
+	if u.Repricing.IsWave() {
+		// one aggregated summary instead of a message per flat
+		res = append(res, u.Repricing.Format(lang))
+	}
+	if u.NewFlats != nil && len(u.NewFlats.Flats) > 0 {
+		res = append(res, u.NewFlats.FormatDisplay(lang, d, nil))
+	}
+	return strings.Join(res, "\n\n")
+}
@@ -0,0 +1,54 @@
+package flatstorage
+
+import (
+	"strings"
+)
+
+// NormalizeBulk reduce any user or API input to the short bulk name, e.g. "Корпус 1.1", "к1.1" => "1.1"
+func NormalizeBulk(bulk string) string {
+	fields := strings.Fields(bulk)
+	if len(fields) == 0 {
+		return ""
+	}
+	bulk = fields[len(fields)-1]
+	return strings.TrimLeft(strings.ToLower(bulk), "кk")
+}
+
+func bulkScope(bulks []string) map[string]struct{} {
+	res := make(map[string]struct{}, len(bulks))
+	for _, bulk := range bulks {
+		res[NormalizeBulk(bulk)] = struct{}{}
+	}
+	return res
+}
+
+// FilterBulks returns a copy with the flats from the given bulks only; empty bulks means the whole block
+func (md *MessageData) FilterBulks(bulks []string) *MessageData {
+	res := md.Copy()
+	if res == nil || len(bulks) == 0 {
+		return res
+	}
+
+	scope := bulkScope(bulks)
+	filtered := make([]Flat, 0, len(res.Flats))
+	for _, flat := range res.Flats {
+		if _, ok := scope[flat.BulkShortName()]; ok {
+			filtered = append(filtered, flat)
+		}
+	}
+	res.Flats = filtered
+
+	return res
+}
+
+// BulkCounts number of flats per bulk short name
+func (md *MessageData) BulkCounts() map[string]int {
+	res := make(map[string]int)
+	if md == nil {
+		return res
+	}
+	for i := range md.Flats {
+		res[md.Flats[i].BulkShortName()] += 1
+	}
+	return res
+}
@@ -0,0 +1,65 @@
+package flatstorage
+
+import (
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"math"
+)
+
+const (
+	// DealThreshold minimal discount of the price per m2 vs the comparable flats to call the flat a deal
+	DealThreshold = 0.05
+
+	MinComparables = 3
+	AreaTolerance  = 0.15 // relative, 54m2 is comparable to 46-62m2
+	FloorBand      = 5    // floors up and down
+)
+
+// Comparable same rooms, similar area and floor
+func (f *Flat) Comparable(other *Flat) bool {
+	if f.ID == other.ID || f.Rooms != other.Rooms || f.Area <= 0 {
+		return false
+	}
+	if math.Abs(other.Area-f.Area)/f.Area > AreaTolerance {
+		return false
+	}
+	floorDiff := f.Floor - other.Floor
+	return floorDiff <= FloorBand && floorDiff >= -FloorBand
+}
+
+// IsDeal true if the flat is significantly cheaper than the similar ones
+func (f *Flat) IsDeal() bool {
+	return f.Discount >= DealThreshold
+}
+
+// ScoreDeals set the discount of each flat vs the median price per m2 of the comparable flats of the block,
+// flats with too few comparables get zero discount
+func (md *MessageData) ScoreDeals(block *MessageData) {
+	if md == nil || block == nil {
+		return
+	}
+	for i := range md.Flats {
+		flat := &md.Flats[i]
+		flat.Discount = 0
+		if flat.MeterPrice <= 0 {
+			continue
+		}
+
+		var meterPrices []int64
+		for j := range block.Flats {
+			if flat.Comparable(&block.Flats[j]) && block.Flats[j].MeterPrice > 0 {
+				meterPrices = append(meterPrices, block.Flats[j].MeterPrice)
+			}
+		}
+		if len(meterPrices) < MinComparables {
+			continue
+		}
+		flat.Discount = 1 - float64(flat.MeterPrice)/util.Median(meterPrices)
+	}
+}
+
+// Deals returns a copy with the deals only
+func (md *MessageData) Deals() *MessageData {
+	return md.Reject(func(flat *Flat) bool {
+		return !flat.IsDeal()
+	})
+}
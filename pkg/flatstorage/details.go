@@ -0,0 +1,169 @@
+package flatstorage
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// FlatDetailsValidInterval details rarely change, prices come from the block endpoint anyway
+	FlatDetailsValidInterval = 24 * time.Hour
+
+	flatDetailsFileFormat = "%v/flat_details_%v.%v"
+)
+
+// FlatDetails from https://flat.pik-service.ru/api/v1/flat/{id}, only the fields shown in the flat card
+type FlatDetails struct {
+	ID            int64    `json:"id"`
+	Address       string   `json:"address,omitempty"`       // Москва, Нагатинская наб., 10к1
+	CeilingHeight float64  `json:"ceilingHeight,omitempty"` // 2.85
+	FinishType    int      `json:"finishType,omitempty"`    // 1, see the finish.N messages
+	WindowView    []string `json:"windowView,omitempty"`    // во двор, на реку
+	Benefits      []string `json:"benefits,omitempty"`      // Семейная ипотека 6%
+	Fetched       string   `json:"fetched"`                 // RFC3339
+}
+
+var flatDetailsMutex sync.Mutex
+
+// FlatDetailsResponse expected source shape, see testdata/flat_details.json (written by hand, not yet checked against a captured response);
+// finishType is a numeric code, the same as in the block flats
+type FlatDetailsResponse struct {
+	Data struct {
+		ID            int64    `json:"id"`
+		Address       string   `json:"address"`
+		CeilingHeight float64  `json:"ceilingHeight"`
+		WindowView    []string `json:"windowView"`
+		FinishType    int      `json:"finishType"`
+		Benefits      []struct {
+			Name string `json:"name"`
+		} `json:"benefits"`
+	} `json:"data"`
+}
+
+func UnmarshallFlatDetails(body []byte) (*FlatDetails, error) {
+	response := &FlatDetailsResponse{}
+	err := json.Unmarshal(body, response)
+	if err != nil {
+		return nil, err
+	}
+	data := response.Data
+	if data.ID == 0 {
+		return nil, fmt.Errorf("no flat id in the response: %.200s", string(body))
+	}
+
+	res := &FlatDetails{
+		ID:            data.ID,
+		Address:       strings.TrimSpace(data.Address),
+		CeilingHeight: data.CeilingHeight,
+		WindowView:    data.WindowView,
+		FinishType:    data.FinishType,
+		Fetched:       time.Now().Format(time.RFC3339),
+	}
+	for _, benefit := range data.Benefits {
+		if name := strings.TrimSpace(benefit.Name); len(name) > 0 {
+			res.Benefits = append(res.Benefits, name)
+		}
+	}
+
+	return res, nil
+}
+
+func (d *FlatDetails) Fresh(now time.Time) bool {
+	t, err := time.Parse(time.RFC3339, d.Fetched)
+	if err != nil {
+		return false
+	}
+	return now.Sub(t) < FlatDetailsValidInterval
+}
+
+func GetFlatDetailsFileName() string {
+	return fmt.Sprintf(flatDetailsFileFormat, storageDir, util.GetEnvType().String(), storageFormat)
+}
+
+func readFlatDetailsCache() (map[int64]FlatDetails, error) {
+	res := make(map[int64]FlatDetails)
+	fileName := GetFlatDetailsFileName()
+	if !FileExists(fileName) {
+		return res, nil
+	}
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(content, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetCachedFlatDetails details fetched before, possibly stale
+func GetCachedFlatDetails(id int64) (*FlatDetails, bool) {
+	flatDetailsMutex.Lock()
+	defer flatDetailsMutex.Unlock()
+
+	cache, err := readFlatDetailsCache()
+	if err != nil {
+		return nil, false
+	}
+	details, ok := cache[id]
+	return &details, ok
+}
+
+// CacheFlatDetails put the fetched details into the local file
+func CacheFlatDetails(details *FlatDetails) error {
+	flatDetailsMutex.Lock()
+	defer flatDetailsMutex.Unlock()
+
+	cache, err := readFlatDetailsCache()
+	if err != nil {
+		return err
+	}
+	cache[details.ID] = *details
+
+	content, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(GetFlatDetailsFileName(), content, 0644)
+}
+
+// LatestUpdate the time of the last poll that found any flats, RFC3339
+func (md *MessageData) LatestUpdate() string {
+	var res string
+	for i := range md.Flats {
+		// RFC3339 strings in the same timezone compare as times
+		if md.Flats[i].Updated > res {
+			res = md.Flats[i].Updated
+		}
+	}
+	return res
+}
+
+// OnSale true if the flat was found by the last poll of the block
+func (md *MessageData) OnSale(flat *Flat) bool {
+	return flat != nil && flat.FoundBy(md.LatestUpdate())
+}
+
+// FoundBy true if the flat was found by the poll at latest, see LatestUpdate
+func (f *Flat) FoundBy(latest string) bool {
+	return len(f.Updated) > 0 && f.Updated == latest
+}
+
+// FindFlat the flat with the ID
+func (md *MessageData) FindFlat(id int64) (*Flat, bool) {
+	if md == nil {
+		return nil, false
+	}
+	for i := range md.Flats {
+		if md.Flats[i].ID == id {
+			return &md.Flats[i], true
+		}
+	}
+	return nil, false
+}
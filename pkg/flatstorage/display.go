@@ -0,0 +1,256 @@
+package flatstorage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// DisplayColumns all columns of a flat line, the order of the line is set by the "flat.template" of the language
+var DisplayColumns = []string{"bulk", "rooms", "area", "price", "meterprice", "floor", "created"}
+
+var DefaultColumns = []string{"bulk", "rooms", "area", "price", "floor"}
+
+// Display how the flat lists are sorted and formatted, zero value means defaults
+type Display struct {
+	Sort     string   `json:"sort,omitempty"`     // see ParseFlatSort, DefaultSortKey if empty
+	Columns  []string `json:"columns,omitempty"`  // DefaultColumns if empty
+	Millions bool     `json:"millions,omitempty"` // 12.76m instead of 12 756 380
+}
+
+// FlatSort the parsed Sort, the default one if Sort is invalid
+func (d Display) FlatSort() FlatSort {
+	res, err := ParseFlatSort(d.Sort)
+	if err != nil {
+		return FlatSort{Field: DefaultSortKey}
+	}
+	return res
+}
+
+func (d Display) Has(column string) bool {
+	columns := d.Columns
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+	for _, c := range columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// String example: "sort=-area columns=rooms,area,price millions=on", the same format as ParseDisplay
+func (d Display) String() string {
+	var columns []string
+	for _, column := range DisplayColumns {
+		if d.Has(column) {
+			columns = append(columns, column)
+		}
+	}
+	millions := "off"
+	if d.Millions {
+		millions = "on"
+	}
+	return fmt.Sprintf("sort=%v columns=%v millions=%v", d.FlatSort(), strings.Join(columns, ","), millions)
+}
+
+// ParseColumns example: "rooms,area,m2price" => rooms, area, meterprice in the DisplayColumns order
+func ParseColumns(s string) ([]string, error) {
+	selected := make(map[string]bool)
+	for _, column := range strings.Split(strings.ToLower(s), ",") {
+		column = strings.TrimSpace(column)
+		if alias, ok := filterFieldAliases[column]; ok {
+			column = alias
+		}
+		if !(Display{Columns: DisplayColumns}).Has(column) {
+			return nil, i18n.NewError("display.unknown.column", column)
+		}
+		selected[column] = true
+	}
+
+	var res []string
+	for _, column := range DisplayColumns {
+		if selected[column] {
+			res = append(res, column)
+		}
+	}
+	return res, nil
+}
+
+// ParseDisplay changes d, example: "sort=-area columns=rooms,area,price millions=on"
+func ParseDisplay(d Display, s string) (Display, error) {
+	for _, word := range strings.Fields(s) {
+		key, value, _ := strings.Cut(strings.ToLower(word), "=")
+		switch key {
+		case "sort":
+			flatSort, err := ParseFlatSort(value)
+			if err != nil {
+				return d, err
+			}
+			d.Sort = flatSort.String()
+		case "columns", "cols":
+			columns, err := ParseColumns(value)
+			if err != nil {
+				return d, err
+			}
+			d.Columns = columns
+		case "millions":
+			switch value {
+			case "", "on":
+				d.Millions = true
+			case "off":
+				d.Millions = false
+			default:
+				return d, i18n.NewError("display.invalid.millions", value)
+			}
+		default:
+			return d, i18n.NewError("display.unknown.option", word)
+		}
+	}
+	return d, nil
+}
+
+// FlatView the values of a flat line for the "flat.template"
+type FlatView struct {
+	ID         int64
+	URL        string
+	Bulk       string
+	Rooms      int8
+	Area       string
+	Price      string // with the currency
+	MeterPrice string
+	Floor      int64
+	MaxFloor   int8
+	Created    string // 02.01.2006
+	Marks      string // 🔒 reserved, ♻️ relisted
+
+	display Display
+}
+
+// Has the column is shown, e.g. {{if .Has "price"}}
+func (v FlatView) Has(column string) bool {
+	return v.display.Has(column)
+}
+
+// FormatPrice example: 12_756_380 => "12 756 380R" or "12.76mR" in millions
+func FormatPrice(lang i18n.Lang, price int64, inMillions bool) string {
+	if inMillions {
+		return i18n.T(lang, "price.millions", fmt.Sprintf("%.2f", float64(price)/1_000_000))
+	}
+	return i18n.T(lang, "price.full", util.ThousandSep(price, " "))
+}
+
+func (f *Flat) View(lang i18n.Lang, d Display) FlatView {
+	res := FlatView{
+		ID:         f.ID,
+		URL:        fmt.Sprintf("https://www.pik.ru/flat/%v", f.ID),
+		Bulk:       f.BulkShortName(),
+		Rooms:      f.Rooms,
+		Area:       fmt.Sprintf("%.1f", f.Area),
+		Price:      FormatPrice(lang, f.Price, d.Millions),
+		MeterPrice: i18n.T(lang, "price.meter", util.ThousandSep(f.MeterPrice, " ")),
+		Floor:      f.Floor,
+		MaxFloor:   f.MaxFloor,
+		display:    d,
+	}
+	if t, err := time.Parse(time.RFC3339, f.Created); err == nil {
+		res.Created = t.Format("02.01.2006")
+	}
+	if f.Status == "reserve" {
+		res.Marks = "🔒"
+	}
+	if f.RelistedFrom != 0 {
+		res.Marks += "♻️"
+	}
+	return res
+}
+
+// FlatTemplatesFile language => text/template of a flat line (see FlatView), e.g. {"en": "{{.Bulk}}: {{.Rooms}}r, {{.Price}}"},
+// the "flat.template" of the catalog is used for the languages missing there
+const FlatTemplatesFile = "data/flat_templates.json"
+
+var (
+	flatTemplates      = make(map[i18n.Lang]*template.Template)
+	flatTemplateTexts  map[string]string // FlatTemplatesFile, read on the first use
+	flatTemplatesMutex sync.Mutex
+)
+
+func readFlatTemplates() map[string]string {
+	res := make(map[string]string)
+	if !FileExists(FlatTemplatesFile) {
+		return res
+	}
+	content, err := os.ReadFile(FlatTemplatesFile)
+	if err != nil {
+		log.Printf("unable to read flat templates file: %v", err)
+		return res
+	}
+	err = json.Unmarshal(content, &res)
+	if err != nil {
+		log.Printf("unable to unmarshal flat templates file: %v", err)
+	}
+	return res
+}
+
+// flatTemplate the parsed template of the language, parsed once; the configured one falls back to the built-in one if invalid
+func flatTemplate(lang i18n.Lang) (*template.Template, error) {
+	flatTemplatesMutex.Lock()
+	defer flatTemplatesMutex.Unlock()
+
+	if tmpl, ok := flatTemplates[lang]; ok {
+		return tmpl, nil
+	}
+	if flatTemplateTexts == nil {
+		flatTemplateTexts = readFlatTemplates()
+	}
+
+	tmpl, err := template.New(string(lang)).Parse(i18n.T(lang, "flat.template"))
+	if text, ok := flatTemplateTexts[string(lang)]; ok {
+		configured, configuredErr := template.New(string(lang)).Parse(text)
+		if configuredErr == nil {
+			tmpl, err = configured, nil
+		} else {
+			log.Printf("invalid %v flat template in %v, using the built-in one: %v", lang, FlatTemplatesFile, configuredErr)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	flatTemplates[lang] = tmpl
+	return tmpl, nil
+}
+
+// FormatDisplay a flat line with the columns of the display
+func (f *Flat) FormatDisplay(lang i18n.Lang, d Display) string {
+	if f == nil {
+		return ""
+	}
+
+	var res string
+	buf := &bytes.Buffer{}
+	tmpl, err := flatTemplate(lang)
+	if err == nil {
+		err = tmpl.Execute(buf, f.View(lang, d))
+	}
+	if err != nil {
+		log.Printf("failed to format flat %v with the %v template: %v", f.ID, lang, err)
+		res = fmt.Sprintf("<a href=\"https://www.pik.ru/flat/%v\">#%v</a>", f.ID, f.ID)
+	} else {
+		res = buf.String()
+	}
+
+	if f.IsDeal() {
+		res += " " + i18n.T(lang, "flat.deal", math.Round(f.Discount*100))
+	}
+	return res
+}
@@ -0,0 +1,177 @@
+package flatstorage
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// utf8BOM lets Excel detect the encoding of Cyrillic CSV files
+const utf8BOM = "\ufeff"
+
+// ExportColumn value is one of string, int64 or float64
+type ExportColumn struct {
+	Name  string
+	Value func(f *Flat) any
+}
+
+var ExportColumns = []ExportColumn{
+	{"id", func(f *Flat) any { return f.ID }},
+	{"block", func(f *Flat) any { return f.BlockName }},
+	{"block_slug", func(f *Flat) any { return f.BlockSlug }},
+	{"bulk", func(f *Flat) any { return f.BulkName }},
+	{"rooms", func(f *Flat) any { return int64(f.Rooms) }},
+	{"area", func(f *Flat) any { return f.Area }},
+	{"floor", func(f *Flat) any { return f.Floor }},
+	{"max_floor", func(f *Flat) any { return int64(f.MaxFloor) }},
+	{"price", func(f *Flat) any { return f.Price }},
+	{"meter_price", func(f *Flat) any { return f.MeterPrice }},
+	{"status", func(f *Flat) any { return f.Status }},
+	{"metro", func(f *Flat) any { return f.Metro.Name }},
+	{"created", func(f *Flat) any { return f.Created }},
+	{"updated", func(f *Flat) any { return f.Updated }},
+	{"plan_url", func(f *Flat) any { return f.PlanURL }},
+	{"price_history", func(f *Flat) any { return f.PriceHistoryString() }},
+}
+
+// PriceHistoryString example: "2024-05-01T10:00:00+03:00 12000000; 2024-05-20T10:00:00+03:00 11500000"
+func (f *Flat) PriceHistoryString() string {
+	var res []string
+	for _, point := range f.PriceHistory {
+		res = append(res, fmt.Sprintf("%v %v", point.Date, point.Price))
+	}
+	return strings.Join(res, "; ")
+}
+
+// ExportTable header and rows of all flats
+func (md *MessageData) ExportTable() ([]string, [][]any) {
+	header := make([]string, 0, len(ExportColumns))
+	for _, column := range ExportColumns {
+		header = append(header, column.Name)
+	}
+	if md == nil {
+		return header, nil
+	}
+
+	rows := make([][]any, 0, len(md.Flats))
+	for i := range md.Flats {
+		row := make([]any, 0, len(ExportColumns))
+		for _, column := range ExportColumns {
+			row = append(row, column.Value(&md.Flats[i]))
+		}
+		rows = append(rows, row)
+	}
+	return header, rows
+}
+
+// CSV all flats with the header row
+func (md *MessageData) CSV() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(utf8BOM)
+
+	writer := csv.NewWriter(buf)
+	header, rows := md.ExportTable()
+	err := writer.Write(header)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		record := make([]string, 0, len(row))
+		for _, value := range row {
+			record = append(record, fmt.Sprintf("%v", value))
+		}
+		err = writer.Write(record)
+		if err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	if err = writer.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var xlsxStaticFiles = []struct {
+	Name    string
+	Content string
+}{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="flats" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// XLSX all flats with the header row as a minimal single sheet workbook
+func (md *MessageData) XLSX() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	archive := zip.NewWriter(buf)
+
+	for _, file := range xlsxStaticFiles {
+		w, err := archive.Create(file.Name)
+		if err != nil {
+			return nil, err
+		}
+		_, err = w.Write([]byte(file.Content))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	w, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(md.xlsxSheet())
+	if err != nil {
+		return nil, err
+	}
+
+	err = archive.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (md *MessageData) xlsxSheet() []byte {
+	header, rows := md.ExportTable()
+
+	sheet := &bytes.Buffer{}
+	sheet.WriteString(xml.Header)
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	headerRow := make([]any, 0, len(header))
+	for _, name := range header {
+		headerRow = append(headerRow, name)
+	}
+	for _, row := range append([][]any{headerRow}, rows...) {
+		sheet.WriteString("<row>")
+		for _, value := range row {
+			switch value.(type) {
+			case int64, float64:
+				fmt.Fprintf(sheet, "<c><v>%v</v></c>", value)
+			default:
+				sheet.WriteString(`<c t="inlineStr"><is><t>`)
+				_ = xml.EscapeText(sheet, []byte(fmt.Sprintf("%v", value)))
+				sheet.WriteString("</t></is></c>")
+			}
+		}
+		sheet.WriteString("</row>")
+	}
+	sheet.WriteString("</sheetData></worksheet>")
+	return sheet.Bytes()
+}
@@ -56,6 +56,9 @@ func FilterWithFlatStorage(msg *MessageData, chatID int64) (*MessageData, error)
 }
 
 func FilterWithFlatStorageHelper(oldMsg, newMsg *MessageData) *MessageData {
+	// relisted flats have new IDs but must be marked as such
+	LinkRelistedFlats(oldMsg, newMsg)
+
 	// gen old map
 	oldFlatsMap := make(map[int64]Flat)
 	for _, flat := range oldMsg.Flats {
@@ -80,6 +83,8 @@ func MergeNewFlatsIntoOld(oldMsg, newMsg *MessageData) *MessageData {
 		return newMsg.Flats[i].ID
 	})
 
+	LinkRelistedFlats(oldMsg, newMsg)
+
 	// gen new map
 	newFlatsMap := make(map[int64]struct{})
 	for i := range newMsg.Flats {
@@ -90,7 +95,7 @@ func MergeNewFlatsIntoOld(oldMsg, newMsg *MessageData) *MessageData {
 	past := time.Now().Add(-10 * 365 * 24 * time.Hour).Format(time.RFC3339)
 
 	// old map with created dates
-	oldFlatsMap := make(map[int64]string)
+	oldFlatsMap := make(map[int64]Flat)
 	for i := range oldMsg.Flats {
 		if len(oldMsg.Flats[i].Created) == 0 {
 			oldMsg.Flats[i].Created = past
@@ -98,7 +103,7 @@ func MergeNewFlatsIntoOld(oldMsg, newMsg *MessageData) *MessageData {
 		if len(oldMsg.Flats[i].Updated) == 0 {
 			oldMsg.Flats[i].Updated = oldMsg.Flats[i].Created
 		}
-		oldFlatsMap[oldMsg.Flats[i].ID] = oldMsg.Flats[i].Created
+		oldFlatsMap[oldMsg.Flats[i].ID] = oldMsg.Flats[i]
 	}
 
 	// filter out existing old Flats by ID
@@ -107,11 +112,29 @@ func MergeNewFlatsIntoOld(oldMsg, newMsg *MessageData) *MessageData {
 		return !ok
 	})
 
-	// update both "Created" and "Updated" for new flats
+	// update both "Created" and "Updated" for new flats, keep track of price changes
 	for i := range newMsg.Flats {
 		newMsg.Flats[i].Created = now
-		if created, ok := oldFlatsMap[newMsg.Flats[i].ID]; ok {
-			newMsg.Flats[i].Created = created
+		oldFlat, ok := oldFlatsMap[newMsg.Flats[i].ID]
+		if ok && newMsg.Flats[i].RelistedFrom == 0 {
+			newMsg.Flats[i].RelistedFrom = oldFlat.RelistedFrom
+		}
+		if !ok && newMsg.Flats[i].RelistedFrom != 0 {
+			// link the relisted flat to the history of the old one
+			oldFlat, ok = oldFlatsMap[newMsg.Flats[i].RelistedFrom]
+		}
+		if ok {
+			newMsg.Flats[i].Created = oldFlat.Created
+			newMsg.Flats[i].PriceHistory = oldFlat.PriceHistory
+			if oldFlat.Price != newMsg.Flats[i].Price && oldFlat.Price != 0 {
+				if len(newMsg.Flats[i].PriceHistory) == 0 {
+					newMsg.Flats[i].PriceHistory = []PricePoint{{Date: oldFlat.Created, Price: oldFlat.Price}}
+				}
+				newMsg.Flats[i].PriceHistory = append(newMsg.Flats[i].PriceHistory, PricePoint{
+					Date:  now,
+					Price: newMsg.Flats[i].Price,
+				})
+			}
 		}
 		newMsg.Flats[i].Updated = now
 	}
@@ -122,6 +145,21 @@ func MergeNewFlatsIntoOld(oldMsg, newMsg *MessageData) *MessageData {
 	return oldMsg
 }
 
+// DetectRepricingWithFlatStorage compare freshly downloaded flats with the local file
+func DetectRepricingWithFlatStorage(msg *MessageData, chatID int64) (*RepricingSummary, error) {
+	if msg == nil || len(msg.Flats) == 0 {
+		return nil, nil
+	}
+
+	storageFileName := GetStorageFileName(msg, chatID)
+	oldMessageData, err := ReadFlatStorage(storageFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return DetectRepricing(oldMessageData, msg), nil
+}
+
 // UpdateFlatStorage update local file (MVP)
 func UpdateFlatStorage(msg *MessageData, chatID int64) (numUpdated int, err error) {
 	if msg == nil || len(msg.Flats) == 0 {
@@ -0,0 +1,210 @@
+package flatstorage
+
+import (
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FlatFilter conditions joined with AND, e.g. "rooms=2 price<15m area>=40 bulk=1.1"
+type FlatFilter struct {
+	Conditions []FilterCondition
+}
+
+type FilterCondition struct {
+	Field string // rooms, price, meterprice, area, floor, bulk, metro, status, block
+	Op    string // =, !=, <, <=, >, >=
+	Value string
+}
+
+var filterConditionRegexp = regexp.MustCompile(`^([a-zA-Z0-9]+)(!=|<=|>=|=|<|>)(.+)$`)
+
+var numericFilterFields = map[string]func(f *Flat) float64{
+	"rooms":      func(f *Flat) float64 { return float64(f.Rooms) },
+	"price":      func(f *Flat) float64 { return float64(f.Price) },
+	"meterprice": func(f *Flat) float64 { return float64(f.MeterPrice) },
+	"area":       func(f *Flat) float64 { return f.Area },
+	"floor":      func(f *Flat) float64 { return float64(f.Floor) },
+}
+
+var stringFilterFields = map[string]func(f *Flat) string{
+	"bulk":   func(f *Flat) string { return f.BulkShortName() },
+	"metro":  func(f *Flat) string { return f.Metro.Name },
+	"status": func(f *Flat) string { return f.Status },
+	"block":  func(f *Flat) string { return f.BlockSlug },
+}
+
+var filterFieldAliases = map[string]string{
+	"r":       "rooms",
+	"m2price": "meterprice",
+	"ppm":     "meterprice",
+	"m2":      "area",
+	"f":       "floor",
+	"corp":    "bulk",
+	"slug":    "block",
+}
+
+// ParseFlatFilter example: "rooms=2 price<15m metro=Нагатинская"
+func ParseFlatFilter(s string) (FlatFilter, error) {
+	var res FlatFilter
+	for _, field := range strings.Fields(s) {
+		match := filterConditionRegexp.FindStringSubmatch(field)
+		if match == nil {
+			return FlatFilter{}, i18n.NewError("filter.invalid", field)
+		}
+		cond := FilterCondition{
+			Field: strings.ToLower(match[1]),
+			Op:    match[2],
+			Value: match[3],
+		}
+		if alias, ok := filterFieldAliases[cond.Field]; ok {
+			cond.Field = alias
+		}
+
+		if _, ok := numericFilterFields[cond.Field]; ok {
+			if _, err := ParseFilterNumber(cond.Value); err != nil {
+				return FlatFilter{}, err
+			}
+		} else if _, ok := stringFilterFields[cond.Field]; ok {
+			if cond.Op != "=" && cond.Op != "!=" {
+				return FlatFilter{}, i18n.NewError("filter.string.op", cond.Field)
+			}
+		} else {
+			return FlatFilter{}, i18n.NewError("filter.unknown.field", cond.Field)
+		}
+
+		res.Conditions = append(res.Conditions, cond)
+	}
+	return res, nil
+}
+
+// ParseFilterNumber example: "15m" => 15_000_000, "350k" => 350_000, "1.5m" => 1_500_000
+func ParseFilterNumber(s string) (float64, error) {
+	s = strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(s, "_", ""), ",", "."))
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(s, "m"):
+		multiplier, s = 1_000_000, strings.TrimSuffix(s, "m")
+	case strings.HasSuffix(s, "k"):
+		multiplier, s = 1_000, strings.TrimSuffix(s, "k")
+	}
+	number, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, i18n.NewError("number.invalid", s)
+	}
+	return number * multiplier, nil
+}
+
+func (f FlatFilter) Empty() bool {
+	return len(f.Conditions) == 0
+}
+
+// Get the value of the first condition with the field, e.g. "2" for "rooms=2"
+func (f FlatFilter) Get(field string) (string, bool) {
+	for _, cond := range f.Conditions {
+		if cond.Field == field {
+			return cond.Value, true
+		}
+	}
+	return "", false
+}
+
+// Without the same filter without conditions on the field
+func (f FlatFilter) Without(field string) FlatFilter {
+	var res FlatFilter
+	for _, cond := range f.Conditions {
+		if cond.Field != field {
+			res.Conditions = append(res.Conditions, cond)
+		}
+	}
+	return res
+}
+
+func (f FlatFilter) Match(flat *Flat) bool {
+	for _, cond := range f.Conditions {
+		if !cond.Match(flat) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c FilterCondition) Match(flat *Flat) bool {
+	if getter, ok := stringFilterFields[c.Field]; ok {
+		equal := normalizeFilterString(getter(flat)) == normalizeFilterString(c.Value)
+		if c.Field == "bulk" {
+			equal = getter(flat) == NormalizeBulk(c.Value)
+		}
+		return equal == (c.Op == "=")
+	}
+
+	getter, ok := numericFilterFields[c.Field]
+	if !ok {
+		return false
+	}
+	value, err := ParseFilterNumber(c.Value)
+	if err != nil {
+		return false
+	}
+	actual := getter(flat)
+	switch c.Op {
+	case "=":
+		return actual == value
+	case "!=":
+		return actual != value
+	case "<":
+		return actual < value
+	case "<=":
+		return actual <= value
+	case ">":
+		return actual > value
+	case ">=":
+		return actual >= value
+	}
+	return false
+}
+
+func normalizeFilterString(s string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), "ё", "е")
+}
+
+func (f FlatFilter) String() string {
+	var res []string
+	for _, cond := range f.Conditions {
+		res = append(res, cond.Field+cond.Op+cond.Value)
+	}
+	return strings.Join(res, " ")
+}
+
+// Filter returns a copy with the matching flats only
+func (md *MessageData) Filter(filter FlatFilter) *MessageData {
+	res := md.Copy()
+	if res == nil || filter.Empty() {
+		return res
+	}
+	filtered := make([]Flat, 0, len(res.Flats))
+	for i := range res.Flats {
+		if filter.Match(&res.Flats[i]) {
+			filtered = append(filtered, res.Flats[i])
+		}
+	}
+	res.Flats = filtered
+	return res
+}
+
+// Reject returns a copy without the flats for which hidden is true
+func (md *MessageData) Reject(hidden func(flat *Flat) bool) *MessageData {
+	res := md.Copy()
+	if res == nil {
+		return res
+	}
+	filtered := make([]Flat, 0, len(res.Flats))
+	for i := range res.Flats {
+		if !hidden(&res.Flats[i]) {
+			filtered = append(filtered, res.Flats[i])
+		}
+	}
+	res.Flats = filtered
+	return res
+}
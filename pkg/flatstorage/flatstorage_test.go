@@ -0,0 +1,583 @@
+package flatstorage
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectRepricing(t *testing.T) {
+	oldMsg := &MessageData{Flats: []Flat{
+		{ID: 1, Rooms: 1, Price: 10_000_000},
+		{ID: 2, Rooms: 1, Price: 10_000_000},
+		{ID: 3, Rooms: 2, Price: 20_000_000},
+		{ID: 4, Rooms: 2, Price: 20_000_000},
+		{ID: 5, Rooms: 2, Price: 20_000_000},
+		{ID: 6, Rooms: 3, Price: 30_000_000},
+	}}
+
+	tests := []struct {
+		newFlats []Flat
+		compared int
+		repriced int
+		rooms    []RoomsRepricing
+		isWave   bool
+	}{
+		{
+			newFlats: []Flat{{ID: 1, Rooms: 1, Price: 10_000_000}, {ID: 7, Rooms: 1, Price: 1}},
+			compared: 1,
+			repriced: 0,
+			isWave:   false,
+		},
+		{
+			newFlats: []Flat{
+				{ID: 1, Rooms: 1, Price: 10_500_000},
+				{ID: 2, Rooms: 1, Price: 10_300_000},
+				{ID: 3, Rooms: 2, Price: 21_000_000},
+				{ID: 4, Rooms: 2, Price: 20_400_000},
+				{ID: 5, Rooms: 2, Price: 20_000_000},
+				{ID: 6, Rooms: 3, Price: 33_000_000},
+			},
+			compared: 6,
+			repriced: 5,
+			rooms: []RoomsRepricing{
+				{Rooms: 1, Repriced: 2, MedianChange: 4},
+				{Rooms: 2, Repriced: 2, MedianChange: 3.5},
+				{Rooms: 3, Repriced: 1, MedianChange: 10},
+			},
+			isWave: true,
+		},
+	}
+
+	for i, test := range tests {
+		res := DetectRepricing(oldMsg, &MessageData{Flats: test.newFlats})
+		require.Equal(t, test.compared, res.Compared, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.repriced, res.Repriced, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, len(test.rooms), len(res.Rooms), fmt.Sprintf("failed case %v", i))
+		for j := range test.rooms {
+			require.Equal(t, test.rooms[j].Rooms, res.Rooms[j].Rooms, fmt.Sprintf("failed case %v", i))
+			require.Equal(t, test.rooms[j].Repriced, res.Rooms[j].Repriced, fmt.Sprintf("failed case %v", i))
+			require.InDelta(t, test.rooms[j].MedianChange, res.Rooms[j].MedianChange, 1e-9, fmt.Sprintf("failed case %v", i))
+		}
+		require.Equal(t, test.isWave, res.IsWave(), fmt.Sprintf("failed case %v", i))
+	}
+}
+
+func TestNormalizeBulk(t *testing.T) {
+	tests := []struct {
+		bulk     string
+		expected string
+	}{
+		{"Корпус 1.1", "1.1"},
+		{"к1.1", "1.1"},
+		{"К1.1", "1.1"},
+		{"k1.1", "1.1"},
+		{"1.1", "1.1"},
+		{" корпус  2 ", "2"},
+		{"", ""},
+	}
+
+	for i, test := range tests {
+		require.Equal(t, test.expected, NormalizeBulk(test.bulk), fmt.Sprintf("failed case %v", i))
+	}
+}
+
+func TestForBulks(t *testing.T) {
+	oldMsg := &MessageData{Flats: []Flat{
+		{ID: 1, BulkName: "Корпус 1.1", Rooms: 1, Price: 10_000_000},
+		{ID: 2, BulkName: "Корпус 1.1", Rooms: 1, Price: 10_000_000},
+		{ID: 3, BulkName: "Корпус 1.2", Rooms: 2, Price: 20_000_000},
+		{ID: 4, BulkName: "Корпус 1.2", Rooms: 2, Price: 20_000_000},
+		{ID: 5, BulkName: "Корпус 1.2", Rooms: 2, Price: 20_000_000},
+		{ID: 6, BulkName: "Корпус 1.2", Rooms: 2, Price: 20_000_000},
+		{ID: 7, BulkName: "Корпус 1.2", Rooms: 2, Price: 20_000_000},
+	}}
+	// the whole 1.2 is repriced, 1.1 is not
+	newMsg := oldMsg.Copy()
+	for i := 2; i < len(newMsg.Flats); i++ {
+		newMsg.Flats[i].Price = 21_000_000
+	}
+	newMsg.Flats = append(newMsg.Flats, Flat{ID: 8, BulkName: "Корпус 1.1", Rooms: 3}, Flat{ID: 9, BulkName: "Корпус 1.2", Rooms: 3})
+	update := &BlockUpdate{
+		NewFlats:  &MessageData{Flats: newMsg.Flats[7:]},
+		Repricing: DetectRepricing(oldMsg, newMsg),
+	}
+
+	tests := []struct {
+		bulks    []string
+		newFlats []int64
+		compared int
+		repriced int
+		isWave   bool
+	}{
+		{nil, []int64{8, 9}, 7, 5, true},
+		{[]string{"Корпус 1.1"}, []int64{8}, 2, 0, false},
+		{[]string{"к1.1"}, []int64{8}, 2, 0, false},
+		{[]string{"k1.2"}, []int64{9}, 5, 5, true},
+		{[]string{"1.1", "1.2"}, []int64{8, 9}, 7, 5, true},
+		{[]string{"2.1"}, nil, 0, 0, false},
+	}
+
+	for i, test := range tests {
+		res := update.ForBulks(test.bulks)
+		var ids []int64
+		for _, flat := range res.NewFlats.Flats {
+			ids = append(ids, flat.ID)
+		}
+		require.Equal(t, test.newFlats, ids, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.compared, res.Repricing.Compared, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.repriced, res.Repricing.Repriced, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.isWave, res.Repricing.IsWave(), fmt.Sprintf("failed case %v", i))
+	}
+
+	// the source is not changed
+	require.Len(t, update.NewFlats.Flats, 2)
+	require.Equal(t, 7, update.Repricing.Compared)
+}
+
+func TestMergeNewFlatsIntoOldPriceHistory(t *testing.T) {
+	oldMsg := &MessageData{Flats: []Flat{
+		{ID: 1, Price: 100, Created: "2024-01-01T00:00:00Z"},
+		{ID: 2, Price: 200, Created: "2024-01-01T00:00:00Z"},
+	}}
+	newMsg := &MessageData{Flats: []Flat{
+		{ID: 1, Price: 100},
+		{ID: 2, Price: 250},
+	}}
+
+	res := MergeNewFlatsIntoOld(oldMsg, newMsg)
+	require.Len(t, res.Flats, 2)
+
+	for _, flat := range res.Flats {
+		require.Equal(t, "2024-01-01T00:00:00Z", flat.Created)
+		switch flat.ID {
+		case 1:
+			require.Empty(t, flat.PriceHistory)
+		case 2:
+			require.Len(t, flat.PriceHistory, 2)
+			require.Equal(t, PricePoint{Date: "2024-01-01T00:00:00Z", Price: 200}, flat.PriceHistory[0])
+			require.Equal(t, int64(250), flat.PriceHistory[1].Price)
+		}
+	}
+}
+
+func TestRelistedFlats(t *testing.T) {
+	flat := Flat{BlockSlug: "2ngt", BulkName: "Корпус 1.1", Floor: 5, Area: 35.2, Rooms: 1, PlanURL: "plan.svg"}
+
+	gone := flat
+	gone.ID, gone.Price, gone.Created = 1, 100, "2024-01-01T00:00:00Z"
+	stillThere := flat
+	stillThere.ID, stillThere.Created = 2, "2024-01-01T00:00:00Z"
+	oldMsg := &MessageData{Flats: []Flat{gone, stillThere}}
+
+	relisted := flat
+	relisted.ID, relisted.Price = 3, 110
+	newMsg := &MessageData{Flats: []Flat{stillThere, relisted}}
+
+	filtered := FilterWithFlatStorageHelper(oldMsg, newMsg.Copy())
+	require.Len(t, filtered.Flats, 1)
+	require.Equal(t, int64(3), filtered.Flats[0].ID)
+	require.Equal(t, int64(1), filtered.Flats[0].RelistedFrom)
+	require.Contains(t, filtered.MakeHeader(i18n.En), "0 new and 1 relisted")
+
+	merged := MergeNewFlatsIntoOld(oldMsg, newMsg)
+	for _, f := range merged.Flats {
+		if f.ID != 3 {
+			continue
+		}
+		require.Equal(t, int64(1), f.RelistedFrom)
+		require.Equal(t, "2024-01-01T00:00:00Z", f.Created)
+		require.Len(t, f.PriceHistory, 2)
+	}
+
+	// the old flat must not be matched twice
+	another := flat
+	another.ID = 4
+	filtered = FilterWithFlatStorageHelper(merged, &MessageData{Flats: []Flat{stillThere, relisted, another}})
+	require.Len(t, filtered.Flats, 1)
+	require.Equal(t, int64(0), filtered.Flats[0].RelistedFrom)
+}
+
+func TestFlatFilter(t *testing.T) {
+	flats := []Flat{
+		{ID: 1, Rooms: 1, Price: 9_000_000, Area: 30, Floor: 2, BulkName: "Корпус 1.1", Status: "free", Metro: Metro{Name: "Нагатинская"}},
+		{ID: 2, Rooms: 2, Price: 14_500_000, Area: 55, Floor: 10, BulkName: "Корпус 1.2", Status: "reserve", Metro: Metro{Name: "Нагатинская"}},
+		{ID: 3, Rooms: 2, Price: 16_000_000, Area: 60, Floor: 20, BulkName: "Корпус 1.1", Status: "free", Metro: Metro{Name: "Коломенская"}},
+	}
+
+	tests := []struct {
+		filter   string
+		expected []int64
+		isErr    bool
+	}{
+		{"", []int64{1, 2, 3}, false},
+		{"rooms=2", []int64{2, 3}, false},
+		{"rooms=2 price<15m", []int64{2}, false},
+		{"area>=55 floor>10", []int64{3}, false},
+		{"bulk=1.1", []int64{1, 3}, false},
+		{"corp!=1.1", []int64{2}, false},
+		{"metro=нагатинская status=free", []int64{1}, false},
+		{"price<=14_500k", []int64{1, 2}, false},
+		{"rooms~2", nil, true},
+		{"height>3", nil, true},
+		{"price<many", nil, true},
+		{"metro>Нагатинская", nil, true},
+	}
+
+	for i, test := range tests {
+		filter, err := ParseFlatFilter(test.filter)
+		if test.isErr {
+			require.Error(t, err, fmt.Sprintf("failed case %v", i))
+			continue
+		}
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+
+		var ids []int64
+		for _, flat := range (&MessageData{Flats: flats}).Filter(filter).Flats {
+			ids = append(ids, flat.ID)
+		}
+		require.Equal(t, test.expected, ids, fmt.Sprintf("failed case %v", i))
+	}
+}
+
+func TestUnmarshallFlatDetails(t *testing.T) {
+	// the shape the card relies on, written by hand: replace with a captured response when one is available
+	fixture, err := os.ReadFile(filepath.Join("testdata", "flat_details.json"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		body     string
+		expected FlatDetails
+		err      bool
+	}{
+		{
+			body: string(fixture),
+			expected: FlatDetails{ID: 819556, Address: "Москва, Нагатинская наб., 10к1", CeilingHeight: 2.85,
+				WindowView: []string{"во двор", "на реку"}, FinishType: 1, Benefits: []string{"Семейная ипотека"}},
+		},
+		{
+			body:     `{"data":{"id":819556,"finishType":2}}`,
+			expected: FlatDetails{ID: 819556, FinishType: 2},
+		},
+		{body: `{"data":{"address":"no id"}}`, err: true},
+		{body: `{"data":{"id":"819556"}}`, err: true},
+		{body: `not json`, err: true},
+	}
+
+	for i, test := range tests {
+		details, err := UnmarshallFlatDetails([]byte(test.body))
+		if test.err {
+			require.Error(t, err, fmt.Sprintf("failed case %v", i))
+			continue
+		}
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+		details.Fetched = ""
+		require.Equal(t, test.expected, *details, fmt.Sprintf("failed case %v", i))
+	}
+}
+
+func TestExport(t *testing.T) {
+	msgData := &MessageData{Flats: []Flat{
+		{ID: 1, Rooms: 2, Area: 54.3, Price: 12_000_000, MeterPrice: 221_000, BlockName: "Второй Нагатинский", Status: "free",
+			Created: "2024-05-01", Updated: "2024-05-20",
+			PriceHistory: []PricePoint{{Date: "2024-05-01", Price: 12_500_000}, {Date: "2024-05-10", Price: 12_000_000}}},
+		{ID: 2, BlockName: `"Квартал" <Лесной> & Co`},
+	}}
+
+	content, err := msgData.CSV()
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimPrefix(string(content), utf8BOM), "\n")
+	require.Equal(t, "id,block,block_slug,bulk,rooms,area,floor,max_floor,price,meter_price,status,metro,created,updated,plan_url,price_history", lines[0])
+	require.Equal(t, "1,Второй Нагатинский,,,2,54.3,0,0,12000000,221000,free,,2024-05-01,2024-05-20,,2024-05-01 12500000; 2024-05-10 12000000", lines[1])
+	require.Equal(t, `2,"""Квартал"" <Лесной> & Co",,,0,0,0,0,0,0,,,,,,`, lines[2])
+
+	content, err = msgData.XLSX()
+	require.NoError(t, err)
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+	require.Len(t, archive.File, 5)
+
+	sheet, err := archive.Open("xl/worksheets/sheet1.xml")
+	require.NoError(t, err)
+	sheetContent, err := io.ReadAll(sheet)
+	require.NoError(t, err)
+	require.NoError(t, xml.Unmarshal(sheetContent, &struct{ XMLName xml.Name }{}))
+	require.Contains(t, string(sheetContent), "<c><v>12000000</v></c>")
+	require.Contains(t, string(sheetContent), "&#34;Квартал&#34; &lt;Лесной&gt; &amp; Co")
+}
+
+func TestBlockStats(t *testing.T) {
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	recent, old := "2024-05-20T11:55:00Z", "2024-05-11T00:00:00Z"
+
+	msgData := &MessageData{Flats: []Flat{
+		{ID: 1, Rooms: 1, Price: 9_000_000, MeterPrice: 300_000, Status: "free", Created: "2024-05-10T12:00:00Z", Updated: recent,
+			SettlementDate: "2025-06-15"},
+		{ID: 2, Rooms: 1, Price: 10_000_000, MeterPrice: 310_000, Status: "reserve", Created: "2024-05-20T12:00:00Z", Updated: recent},
+		{ID: 3, Rooms: 1, Price: 12_000_000, MeterPrice: 330_000, Status: "free", Created: "2024-05-15T12:00:00Z", Updated: recent},
+		{ID: 4, Rooms: 2, Price: 15_000_000, MeterPrice: 280_000, Status: "free", Created: "2024-05-20T12:00:00Z", Updated: recent,
+			SettlementDate: "2026-03-31"},
+		{ID: 5, Rooms: 2, Price: 14_000_000, MeterPrice: 270_000, Status: "free", Created: "2024-05-01T00:00:00Z", Updated: old},
+		{ID: 6, Rooms: 3, Price: 20_000_000, MeterPrice: 260_000, Status: "free", Created: "2024-05-07T00:00:00Z", Updated: old},
+	}}
+
+	stats := msgData.Stats(now)
+	require.Equal(t, 4, stats.Total)
+	require.Equal(t, 3, stats.Free)
+	require.Equal(t, 1, stats.Reserved)
+	require.Equal(t, 2, stats.Sold)
+	require.Equal(t, 7.0, stats.AvgDaysOnMarket)
+	require.Equal(t, 3.75, stats.AvgDaysListed)
+	require.Equal(t, int64(280_000), stats.MinMeterPrice)
+	require.Equal(t, int64(305_000), stats.MedianMeterPrice)
+	require.Equal(t, "2025-06-15", stats.SettlementFrom)
+	require.Equal(t, "2026-03-31", stats.SettlementTo)
+	require.Equal(t, []RoomStats{
+		{Rooms: 1, Count: 3, Free: 2, Reserved: 1, MinPrice: 9_000_000, MedianPrice: 10_000_000, MaxPrice: 12_000_000,
+			MinMeterPrice: 300_000, MedianMeterPrice: 310_000, MaxMeterPrice: 330_000},
+		{Rooms: 2, Count: 1, Free: 1, MinPrice: 15_000_000, MedianPrice: 15_000_000, MaxPrice: 15_000_000,
+			MinMeterPrice: 280_000, MedianMeterPrice: 280_000, MaxMeterPrice: 280_000},
+	}, stats.Rooms)
+
+	require.Contains(t, stats.String(), "1r        3    2    1  9.0/10.0/12.0   300/310/330")
+
+	// a late call (e.g. after the bot was down) still counts the flats of the last poll as on sale
+	late := msgData.Stats(now.Add(3 * time.Hour))
+	require.Equal(t, 4, late.Total)
+	require.Equal(t, 2, late.Sold)
+}
+
+func TestMedianMeterPriceHistory(t *testing.T) {
+	msgData := &MessageData{Flats: []Flat{
+		{ID: 1, Rooms: 1, Area: 40, Price: 12_000_000, Created: "2024-05-01T10:00:00Z", Updated: "2024-05-03T10:00:00Z",
+			PriceHistory: []PricePoint{{Date: "2024-05-01T10:00:00Z", Price: 13_000_000}, {Date: "2024-05-02T10:00:00Z", Price: 12_000_000}}},
+		{ID: 2, Rooms: 1, Area: 50, Price: 15_000_000, Created: "2024-05-02T10:00:00Z", Updated: "2024-05-03T10:00:00Z"},
+		{ID: 3, Rooms: 2, Area: 60, Price: 18_000_000, Created: "2024-04-30T10:00:00Z", Updated: "2024-05-01T10:00:00Z"},
+	}}
+
+	require.Equal(t, []PricePoint{
+		{Date: "2024-05-01T10:00:00Z", Price: 13_000_000},
+		{Date: "2024-05-02T10:00:00Z", Price: 12_000_000},
+		{Date: "2024-05-03T10:00:00Z", Price: 12_000_000},
+	}, msgData.Flats[0].PricePoints())
+
+	history := msgData.MedianMeterPriceHistory()
+	require.Equal(t, []PricePoint{
+		{Date: "2024-05-01", Price: 325_000},
+		{Date: "2024-05-02", Price: 300_000},
+		{Date: "2024-05-03", Price: 300_000},
+	}, history[1])
+	require.Equal(t, []PricePoint{
+		{Date: "2024-04-30", Price: 300_000},
+		{Date: "2024-05-01", Price: 300_000},
+	}, history[2])
+}
+
+func TestScoreDeals(t *testing.T) {
+	block := &MessageData{Flats: []Flat{
+		{ID: 1, Rooms: 2, Area: 54, Floor: 10, MeterPrice: 300_000},
+		{ID: 2, Rooms: 2, Area: 56, Floor: 12, MeterPrice: 310_000},
+		{ID: 3, Rooms: 2, Area: 52, Floor: 8, MeterPrice: 290_000},
+		{ID: 4, Rooms: 2, Area: 55, Floor: 11, MeterPrice: 276_000},
+		// not comparable: rooms, area, floor
+		{ID: 5, Rooms: 3, Area: 55, Floor: 10, MeterPrice: 200_000},
+		{ID: 6, Rooms: 2, Area: 80, Floor: 10, MeterPrice: 200_000},
+		{ID: 7, Rooms: 2, Area: 54, Floor: 30, MeterPrice: 200_000},
+	}}
+
+	block.ScoreDeals(block)
+
+	require.InDelta(t, 0.08, block.Flats[3].Discount, 0.0001)
+	require.True(t, block.Flats[3].IsDeal())
+	require.False(t, block.Flats[0].IsDeal())
+	require.Zero(t, block.Flats[4].Discount)
+	require.Contains(t, block.Flats[3].String(), "💰 -8% vs similar")
+	require.NotContains(t, block.Flats[0].String(), "💰")
+
+	deals := block.Deals()
+	require.Len(t, deals.Flats, 1)
+	require.Equal(t, int64(4), deals.Flats[0].ID)
+}
+
+func TestMarketReport(t *testing.T) {
+	now := time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC)
+	onSale := "2024-05-31T11:55:00Z"
+
+	msgData := &MessageData{Flats: []Flat{
+		{ID: 1, Rooms: 1, BulkName: "Корпус 1", PlanURL: "a.svg", Area: 35, Created: "2024-05-01T12:00:00Z", Updated: "2024-05-11T12:00:00Z"},
+		{ID: 2, Rooms: 1, BulkName: "Корпус 1", PlanURL: "a.svg", Area: 35, Created: "2024-05-01T12:00:00Z", Updated: "2024-05-29T12:00:00Z"},
+		{ID: 3, Rooms: 1, BulkName: "Корпус 2", PlanURL: "a.svg", Area: 35, Created: "2024-05-01T12:00:00Z", Updated: onSale},
+		{ID: 4, Rooms: 2, BulkName: "Корпус 2", PlanURL: "b.svg", Area: 55, Created: "2024-05-20T12:00:00Z", Updated: "2024-05-22T12:00:00Z"},
+		{ID: 5, Rooms: 2, BulkName: "Корпус 2", PlanURL: "c.svg", Area: 60, Created: "2024-05-20T12:00:00Z", Updated: onSale},
+		// sold before the windows
+		{ID: 6, Rooms: 2, BulkName: "Корпус 2", PlanURL: "b.svg", Area: 55, Created: "2024-03-01T12:00:00Z", Updated: "2024-04-01T12:00:00Z"},
+	}}
+
+	report := msgData.MarketReport(now)
+	require.Equal(t, []SellThrough{
+		{Key: "7", Example: &msgData.Flats[1], Listed: 3, Sold: 1, AvgDays: 28},
+		{Key: "30", Example: &msgData.Flats[0], Listed: 5, Sold: 3, AvgDays: 40.0 / 3},
+	}, report.Total)
+
+	require.Len(t, report.Rooms, 2)
+	require.Equal(t, 2, report.Rooms[0].Sold)
+	require.Equal(t, 3, report.Rooms[0].Listed)
+	require.Len(t, report.Bulks, 2)
+
+	require.Len(t, report.Layouts, 2)
+	require.Equal(t, "b.svg", report.Layouts[0].Key)
+	require.Equal(t, 2.0, report.Layouts[0].AvgDays)
+	require.Equal(t, "a.svg", report.Layouts[1].Key)
+
+	require.Contains(t, report.String(), "2r: sold 1 of 2 (50%), avg 2 days on market")
+
+	// the flats of the last poll are not sold, however long ago it was
+	require.Equal(t, report.Total, msgData.MarketReport(now.Add(2*time.Hour)).Total)
+}
+
+func TestFlatSort(t *testing.T) {
+	msgData := &MessageData{Flats: []Flat{
+		{ID: 1, Price: 12_000_000, Area: 40, Created: "2024-05-02T00:00:00Z"},
+		{ID: 2, Price: 10_000_000, Area: 60, Created: "2024-05-01T00:00:00Z"},
+		{ID: 3, Price: 11_000_000, Area: 60, Created: "2024-05-03T00:00:00Z"},
+	}}
+
+	tests := []struct {
+		sort     string
+		expected []int64
+	}{
+		{"", []int64{2, 3, 1}},
+		{"-price", []int64{1, 3, 2}},
+		{"-m2", []int64{2, 3, 1}},
+		{"created", []int64{2, 1, 3}},
+	}
+
+	for i, test := range tests {
+		flatSort, err := ParseFlatSort(test.sort)
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+		msgData.Sort(flatSort)
+
+		var ids []int64
+		for _, flat := range msgData.Flats {
+			ids = append(ids, flat.ID)
+		}
+		require.Equal(t, test.expected, ids, fmt.Sprintf("failed case %v", i))
+	}
+
+	_, err := ParseFlatSort("bulk")
+	require.Error(t, err)
+}
+
+func TestGroup(t *testing.T) {
+	plan := "https://example.com/plan.svg"
+	msgData := &MessageData{Flats: []Flat{
+		{ID: 1, BlockName: "Второй Нагатинский", BulkName: "Корпус 1.1", PlanURL: plan, Rooms: 2, Area: 54.3, Floor: 17, Price: 13_500_000},
+		{ID: 2, BlockName: "Второй Нагатинский", BulkName: "Корпус 1.2", PlanURL: plan, Rooms: 2, Area: 54.3, Floor: 3, Price: 12_345_000},
+		{ID: 3, BlockName: "Второй Нагатинский", BulkName: "Корпус 1.1", PlanURL: plan, Rooms: 2, Area: 54.3, Floor: 9, Price: 12_900_000},
+		{ID: 4, BlockName: "Второй Нагатинский", BulkName: "Корпус 1.1", Rooms: 1, Area: 35, Floor: 5, Price: 9_000_000},
+	}}
+
+	tests := []struct {
+		by       string
+		expected []string
+	}{
+		{"layout", []string{
+			"1r, 35.0m2 ×1: 9 000 000R, f5, bulk 1.1",
+			"<a href=\"https://example.com/plan.svg\">2r, 54.3m2</a> ×3: 12 345 000-13 500 000R, f3-17, bulk 1.1, 1.2",
+		}},
+		{"corp", []string{
+			"<b>Bulk 1.1</b>: 3 flats, 1-2r, 35.0-54.3m2, 9 000 000-13 500 000R, f5-17",
+			"<b>Bulk 1.2</b>: 1 flat, 2r, 54.3m2, 12 345 000R, f3",
+		}},
+		{"rooms", []string{
+			"<b>1r</b>: 1 flat, 35.0m2, 9 000 000R, f5",
+			"<b>2r</b>: 3 flats, 54.3m2, 12 345 000-13 500 000R, f3-17",
+		}},
+	}
+
+	for i, test := range tests {
+		by, err := ParseGroupBy(test.by)
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+
+		var lines []string
+		for _, group := range msgData.Group(by) {
+			lines = append(lines, group.Format(i18n.En, by))
+		}
+		require.Equal(t, test.expected, lines, fmt.Sprintf("failed case %v", i))
+	}
+
+	require.True(t, strings.HasPrefix(msgData.FormatGrouped(i18n.En, GroupByLayout), "4 flats in Второй Нагатинский, by layout (2):\n"))
+
+	_, err := ParseGroupBy("metro")
+	require.Error(t, err)
+}
+
+func TestDisplay(t *testing.T) {
+	msgData := &MessageData{Flats: []Flat{
+		{ID: 1, BulkName: "Корпус 1.1", Rooms: 2, Area: 54.3, Floor: 5, Price: 12_756_380, MeterPrice: 234_924, Created: "2024-05-02T10:00:00Z"},
+		{ID: 2, BulkName: "Корпус 1.2", Rooms: 1, Area: 32.6, Floor: 19, Price: 9_000_000, MeterPrice: 276_074, Status: "reserve"},
+	}}
+
+	tests := []struct {
+		args     string
+		expected []string
+		isErr    bool
+	}{
+		{"", []string{
+			"1.2: <a href=\"https://www.pik.ru/flat/2\">1r, 32.6m2</a>, 9 000 000R, f19🔒",
+			"1.1: <a href=\"https://www.pik.ru/flat/1\">2r, 54.3m2</a>, 12 756 380R, f5",
+		}, false},
+		{"sort=-area columns=rooms,area,price millions", []string{
+			"<a href=\"https://www.pik.ru/flat/1\">2r, 54.3m2</a>, 12.76mR",
+			"<a href=\"https://www.pik.ru/flat/2\">1r, 32.6m2</a>, 9.00mR🔒",
+		}, false},
+		{"sort=-m2price cols=corp,ppm,created", []string{
+			"1.2: <a href=\"https://www.pik.ru/flat/2\">#2</a>, 276 074R/m2🔒",
+			"1.1: <a href=\"https://www.pik.ru/flat/1\">#1</a>, 234 924R/m2, since 02.05.2024",
+		}, false},
+		{"columns=metro", nil, true},
+		{"millions=maybe", nil, true},
+		{"view=table", nil, true},
+	}
+
+	for i, test := range tests {
+		display, err := ParseDisplay(Display{}, test.args)
+		if test.isErr {
+			require.Error(t, err, fmt.Sprintf("failed case %v", i))
+			continue
+		}
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+
+		lines := strings.Split(msgData.FormatDisplay(i18n.En, display, nil), "\n")
+		require.Equal(t, test.expected, lines[1:], fmt.Sprintf("failed case %v", i))
+	}
+
+	display, err := ParseDisplay(Display{Millions: true}, "sort=area millions=off")
+	require.NoError(t, err)
+	require.Equal(t, "sort=area columns=bulk,rooms,area,price,floor millions=off", display.String())
+	require.Contains(t, msgData.Flats[0].FormatDisplay(i18n.Ru, Display{Millions: true}), "9.00 млн₽")
+}
+
+func TestConfiguredFlatTemplate(t *testing.T) {
+	oldTemplates, oldTexts := flatTemplates, flatTemplateTexts
+	defer func() { flatTemplates, flatTemplateTexts = oldTemplates, oldTexts }()
+
+	// as if read from FlatTemplatesFile: a valid en template and a broken ru one
+	flatTemplates = make(map[i18n.Lang]*template.Template)
+	flatTemplateTexts = map[string]string{"en": "#{{.ID}} {{.Rooms}}r {{.Price}}", "ru": "{{.ID"}
+	flat := &Flat{ID: 1, BulkName: "Корпус 1.1", Rooms: 2, Area: 54.3, Floor: 5, Price: 12_756_380}
+
+	require.Equal(t, "#1 2r 12 756 380R", flat.FormatDisplay(i18n.En, Display{}))
+	require.Equal(t, "1.1: <a href=\"https://www.pik.ru/flat/1\">2к, 54.3м²</a>, 12 756 380₽, эт.5", flat.FormatDisplay(i18n.Ru, Display{}))
+}
@@ -0,0 +1,162 @@
+package flatstorage
+
+import (
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"sort"
+	"strings"
+)
+
+type GroupBy string
+
+const (
+	GroupByLayout GroupBy = "layout" // same PlanURL, area and rooms
+	GroupByBulk   GroupBy = "bulk"
+	GroupByRooms  GroupBy = "rooms"
+)
+
+var groupByAliases = map[string]GroupBy{
+	"layouts": GroupByLayout,
+	"plan":    GroupByLayout,
+	"bulks":   GroupByBulk,
+	"corp":    GroupByBulk,
+	"r":       GroupByRooms,
+}
+
+// ParseGroupBy example: "layout", "bulk", "rooms"
+func ParseGroupBy(s string) (GroupBy, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if alias, ok := groupByAliases[s]; ok {
+		return alias, nil
+	}
+	switch by := GroupBy(s); by {
+	case GroupByLayout, GroupByBulk, GroupByRooms:
+		return by, nil
+	}
+	return "", i18n.NewError("group.unknown", s)
+}
+
+func (by GroupBy) key(f *Flat) string {
+	switch by {
+	case GroupByBulk:
+		return f.BulkShortName()
+	case GroupByRooms:
+		return fmt.Sprintf("%02d", f.Rooms) // 10 rooms after 9
+	}
+	return fmt.Sprintf("%v|%.1f|%v", f.PlanURL, f.Area, f.Rooms)
+}
+
+// FlatGroup flats sharing the layout, the bulk or the number of rooms, sorted by price
+type FlatGroup struct {
+	Key   string
+	Flats []Flat
+}
+
+// Group layouts go from the cheapest, bulks and rooms by name
+func (md *MessageData) Group(by GroupBy) []FlatGroup {
+	if md == nil {
+		return nil
+	}
+	flats := md.Copy()
+	flats.Sort(FlatSort{Field: DefaultSortKey})
+
+	groups := make(map[string]*FlatGroup)
+	var keys []string
+	for _, flat := range flats.Flats {
+		key := by.key(&flat)
+		if groups[key] == nil {
+			groups[key] = &FlatGroup{Key: key}
+			keys = append(keys, key)
+		}
+		groups[key].Flats = append(groups[key].Flats, flat)
+	}
+	if by != GroupByLayout {
+		sort.Strings(keys)
+	}
+
+	res := make([]FlatGroup, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, *groups[key])
+	}
+	return res
+}
+
+// intRange example: 3, 17 => "3-17", 5, 5 => "5"
+func intRange(from int64, to int64) string {
+	if from == to {
+		return fmt.Sprintf("%v", from)
+	}
+	return fmt.Sprintf("%v-%v", from, to)
+}
+
+func priceRange(from int64, to int64) string {
+	if from == to {
+		return util.ThousandSep(from, " ")
+	}
+	return util.ThousandSep(from, " ") + "-" + util.ThousandSep(to, " ")
+}
+
+// Ranges floors, prices (the flats are sorted by price), areas and rooms of the group
+func (g FlatGroup) Ranges() (floors string, prices string, areas string, rooms string) {
+	if len(g.Flats) == 0 {
+		return "", "", "", ""
+	}
+	first := g.Flats[0]
+	minFloor, maxFloor := first.Floor, first.Floor
+	minArea, maxArea := first.Area, first.Area
+	minRooms, maxRooms := first.Rooms, first.Rooms
+	for _, flat := range g.Flats {
+		minFloor, maxFloor = util.Min(minFloor, flat.Floor), util.Max(maxFloor, flat.Floor)
+		minArea, maxArea = util.Min(minArea, flat.Area), util.Max(maxArea, flat.Area)
+		minRooms, maxRooms = util.Min(minRooms, flat.Rooms), util.Max(maxRooms, flat.Rooms)
+	}
+
+	areas = fmt.Sprintf("%.1f", minArea)
+	if minArea != maxArea {
+		areas = fmt.Sprintf("%.1f-%.1f", minArea, maxArea)
+	}
+	return intRange(minFloor, maxFloor), priceRange(first.Price, g.Flats[len(g.Flats)-1].Price),
+		areas, intRange(int64(minRooms), int64(maxRooms))
+}
+
+// Format one line of the group, example for a layout:
+// <a href="plan">2r, 54.3m2</a> ×5: 12 345 000-13 500 000R, f3-17, bulk 1.1, 1.2
+func (g FlatGroup) Format(lang i18n.Lang, by GroupBy) string {
+	if len(g.Flats) == 0 {
+		return ""
+	}
+	first := g.Flats[0]
+	floors, prices, areas, rooms := g.Ranges()
+
+	switch by {
+	case GroupByBulk:
+		return i18n.T(lang, "group.bulk", first.BulkShortName(), i18n.N(lang, "flats", len(g.Flats)), rooms, areas, prices, floors)
+	case GroupByRooms:
+		return i18n.T(lang, "group.rooms", first.Rooms, i18n.N(lang, "flats", len(g.Flats)), areas, prices, floors)
+	}
+
+	bulks := make(map[string]bool)
+	for _, flat := range g.Flats {
+		bulks[flat.BulkShortName()] = true
+	}
+	layout := i18n.T(lang, "group.layout.name", first.Rooms, fmt.Sprintf("%.1f", first.Area))
+	if len(first.PlanURL) > 0 {
+		layout = fmt.Sprintf("<a href=\"%v\">%v</a>", first.PlanURL, layout)
+	}
+	return i18n.T(lang, "group.layout", layout, len(g.Flats), prices, floors, strings.Join(util.SortedKeys(bulks), ", "))
+}
+
+// FormatGrouped Format with a line per group instead of a line per flat
+func (md *MessageData) FormatGrouped(lang i18n.Lang, by GroupBy) string {
+	if md == nil || len(md.Flats) == 0 {
+		return ""
+	}
+	groups := md.Group(by)
+	res := []string{i18n.T(lang, "group.header", i18n.N(lang, "flats", len(md.Flats)), md.Flats[0].BlockName,
+		i18n.T(lang, "group.by."+string(by), len(groups)))}
+	for _, group := range groups {
+		res = append(res, group.Format(lang, by))
+	}
+	return strings.Join(res, "\n")
+}
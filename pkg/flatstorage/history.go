@@ -0,0 +1,77 @@
+package flatstorage
+
+import (
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"math"
+	"time"
+)
+
+// HistoryDays how far back the block price history goes
+const HistoryDays = 90
+
+// PricePoints the price history including the current price at the last update,
+// empty if the dates are unknown
+func (f *Flat) PricePoints() []PricePoint {
+	var res []PricePoint
+	if len(f.PriceHistory) > 0 {
+		res = append(res, f.PriceHistory...)
+	} else if len(f.Created) > 0 {
+		res = append(res, PricePoint{Date: f.Created, Price: f.Price})
+	}
+	if len(res) > 0 && len(f.Updated) > 0 && f.Updated > res[len(res)-1].Date {
+		res = append(res, PricePoint{Date: f.Updated, Price: f.Price})
+	}
+	return res
+}
+
+// PriceAt the price valid at the moment, false if the flat was not listed yet
+func (f *Flat) PriceAt(t time.Time) (int64, bool) {
+	var res int64
+	var found bool
+	for _, point := range f.PricePoints() {
+		date, err := time.Parse(time.RFC3339, point.Date)
+		if err != nil || date.After(t) {
+			break
+		}
+		res, found = point.Price, true
+	}
+	return res, found
+}
+
+// MedianMeterPriceHistory median price per m2 of the flats on sale by day for each number of rooms,
+// for the last HistoryDays days, dates are "2006-01-02"
+func (md *MessageData) MedianMeterPriceHistory() map[int8][]PricePoint {
+	res := make(map[int8][]PricePoint)
+
+	latest, err := time.Parse(time.RFC3339, md.LatestUpdate())
+	if err != nil {
+		return res
+	}
+	lastDay := time.Date(latest.Year(), latest.Month(), latest.Day(), 0, 0, 0, 0, latest.Location())
+
+	for day := lastDay.AddDate(0, 0, -HistoryDays); !day.After(lastDay); day = day.AddDate(0, 0, 1) {
+		endOfDay := day.AddDate(0, 0, 1).Add(-time.Second)
+
+		meterPrices := make(map[int8][]float64)
+		for i := range md.Flats {
+			flat := &md.Flats[i]
+			updated, err := time.Parse(time.RFC3339, flat.Updated)
+			if err != nil || updated.Before(day) || flat.Area <= 0 {
+				continue
+			}
+			price, ok := flat.PriceAt(endOfDay)
+			if !ok {
+				continue
+			}
+			meterPrices[flat.Rooms] = append(meterPrices[flat.Rooms], float64(price)/flat.Area)
+		}
+
+		for _, rooms := range util.SortedKeys(meterPrices) {
+			res[rooms] = append(res[rooms], PricePoint{
+				Date:  day.Format(time.DateOnly),
+				Price: int64(math.Round(util.Median(meterPrices[rooms]))),
+			})
+		}
+	}
+	return res
+}
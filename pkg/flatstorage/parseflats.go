@@ -2,9 +2,7 @@ package flatstorage
 
 import (
 	"encoding/json"
-	"fmt"
-	"github.com/georgri/sledopyt_addresses/pkg/util"
-	"sort"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
 	"strings"
 	"time"
 )
@@ -28,8 +26,7 @@ type Flat struct {
 	Price  int64   `json:"price"`
 	Rooms  int8    `json:"rooms"`
 	Status string  `json:"status"`
-	// TODO: find Url plan with areas, maybe with address https://www.pik.ru/flat/819556
-	// https://flat.pik-service.ru/api/v1/flat/819556
+	// address and more details: see FlatDetails
 	PlanURL   string `json:"planUrl"`   // https:\/\/0.db-estate.cdn.pik-service.ru\/layout\/2022\/06\/13\/1_sem2_2el36_4_2x12_6-1_t_a_90_PgbXHE4ZDppCmmc2.svg
 	BulkName  string `json:"bulkName"`  // Корпус 1.1
 	MaxFloor  int8   `json:"maxFloor"`  // 33
@@ -37,6 +34,20 @@ type Flat struct {
 	BlockSlug string `json:"blockSlug"`
 	Created   string `json:"created,omitempty"` // when the flat first appeared
 	Updated   string `json:"updated,omitempty"` // when the flat was last seen (to filter out the old ones)
+
+	MeterPrice   int64        `json:"meterPrice"`             // 334300
+	PriceHistory []PricePoint `json:"priceHistory,omitempty"` // empty if the price never changed
+	RelistedFrom int64        `json:"relistedFrom,omitempty"` // ID of the same physical flat listed before
+
+	SettlementDate string `json:"settlementDate,omitempty"` // 2025-06-15, keys handover
+
+	Discount float64 `json:"-"` // vs similar flats of the block, see ScoreDeals
+}
+
+// PricePoint the price set at Date (RFC3339) and valid until the next point
+type PricePoint struct {
+	Date  string `json:"date"`
+	Price int64  `json:"price"`
 }
 
 type MessageData struct {
@@ -99,17 +110,31 @@ func (md *MessageData) Copy() *MessageData {
 // {number of Flats} новых объектов в ЖК "Второй Нагатинский" (м.Нагатинская (color #ACADAF)):
 // Корпус 1.3 #831859[url link to flat]: 32.6m, 1r, f19, 12_756_380rub,
 func (md *MessageData) String() string {
+	return md.Format(i18n.En)
+}
 
-	// sorting by price
-	sort.Slice(md.Flats, func(i, j int) bool {
-		return md.Flats[i].Price < md.Flats[j].Price
-	})
+// Format String in the language of the chat
+func (md *MessageData) Format(lang i18n.Lang) string {
+	return md.FormatDisplay(lang, Display{}, nil)
+}
+
+// FormatDisplay Format sorted and formatted as the display says,
+// the suffix is appended to each flat line, e.g. the mortgage payment
+func (md *MessageData) FormatDisplay(lang i18n.Lang, d Display, suffix func(f *Flat) string) string {
+
+	md.Sort(d.FlatSort())
 
-	res := md.MakeHeader()
+	res := md.MakeHeader(lang)
 
 	flats := make([]string, 0, len(md.Flats))
-	for _, flat := range md.Flats {
-		flats = append(flats, flat.String())
+	for i := range md.Flats {
+		line := md.Flats[i].FormatDisplay(lang, d)
+		if suffix != nil {
+			if extra := suffix(&md.Flats[i]); len(extra) > 0 {
+				line += " " + extra
+			}
+		}
+		flats = append(flats, line)
 	}
 
 	res += "\n" + strings.Join(flats, "\n") // try <br>
@@ -119,7 +144,7 @@ func (md *MessageData) String() string {
 
 // MakeHeader example:
 // // {number of Flats} новых объектов в ЖК "Второй Нагатинский" (м.Нагатинская (color #ACADAF)):
-func (md *MessageData) MakeHeader() string {
+func (md *MessageData) MakeHeader(lang i18n.Lang) string {
 
 	if md == nil || len(md.Flats) == 0 {
 		return ""
@@ -131,8 +156,17 @@ func (md *MessageData) MakeHeader() string {
 	// metro := flat.Metro.Name // to large message
 	// metroColor := flat.Metro.Color // telegram doesn't support text color :(
 
-	res := fmt.Sprintf("%v new flats in %v:",
-		numFlats, blockName)
+	var numRelisted int
+	for i := range md.Flats {
+		if md.Flats[i].RelistedFrom != 0 {
+			numRelisted += 1
+		}
+	}
+
+	res := i18n.T(lang, "flats.header", i18n.N(lang, "flats.new", numFlats), blockName)
+	if numRelisted > 0 {
+		res = i18n.T(lang, "flats.header.relisted", numFlats-numRelisted, numRelisted, blockName)
+	}
 
 	return res
 }
@@ -152,26 +186,17 @@ func (f *Flat) RecentlyUpdated(now time.Time) bool {
 	return now.Sub(t) < FlatValidInterval
 }
 
+// BulkShortName example: "Корпус 1.3" => "1.3"
+func (f *Flat) BulkShortName() string {
+	return NormalizeBulk(f.BulkName)
+}
+
 // String example:
 // Корпус 1.3 #831859[url link to flat]: 32.6m, 1r, f19, 12_756_380rub,
 func (f *Flat) String() string {
-	if f == nil {
-		return ""
-	}
-
-	corp := strings.Split(f.BulkName, " ")[1]
-	id := f.ID
-	flatURL := fmt.Sprintf("https://www.pik.ru/flat/%v", id)
-	area := fmt.Sprintf("%.1f", f.Area)
-	rooms := f.Rooms
-	floor := f.Floor
-	price := util.ThousandSep(f.Price, " ")
-	var reserve string
-	if f.Status == "reserve" {
-		reserve = "🔒"
-	}
-
-	res := fmt.Sprintf("%v: <a href=\"%v\">%vr, %vm2</a>, %vR, f%v%v", corp, flatURL, rooms, area, price, floor, reserve)
+	return f.Format(i18n.En)
+}
 
-	return res
+func (f *Flat) Format(lang i18n.Lang) string {
+	return f.FormatDisplay(lang, Display{})
 }
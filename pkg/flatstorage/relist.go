@@ -0,0 +1,70 @@
+package flatstorage
+
+import (
+	"fmt"
+	"sort"
+)
+
+// PhysicalKey identifies the apartment itself, not the listing:
+// PIK sometimes re-lists the same flat under a new ID
+func (f *Flat) PhysicalKey() string {
+	return fmt.Sprintf("%v|%v|%v|%.1f|%v|%v", f.BlockSlug, f.BulkName, f.Floor, f.Area, f.Rooms, f.PlanURL)
+}
+
+// LinkRelistedFlats set RelistedFrom for every flat in newMsg with unknown ID
+// that matches an old flat gone from newMsg by the physical key
+func LinkRelistedFlats(oldMsg, newMsg *MessageData) {
+	if oldMsg == nil || newMsg == nil || len(oldMsg.Flats) == 0 {
+		return
+	}
+
+	newIDs := make(map[int64]struct{}, len(newMsg.Flats))
+	for i := range newMsg.Flats {
+		newIDs[newMsg.Flats[i].ID] = struct{}{}
+	}
+
+	oldIDs := make(map[int64]struct{}, len(oldMsg.Flats))
+	alreadyRelisted := make(map[int64]struct{})
+	for i := range oldMsg.Flats {
+		oldIDs[oldMsg.Flats[i].ID] = struct{}{}
+		if oldMsg.Flats[i].RelistedFrom != 0 {
+			alreadyRelisted[oldMsg.Flats[i].RelistedFrom] = struct{}{}
+		}
+	}
+
+	// only the flats which disappeared can be relisted
+	gone := make(map[string][]Flat)
+	for _, flat := range oldMsg.Flats {
+		if _, ok := newIDs[flat.ID]; ok {
+			continue
+		}
+		if _, ok := alreadyRelisted[flat.ID]; ok {
+			continue
+		}
+		key := flat.PhysicalKey()
+		gone[key] = append(gone[key], flat)
+	}
+	if len(gone) == 0 {
+		return
+	}
+
+	// the most recently seen flat goes first
+	for key := range gone {
+		sort.Slice(gone[key], func(i, j int) bool {
+			return gone[key][i].Updated > gone[key][j].Updated
+		})
+	}
+
+	for i := range newMsg.Flats {
+		flat := &newMsg.Flats[i]
+		if _, ok := oldIDs[flat.ID]; ok || flat.RelistedFrom != 0 {
+			continue
+		}
+		key := flat.PhysicalKey()
+		if len(gone[key]) == 0 {
+			continue
+		}
+		flat.RelistedFrom = gone[key][0].ID
+		gone[key] = gone[key][1:]
+	}
+}
@@ -0,0 +1,163 @@
+package flatstorage
+
+import (
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"strings"
+)
+
+const (
+	// RepricingMinShare share of comparable flats that must change price between two polls to call it a wave
+	RepricingMinShare = 0.3
+	// RepricingMinFlats do not report waves on tiny blocks
+	RepricingMinFlats = 5
+)
+
+// RepricingSummary aggregated price changes in a block between two polls
+type RepricingSummary struct {
+	BlockName string
+	BlockSlug string
+
+	Compared int // flats present in both polls
+	Repriced int // flats with changed price
+
+	Rooms []RoomsRepricing // sorted by number of rooms
+
+	compared []comparedFlat // to scope the summary to bulks, see ForBulks
+}
+
+type RoomsRepricing struct {
+	Rooms        int8
+	Repriced     int
+	MedianChange float64 // in percent, e.g. 3.5 means +3.5%
+}
+
+// comparedFlat a flat present in both polls
+type comparedFlat struct {
+	bulk   string // short name, see NormalizeBulk
+	rooms  int8
+	change float64 // in percent, 0 if the price did not change
+}
+
+// DetectRepricing compare prices of flats known in oldMsg with the fresh ones from newMsg
+func DetectRepricing(oldMsg, newMsg *MessageData) *RepricingSummary {
+	if oldMsg == nil || newMsg == nil || len(newMsg.Flats) == 0 {
+		return nil
+	}
+
+	oldPrices := make(map[int64]int64, len(oldMsg.Flats))
+	for _, flat := range oldMsg.Flats {
+		oldPrices[flat.ID] = flat.Price
+	}
+
+	var compared []comparedFlat
+	seen := make(map[int64]struct{}, len(newMsg.Flats))
+	for _, flat := range newMsg.Flats {
+		if _, ok := seen[flat.ID]; ok {
+			continue
+		}
+		seen[flat.ID] = struct{}{}
+
+		oldPrice, ok := oldPrices[flat.ID]
+		if !ok || oldPrice == 0 {
+			continue
+		}
+		compared = append(compared, comparedFlat{
+			bulk:   flat.BulkShortName(),
+			rooms:  flat.Rooms,
+			change: 100 * float64(flat.Price-oldPrice) / float64(oldPrice),
+		})
+	}
+
+	return summarizeRepricing(newMsg.Flats[0].BlockName, newMsg.Flats[0].BlockSlug, compared)
+}
+
+func summarizeRepricing(blockName, blockSlug string, compared []comparedFlat) *RepricingSummary {
+	summary := &RepricingSummary{
+		BlockName: blockName,
+		BlockSlug: blockSlug,
+		Compared:  len(compared),
+		compared:  compared,
+	}
+
+	changesByRooms := make(map[int8][]float64)
+	for _, flat := range compared {
+		if flat.change == 0 {
+			continue
+		}
+		summary.Repriced += 1
+		changesByRooms[flat.rooms] = append(changesByRooms[flat.rooms], flat.change)
+	}
+
+	for _, rooms := range util.SortedKeys(changesByRooms) {
+		summary.Rooms = append(summary.Rooms, RoomsRepricing{
+			Rooms:        rooms,
+			Repriced:     len(changesByRooms[rooms]),
+			MedianChange: util.Median(changesByRooms[rooms]),
+		})
+	}
+
+	return summary
+}
+
+// ForBulks the summary of the flats from the given bulks only; empty bulks means the whole block
+func (s *RepricingSummary) ForBulks(bulks []string) *RepricingSummary {
+	if s == nil || len(bulks) == 0 {
+		return s
+	}
+
+	scope := bulkScope(bulks)
+	var compared []comparedFlat
+	for _, flat := range s.compared {
+		if _, ok := scope[flat.bulk]; ok {
+			compared = append(compared, flat)
+		}
+	}
+	return summarizeRepricing(s.BlockName, s.BlockSlug, compared)
+}
+
+// Share of compared flats that were repriced
+func (s *RepricingSummary) Share() float64 {
+	if s == nil || s.Compared == 0 {
+		return 0
+	}
+	return float64(s.Repriced) / float64(s.Compared)
+}
+
+// IsWave true if the whole block was repriced at once
+func (s *RepricingSummary) IsWave() bool {
+	if s == nil {
+		return false
+	}
+	return s.Repriced >= RepricingMinFlats && s.Share() >= RepricingMinShare
+}
+
+// String example:
+// 📈 Repricing in Второй Нагатинский: 120 of 300 flats (40%)
+// 1r: 40 flats, median +3.2%
+// 2r: 80 flats, median +2.9%
+func (s *RepricingSummary) String() string {
+	return s.Format(i18n.En)
+}
+
+func (s *RepricingSummary) Format(lang i18n.Lang) string {
+	if s == nil {
+		return ""
+	}
+
+	var medians []float64
+	for _, rooms := range s.Rooms {
+		medians = append(medians, rooms.MedianChange)
+	}
+	sign := "📈"
+	if util.Median(medians) < 0 {
+		sign = "📉"
+	}
+
+	res := []string{i18n.T(lang, "repricing.header", sign, s.BlockName, s.Repriced, s.Compared, 100*s.Share())}
+	for _, rooms := range s.Rooms {
+		res = append(res, i18n.T(lang, "repricing.rooms", rooms.Rooms, i18n.N(lang, "flats", rooms.Repriced), rooms.MedianChange))
+	}
+
+	return strings.Join(res, "\n")
+}
@@ -0,0 +1,67 @@
+package flatstorage
+
+import (
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"sort"
+	"strings"
+)
+
+const DefaultSortKey = "price"
+
+// FlatSort example: "meterprice" cheapest per m2 first, "-area" largest first
+type FlatSort struct {
+	Field string
+	Desc  bool
+}
+
+// ParseFlatSort example: "-m2price" => {meterprice, desc}, empty means DefaultSortKey
+func ParseFlatSort(s string) (FlatSort, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	res := FlatSort{Field: DefaultSortKey}
+	if len(s) == 0 {
+		return res, nil
+	}
+	if strings.HasPrefix(s, "-") {
+		res.Desc, s = true, strings.TrimPrefix(s, "-")
+	}
+	if alias, ok := filterFieldAliases[s]; ok {
+		s = alias
+	}
+	if _, ok := numericFilterFields[s]; !ok && s != "created" {
+		return FlatSort{}, i18n.NewError("sort.unknown", s)
+	}
+	res.Field = s
+	return res, nil
+}
+
+func (s FlatSort) String() string {
+	if s.Desc {
+		return "-" + s.Field
+	}
+	return s.Field
+}
+
+func (s FlatSort) less(a, b *Flat) bool {
+	if s.Field == "created" {
+		return a.Created < b.Created
+	}
+	value := numericFilterFields[s.Field]
+	return value(a) < value(b)
+}
+
+// Sort in place, ties are broken by price and ID to keep the order stable across pages
+func (md *MessageData) Sort(s FlatSort) {
+	if md == nil {
+		return
+	}
+	sort.SliceStable(md.Flats, func(i, j int) bool {
+		a, b := &md.Flats[i], &md.Flats[j]
+		if s.less(a, b) != s.less(b, a) {
+			return s.less(a, b) != s.Desc
+		}
+		if a.Price != b.Price {
+			return a.Price < b.Price
+		}
+		return a.ID < b.ID
+	})
+}
@@ -0,0 +1,198 @@
+package flatstorage
+
+import (
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RoomStats prices of the flats on sale with the same number of rooms
+type RoomStats struct {
+	Rooms    int8
+	Count    int
+	Free     int
+	Reserved int
+
+	MinPrice    int64
+	MedianPrice int64
+	MaxPrice    int64
+
+	MinMeterPrice    int64
+	MedianMeterPrice int64
+	MaxMeterPrice    int64
+}
+
+type BlockStats struct {
+	BlockName string
+	Total     int // on sale
+	Free      int
+	Reserved  int
+	Rooms     []RoomStats // by the number of rooms
+
+	MinMeterPrice    int64
+	MedianMeterPrice int64
+	SettlementFrom   string // earliest settlement date of the flats on sale, 2025-06-15
+	SettlementTo     string
+
+	Sold            int     // no longer on sale
+	AvgDaysOnMarket float64 // of the sold flats, from Created to Updated
+	AvgDaysListed   float64 // of the flats on sale, from Created to now
+}
+
+// DaysOnMarket days from Created to Updated
+func (f *Flat) DaysOnMarket() (float64, bool) {
+	created, err := time.Parse(time.RFC3339, f.Created)
+	if err != nil {
+		return 0, false
+	}
+	updated, err := time.Parse(time.RFC3339, f.Updated)
+	if err != nil {
+		return 0, false
+	}
+	return updated.Sub(created).Hours() / 24, true
+}
+
+// Stats flats on sale are the ones found by the last poll, the rest are considered sold
+func (md *MessageData) Stats(now time.Time) BlockStats {
+	res := BlockStats{}
+	if md == nil {
+		return res
+	}
+	latest := md.LatestUpdate()
+
+	byRooms := make(map[int8][]*Flat)
+	var daysOnMarket, daysListed []float64
+	var meterPrices []int64
+	for i := range md.Flats {
+		flat := &md.Flats[i]
+		if len(res.BlockName) == 0 {
+			res.BlockName = flat.BlockName
+		}
+
+		if !flat.FoundBy(latest) {
+			res.Sold++
+			if days, ok := flat.DaysOnMarket(); ok {
+				daysOnMarket = append(daysOnMarket, days)
+			}
+			continue
+		}
+
+		res.Total++
+		if flat.Status == "reserve" {
+			res.Reserved++
+		} else {
+			res.Free++
+		}
+		if created, err := time.Parse(time.RFC3339, flat.Created); err == nil {
+			daysListed = append(daysListed, now.Sub(created).Hours()/24)
+		}
+		byRooms[flat.Rooms] = append(byRooms[flat.Rooms], flat)
+		if flat.MeterPrice > 0 {
+			meterPrices = append(meterPrices, flat.MeterPrice)
+		}
+		if len(flat.SettlementDate) > 0 {
+			if len(res.SettlementFrom) == 0 || flat.SettlementDate < res.SettlementFrom {
+				res.SettlementFrom = flat.SettlementDate
+			}
+			if flat.SettlementDate > res.SettlementTo {
+				res.SettlementTo = flat.SettlementDate
+			}
+		}
+	}
+
+	for _, rooms := range util.SortedKeys(byRooms) {
+		res.Rooms = append(res.Rooms, roomStats(rooms, byRooms[rooms]))
+	}
+	if len(meterPrices) > 0 {
+		sort.Slice(meterPrices, func(i, j int) bool { return meterPrices[i] < meterPrices[j] })
+		res.MinMeterPrice, res.MedianMeterPrice = meterPrices[0], int64(util.Median(meterPrices))
+	}
+	res.AvgDaysOnMarket = average(daysOnMarket)
+	res.AvgDaysListed = average(daysListed)
+
+	return res
+}
+
+func roomStats(rooms int8, flats []*Flat) RoomStats {
+	res := RoomStats{Rooms: rooms, Count: len(flats)}
+
+	prices := make([]int64, 0, len(flats))
+	meterPrices := make([]int64, 0, len(flats))
+	for _, flat := range flats {
+		if flat.Status == "reserve" {
+			res.Reserved++
+		} else {
+			res.Free++
+		}
+		prices = append(prices, flat.Price)
+		meterPrices = append(meterPrices, flat.MeterPrice)
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i] < prices[j] })
+	sort.Slice(meterPrices, func(i, j int) bool { return meterPrices[i] < meterPrices[j] })
+
+	res.MinPrice, res.MedianPrice, res.MaxPrice = prices[0], int64(util.Median(prices)), prices[len(prices)-1]
+	res.MinMeterPrice, res.MedianMeterPrice, res.MaxMeterPrice =
+		meterPrices[0], int64(util.Median(meterPrices)), meterPrices[len(meterPrices)-1]
+	return res
+}
+
+func average(arr []float64) float64 {
+	if len(arr) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, x := range arr {
+		sum += x
+	}
+	return sum / float64(len(arr))
+}
+
+// millions example: 12_345_678 => "12.3"
+func millions(price int64) string {
+	return fmt.Sprintf("%.1f", float64(price)/1_000_000)
+}
+
+// thousands example: 334_300 => "334"
+func thousands(price int64) string {
+	return fmt.Sprintf("%.0f", float64(price)/1_000)
+}
+
+func (s BlockStats) String() string {
+	return s.Format(i18n.En)
+}
+
+// Format example:
+// 📊 Второй Нагатинский: 120 flats on sale (100 free, 20 reserved)
+// rooms count free res  price, M         m2, k
+// 1r       30   25   5  9.0/10.2/12.1   290/310/330
+// ...
+// ⏱ Sold: 52, avg 34 days on market
+// Avg time on sale now: 20 days
+func (s BlockStats) Format(lang i18n.Lang) string {
+	res := []string{i18n.T(lang, "stats.header", s.BlockName, s.Total, s.Free, s.Reserved)}
+
+	if len(s.Rooms) > 0 {
+		table := []string{fmt.Sprintf("%-5v %5v %4v %4v  %-15v %v",
+			i18n.T(lang, "stats.col.rooms"), i18n.T(lang, "stats.col.count"), i18n.T(lang, "stats.col.free"),
+			i18n.T(lang, "stats.col.reserved"), i18n.T(lang, "stats.col.price"), i18n.T(lang, "stats.col.meterprice"))}
+		for _, rooms := range s.Rooms {
+			prices := fmt.Sprintf("%v/%v/%v", millions(rooms.MinPrice), millions(rooms.MedianPrice), millions(rooms.MaxPrice))
+			meterPrices := fmt.Sprintf("%v/%v/%v",
+				thousands(rooms.MinMeterPrice), thousands(rooms.MedianMeterPrice), thousands(rooms.MaxMeterPrice))
+			table = append(table, fmt.Sprintf("%-5v %5v %4v %4v  %-15v %v",
+				i18n.T(lang, "stats.rooms", rooms.Rooms), rooms.Count, rooms.Free, rooms.Reserved, prices, meterPrices))
+		}
+		res = append(res, "<pre>"+strings.Join(table, "\n")+"</pre>")
+	}
+
+	if s.Sold > 0 {
+		res = append(res, i18n.T(lang, "stats.sold", s.Sold, s.AvgDaysOnMarket))
+	}
+	if s.Total > 0 {
+		res = append(res, i18n.T(lang, "stats.listed", s.AvgDaysListed))
+	}
+	return strings.Join(res, "\n")
+}
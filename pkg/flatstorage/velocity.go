@@ -0,0 +1,200 @@
+package flatstorage
+
+import (
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"sort"
+	"strings"
+	"time"
+)
+
+const FastestLayoutsLimit = 5
+
+// SellThroughWindows rolling windows in days
+var SellThroughWindows = []int{7, 30}
+
+// SellThrough of a group of flats over the window
+type SellThrough struct {
+	Key     string
+	Example *Flat // any flat of the group, e.g. to describe the layout
+	Listed  int   // on sale at any moment of the window
+	Sold    int   // left the market during the window
+	AvgDays float64
+}
+
+// Rate share of the listed flats sold during the window
+func (s SellThrough) Rate() float64 {
+	if s.Listed == 0 {
+		return 0
+	}
+	return float64(s.Sold) / float64(s.Listed)
+}
+
+func SellThroughByRooms(f *Flat) string {
+	return fmt.Sprintf("%v", f.Rooms)
+}
+
+func SellThroughByBulk(f *Flat) string {
+	return f.BulkShortName()
+}
+
+func SellThroughByLayout(f *Flat) string {
+	return f.PlanURL
+}
+
+func SellThroughTotal(*Flat) string {
+	return ""
+}
+
+// SellThrough grouped by the key over the last days, sorted by key; flats with empty keys are skipped
+// unless all keys are empty
+func (md *MessageData) SellThrough(now time.Time, days int, key func(f *Flat) string) []SellThrough {
+	start := now.AddDate(0, 0, -days)
+	latest := md.LatestUpdate() // the flats not found by the last poll are sold
+
+	groups := make(map[string]*SellThrough)
+	daysSum := make(map[string]float64)
+	for i := range md.Flats {
+		flat := &md.Flats[i]
+		groupKey := key(flat)
+		created, err := time.Parse(time.RFC3339, flat.Created)
+		if err != nil || created.After(now) {
+			continue
+		}
+		updated, err := time.Parse(time.RFC3339, flat.Updated)
+		if err != nil || updated.Before(start) {
+			continue
+		}
+
+		group, ok := groups[groupKey]
+		if !ok {
+			group = &SellThrough{Key: groupKey, Example: flat}
+			groups[groupKey] = group
+		}
+		group.Listed++
+		if !flat.FoundBy(latest) {
+			group.Sold++
+			if days, ok := flat.DaysOnMarket(); ok {
+				daysSum[groupKey] += days
+			}
+		}
+	}
+
+	res := make([]SellThrough, 0, len(groups))
+	for groupKey, group := range groups {
+		if len(groupKey) == 0 && len(groups) > 1 {
+			continue
+		}
+		if group.Sold > 0 {
+			group.AvgDays = daysSum[groupKey] / float64(group.Sold)
+		}
+		res = append(res, *group)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Key < res[j].Key
+	})
+	return res
+}
+
+// FastestLayouts sold layouts with the shortest average days on market
+func (md *MessageData) FastestLayouts(now time.Time, days int) []SellThrough {
+	var res []SellThrough
+	for _, layout := range md.SellThrough(now, days, SellThroughByLayout) {
+		if layout.Sold > 0 && len(layout.Key) > 0 {
+			res = append(res, layout)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].AvgDays != res[j].AvgDays {
+			return res[i].AvgDays < res[j].AvgDays
+		}
+		return res[i].Sold > res[j].Sold
+	})
+	if len(res) > FastestLayoutsLimit {
+		res = res[:FastestLayoutsLimit]
+	}
+	return res
+}
+
+// MarketReport sell-through of the block over the rolling windows
+type MarketReport struct {
+	BlockName string
+	Days      int // the window of the breakdowns
+	Total     []SellThrough
+	Rooms     []SellThrough
+	Bulks     []SellThrough
+	Layouts   []SellThrough
+}
+
+// MarketReport totals for each of SellThroughWindows, breakdowns for the longest one
+func (md *MessageData) MarketReport(now time.Time) MarketReport {
+	days := SellThroughWindows[len(SellThroughWindows)-1]
+	res := MarketReport{
+		Days:    days,
+		Rooms:   md.SellThrough(now, days, SellThroughByRooms),
+		Bulks:   md.SellThrough(now, days, SellThroughByBulk),
+		Layouts: md.FastestLayouts(now, days),
+	}
+	if len(md.Flats) > 0 {
+		res.BlockName = md.Flats[0].BlockName
+	}
+	for _, window := range SellThroughWindows {
+		total := md.SellThrough(now, window, SellThroughTotal)
+		if len(total) == 0 {
+			total = []SellThrough{{}}
+		}
+		total[0].Key = fmt.Sprintf("%v", window)
+		res.Total = append(res.Total, total[0])
+	}
+	return res
+}
+
+// sellThroughLine example: "2r: sold 5 of 40 (12%), avg 21 days"
+func sellThroughLine(lang i18n.Lang, label string, s SellThrough) string {
+	res := i18n.T(lang, "report.line", label, s.Sold, s.Listed, s.Rate()*100)
+	if s.Sold > 0 {
+		res += i18n.T(lang, "report.days", s.AvgDays)
+	}
+	return res
+}
+
+func (r MarketReport) String() string {
+	return r.Format(i18n.En)
+}
+
+// Format example:
+// 📈 Второй Нагатинский, sell-through
+// 7 days: sold 3 of 120 (2%), avg 25 days
+// 30 days: sold 15 of 130 (12%), avg 31 days
+// By rooms, 30 days:
+// 1r: sold 5 of 40 (12%), avg 21 days
+// ...
+// Fastest layouts, 30 days:
+// 2r, 54.3m2 (plan link): sold 3 of 4 (75%), avg 9 days
+func (r MarketReport) Format(lang i18n.Lang) string {
+	res := []string{i18n.T(lang, "report.header", r.BlockName)}
+	for _, total := range r.Total {
+		res = append(res, sellThroughLine(lang, i18n.T(lang, "report.window", total.Key), total))
+	}
+
+	if len(r.Rooms) > 0 {
+		res = append(res, "", i18n.T(lang, "report.rooms", r.Days))
+		for _, rooms := range r.Rooms {
+			res = append(res, sellThroughLine(lang, i18n.T(lang, "stats.rooms", rooms.Key), rooms))
+		}
+	}
+	if len(r.Bulks) > 1 {
+		res = append(res, "", i18n.T(lang, "report.bulks", r.Days))
+		for _, bulk := range r.Bulks {
+			res = append(res, sellThroughLine(lang, bulk.Key, bulk))
+		}
+	}
+	if len(r.Layouts) > 0 {
+		res = append(res, "", i18n.T(lang, "report.layouts", r.Days))
+		for _, layout := range r.Layouts {
+			label := i18n.T(lang, "report.layout", layout.Key, layout.Example.Rooms, fmt.Sprintf("%.1f", layout.Example.Area))
+			res = append(res, sellThroughLine(lang, label, layout))
+		}
+	}
+	return strings.Join(res, "\n")
+}
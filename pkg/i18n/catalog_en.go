@@ -0,0 +1,341 @@
+package i18n
+
+var en = Catalog{
+	"lang.name": "English",
+
+	// flatstorage
+	// built-in text/template of a flat line (see flatstorage.FlatView), data/flat_templates.json overrides it
+	"flat.template": `{{if .Has "bulk"}}{{.Bulk}}: {{end}}<a href="{{.URL}}">` +
+		`{{if and (.Has "rooms") (.Has "area")}}{{.Rooms}}r, {{.Area}}m2{{else if .Has "rooms"}}{{.Rooms}}r{{else if .Has "area"}}{{.Area}}m2{{else}}#{{.ID}}{{end}}</a>` +
+		`{{if .Has "price"}}, {{.Price}}{{end}}{{if .Has "meterprice"}}, {{.MeterPrice}}{{end}}` +
+		`{{if .Has "floor"}}, f{{.Floor}}{{end}}{{if and (.Has "created") .Created}}, since {{.Created}}{{end}}{{.Marks}}`,
+	"price.full":             "%vR",
+	"price.millions":         "%vmR",
+	"price.meter":            "%vR/m2",
+	"flat.deal":              "💰 -%v%% vs similar",
+	"flats.header":           "%v in %v:",
+	"flats.header.relisted":  "%v new and %v relisted flats in %v:",
+	"flats.new.one":          "%v new flat",
+	"flats.new.many":         "%v new flats",
+	"flats.one":              "%v flat",
+	"flats.many":             "%v flats",
+	"complexes.one":          "%v complex",
+	"complexes.many":         "%v complexes",
+	"repricing.header":       "%v Repricing in %v: %v of %v flats (%.0f%%)",
+	"repricing.rooms":        "%vr: %v, median %+.1f%%",
+	"digest.header":          "📬 Digest: %v in %v",
+	"blocks.new":             "#NewPikProjects\n\n%v\n\nTo follow new updates, write @pik_checker_bot",
+	"area.from.point":        "📍 %.1f km from your point",
+	"button.unknown":         "Unknown button",
+	"button.expired":         "The button has expired, run the command again",
+	"button.subscribed":      "Subscribed to %v",
+	"button.unsubscribed":    "Unsubscribed from %v",
+	"button.sub.already":     "Already subscribed to %v",
+	"button.unsub.already":   "Not subscribed to %v",
+	"button.sub.failed":      "Failed to change the subscription to %v, try again later",
+	"button.subscribe":       "🔔 Subscribe",
+	"button.unsubscribe":     "🔕 Unsubscribe",
+	"button.dump":            "📋 All flats",
+	"button.rooms":           "%vr",
+	"button.prev":            "⬅️ Prev",
+	"button.next":            "Next ➡️",
+	"button.only.subscribed": "✅ Only subscribed",
+	"button.all.complexes":   "📃 All complexes",
+	"button.plans":           "🖼 Plans",
+	"button.group.layout":    "🗂 By layout",
+	"button.group.bulk":      "🏢 By bulk",
+	"button.group.rooms":     "🚪 By rooms",
+
+	// blocks and subscriptions
+	"hello":             "Hello, %v!",
+	"slug.usage":        "usage: /%v [code]\n\nTo get [code] of any complex type /%v",
+	"dump.usage":        "usage: /%v [code] [filter] [group=layout|bulk|rooms], e.g. /%v %v rooms=2 price<15m\nsame layouts in one line: /%v %v group=layout",
+	"dump.empty":        "No known flats for complex %v",
+	"dump.empty.filter": "No known flats for complex %v matching %v",
+	"bulks.unknown":     "Unknown bulks in %v: %v",
+	"bulks.hint":        "Bulks in %v: %v\nTo subscribe to some of them only: /%v %v %v",
+	"bulks.scope":       " (bulks %v)",
+	"bulks.scope.all":   " (whole complex)",
+	"bulks.empty":       "No known flats for complex %v yet, try /%v_%v first",
+	"sub.already":       "You are already subscribed to complex %v%v.\nTo view all flats: /%v_%v",
+	"sub.change.failed": "Something went wrong while changing subscription to %v:\nerror: %v",
+	"sub.changed":       "Your subscription to %v is changed%v.\nTo unsubscribe, click here: /%v_%v",
+	"sub.failed":        "Something went wrong while subscribing to %v:\nerror: %v\nYou can try again later with /%v_%v",
+	"sub.done": "You are now subscribed to new flats from: %v%v.\n" +
+		"To unsubscribe, click here: /%v_%v\n" +
+		"To get all known flats click here: /%v_%v",
+	"unsub.already": "You are not currently subscribed to complex %v.\n" +
+		"To subscribe: /%v_%v\n" +
+		"To view all flats: /%v_%v",
+	"unsub.failed": "Something went wrong while unsubscribing from %v:\nerror: %v\nYou might need to try again later with /%v_%v",
+	"unsub.done": "You were unsubscribed from: %v.\n" +
+		"To subscribe again, click here: /%v_%v\n" +
+		"To get all known flats click here: /%v_%v",
+
+	// list and search
+	"list.header":            "List of known complexes",
+	"list.header.subscribed": "Your subscriptions",
+	"list.matching":          "matching \"%v\"",
+	"list.page":              "page %v/%v",
+	"list.empty":             "Nothing found. To search: /%v [text]",
+	"find.usage":             "usage: /%v [text], e.g. /%v нагатинский\nAdd \"sub\" to search among your subscriptions only",
+
+	// areas
+	"area.usage.regions": "usage: /%v [name]\n\nKnown regions:\n%v",
+	"area.usage.metro":   "usage: /%v [name]\n\nKnown metro stations:\n%v",
+	"area.already":       "You are already subscribed to %v.\nTo unsubscribe: /%v",
+	"area.failed":        "Something went wrong while subscribing to %v:\nerror: %v",
+	"area.try.again":     "You can try again later with /%v",
+	"area.done": "You are now subscribed to new flats in %v.\n" +
+		"Complexes covered now:\n%v\n\n" +
+		"New complexes will be added automatically.\n" +
+		"To unsubscribe: /%v",
+	"area.unsub.already": "You are not subscribed to %v.\nTo subscribe: /%v",
+	"area.unsub.done":    "You were unsubscribed from %v.\nTo subscribe again: /%v",
+	"area.list":          "Subscribed areas: %v",
+	"area.radius":        "%v km around %.5f,%.5f",
+	"area.distance":      "(%.1f km)",
+	"near.location": "Got your location: %.5f,%.5f\n" +
+		"To subscribe to all complexes within the radius send /%v [km], e.g. /%v %v",
+	"near.usage": "usage: send your location (📎 => Location), then /%v [km]\n" +
+		"or: /%v [latitude] [longitude] [km], e.g. /%v 55.6836 37.6217 %v",
+	"near.unsub.already": "You had no radius subscriptions.\nTo subscribe: /%v",
+	"near.unsub.done":    "You were unsubscribed from:\n%v",
+
+	// flat card
+	"card.usage":         "usage: /%v [id], e.g. /%v 819556",
+	"card.not.found":     "Flat %v not found",
+	"card.title":         "🏠 <a href=\"%v\">Flat %v</a>",
+	"card.block":         "%v, bulk %v",
+	"card.params":        "%vr, %vm2, floor %v/%v",
+	"card.price":         "💰 %vR (%vR/m2)",
+	"card.sold":          "❌ No longer on sale",
+	"card.reserve":       "🔒 Reserved",
+	"card.relisted":      "♻️ Relisted, was flat %v",
+	"card.ceiling":       "Ceiling: %.2f m",
+	"card.finish":        "Finish: %v",
+	"card.windows":       "Windows: %v",
+	"card.benefits":      "Benefits: %v",
+	"card.history":       "Price history:",
+	"card.history.point": "%v: %vR",
+	"finish.1":           "with finishing",
+	"finish.2":           "white box",
+
+	// floor plans
+	"plans.usage":     "usage: /%v [code] [filter], e.g. /%v %v rooms=2",
+	"plans.empty":     "No known floor plans for complex %v",
+	"plans.more.one":  "+%v more flat with this layout",
+	"plans.more.many": "+%v more flats with this layout",
+
+	// settings
+	"delivery.help":    "Current delivery mode: %v\n\n%v\ne.g. /%v daily 09:00\nTo change timezone: /%v [name], e.g. /%v %v",
+	"delivery.usage":   "usage: /%v instant|hourly|daily [HH:MM]|weekly [mon..sun] [HH:MM]",
+	"delivery.failed":  "Something went wrong while changing delivery mode:\nerror: %v",
+	"delivery.changed": "Delivery mode: %v",
+	"delivery.next":    "Next digest: %v",
+	"delivery.instant": "instant",
+	"delivery.hourly":  "hourly",
+	"delivery.daily":   "daily at %v (%v)",
+	"delivery.weekly":  "weekly on %v at %v (%v)",
+	"weekday.0":        "Sunday",
+	"weekday.1":        "Monday",
+	"weekday.2":        "Tuesday",
+	"weekday.3":        "Wednesday",
+	"weekday.4":        "Thursday",
+	"weekday.5":        "Friday",
+	"weekday.6":        "Saturday",
+	"timezone.help":    "Current timezone: %v\n\nusage: /%v [name], e.g. /%v %v",
+	"timezone.changed": "Timezone: %v\nDelivery mode: %v",
+	"quiet.usage": "usage: /%v HH:MM-HH:MM [hold|silent] [timezone] or /%v off\n" +
+		"hold: messages are delivered when the quiet hours end\n" +
+		"silent: messages are delivered without notification\n" +
+		"e.g. /%v 23:00-08:00 hold %v",
+	"quiet.changed": "Quiet hours: %v",
+	"quiet.failed":  "Something went wrong while changing quiet hours:\nerror: %v",
+	"quiet.off":     "off",
+	"quiet.hold":    "hold",
+	"quiet.silent":  "silent",
+	"lang.help":     "Current language: %v\n\nusage: /%v ru|en|auto, e.g. /%v en",
+	"lang.changed":  "Language: %v",
+
+	// watchlist
+	"watch.usage":      "usage: /%v [id], e.g. /%v 819556",
+	"watch.already":    "Flat %v is already in your watchlist, /%v_%v to stop watching",
+	"watch.failed":     "Failed to watch flat %v: %v",
+	"watch.done":       "👁 Watching flat %v: price and status changes will be sent here. /%v_%v to stop, /%v for the whole list",
+	"unwatch.already":  "Flat %v is not in your watchlist, /%v_%v to watch it",
+	"unwatch.done":     "Flat %v removed from your watchlist, /%v_%v to watch it again",
+	"watch.gone":       "❌ Watched flat is no longer on sale",
+	"watch.back":       "♻️ Watched flat is back on sale",
+	"watch.price":      "💰 Watched flat price: %vR → %vR (%+.1f%%)",
+	"watch.status":     "🔄 Watched flat status: %v → %v",
+	"watchlist.empty":  "Your watchlist is empty\n\nusage: /%v [id], e.g. /%v 819556",
+	"watchlist.header": "👁 Watched flats (%v):",
+	"watchlist.gone":   "❌ not on sale",
+	"status.free":      "free",
+	"status.reserve":   "reserved",
+	"button.watch":     "👁 Watch",
+	"button.unwatch":   "🙈 Unwatch",
+
+	// hidden flats
+	"hide.usage":          "usage: /%v [id] [layout], e.g. /%v 819556 or /%v 819556 layout to hide all flats with its floor plan",
+	"hide.no.layout":      "Flat %v has no known floor plan",
+	"hide.failed":         "Failed to save hidden flats: %v",
+	"hide.done":           "🙈 Flat %v is hidden, /%v_%v to show it again",
+	"hide.layout.done":    "🙈 Flats with the layout of %v are hidden, /%v to review",
+	"unhide.usage":        "usage: /%v [id], e.g. /%v 819556",
+	"unhide.not.hidden":   "Flat %v is not hidden",
+	"unhide.done":         "Flat %v is shown again",
+	"unhide.layout.usage": "usage: /%v [number], see the numbers in /%v",
+	"unhide.layout.done":  "Layout %v is shown again",
+	"hidden.empty":        "No hidden flats\n\nusage: /%v [id] [layout], e.g. /%v 819556 or /%v 819556 layout",
+	"hidden.flats":        "🙈 Hidden flats (%v):",
+	"hidden.layouts":      "🙈 Hidden layouts (%v):",
+	"hidden.layout.link":  "<a href=\"%v\">layout</a>",
+	"button.hide":         "🚫 Hide",
+	"button.unhide":       "👀 Show again",
+	"button.hide.layout":  "🚫 Hide layout",
+
+	// export
+	"export.usage":        "usage: /%v [code] [csv|xlsx] [filter], e.g. /%v %v xlsx rooms=2",
+	"export.caption.one":  "%v flat in %v",
+	"export.caption.many": "%v flats in %v",
+
+	// statistics
+	"stats.header":         "📊 <b>%v</b>: %v flats on sale (%v free, %v reserved)",
+	"stats.col.rooms":      "rooms",
+	"stats.col.count":      "count",
+	"stats.col.free":       "free",
+	"stats.col.reserved":   "res",
+	"stats.col.price":      "price, M",
+	"stats.col.meterprice": "m2, k",
+	"stats.rooms":          "%vr",
+	"stats.sold":           "⏱ Sold: %v, avg %.0f days on market",
+	"stats.listed":         "Avg time on sale now: %.0f days",
+
+	// charts
+	"chart.block":  "📈 <b>%v</b>: median price per m2 by number of rooms, last %v days",
+	"chart.flat":   "📈 Flat %v (%v): price history",
+	"chart.empty":  "No price history yet",
+	"button.chart": "📈 Chart",
+
+	// deals
+	"deals.help":    "Deals only: %v\n\nIn the deals only mode you get notified only about the flats at least %.0f%% cheaper per m2 than the similar ones (same rooms, similar area and floor) of the complex\n\nusage: /%v on|off, e.g. /%v on",
+	"deals.changed": "Deals only: %v",
+	"deals.on":      "on",
+	"deals.off":     "off",
+
+	// flat lists
+	"display.usage": "usage: /%v [sort=key] [columns=list] [millions=on|off] or /%v reset\n" +
+		"sort keys: price, meterprice, area, floor, rooms, created; -price for the most expensive first\n" +
+		"columns: %v\n" +
+		"e.g. /%v sort=-area columns=rooms,area,price,meterprice millions=on",
+	"display.changed": "Flat lists: %v",
+
+	// market report
+	"report.header":     "📈 <b>%v</b>: sell-through",
+	"report.window":     "%v days",
+	"report.line":       "%v: sold %v of %v (%.0f%%)",
+	"report.days":       ", avg %.0f days on market",
+	"report.rooms":      "By rooms, %v days:",
+	"report.bulks":      "By bulks, %v days:",
+	"report.layouts":    "Fastest selling layouts, %v days:",
+	"report.layout":     "<a href=\"%v\">%vr, %vm2</a>",
+	"report.weekly.on":  "📅 Weekly market report of the subscribed complexes: on, every Monday at %v",
+	"report.weekly.off": "📅 Weekly market report: off",
+
+	// search
+	"search.usage":   "usage: /%v [filter] [sort=key], e.g. /%v rooms=2 price<15m metro=Нагатинская sort=meterprice\n\nsort keys: price, meterprice, area, floor, rooms, created; -price for the most expensive first",
+	"search.empty":   "No flats found: %v",
+	"search.header":  "🔎 %v: %v found, page %v/%v",
+	"search.expired": "Search expired, run /%v again",
+
+	// comparison
+	"compare.usage":      "usage: /%v [code] [code]..., up to %v complexes, e.g. /%v 2ngt utnv\n\ncodes: /%v",
+	"compare.unknown":    "Unknown complexes: %v",
+	"compare.header":     "⚖️ Flats on sale, count: min/median price in millions",
+	"compare.metro":      "metro",
+	"compare.total":      "flats",
+	"compare.meterprice": "m2, k",
+	"compare.settlement": "keys",
+
+	// budget
+	"budget.usage":            "usage: /%v [budget] [filter] [downpayment=amount] [sort=key], e.g. /%v 14m rooms=2 downpayment=3m\n\nflats of the subscribed complexes, the biggest first; sort=meterprice for the cheapest per m2",
+	"budget.no.subscriptions": "No subscriptions yet, /%v [code] to subscribe, see /%v",
+	"budget.empty":            "No flats up to %vR%v in the subscribed complexes",
+	"budget.header":           "💰 Up to %vR%v: %v in %v complexes",
+	"budget.found.one":        "%v flat",
+	"budget.found.many":       "%v flats",
+	"budget.block":            "<b>%v</b> (%v) /%v_%v",
+	"budget.payment":          "≈%vR/mo",
+	"budget.mortgage":         "Mortgage estimate: %v",
+
+	// mortgage
+	"mortgage.usage": "usage: /%v [family|it|market] [rate=%%] [years=N] [down=amount|%%] [annuity|diff] [price]\n" +
+		"family, it: subsidized programs, market: %.0f%%\n" +
+		"e.g. /%v family years=20 down=20%%\n/%v 15m to calculate for the price, /%v off to hide the payments",
+	"mortgage.current":            "🏦 Mortgage: %v",
+	"mortgage.changed":            "🏦 Mortgage: %v\n\nThe monthly payment is shown on flat cards (/%v) and in /%v",
+	"mortgage.off":                "🏦 Mortgage payments are not shown, /%v [parameters] to set up",
+	"mortgage.params":             "down payment %v, %.1f%%, %v years, %v",
+	"mortgage.annuity":            "annuity",
+	"mortgage.diff":               "differentiated",
+	"mortgage.preset.family":      "Family mortgage",
+	"mortgage.preset.it":          "IT mortgage",
+	"mortgage.preset.market":      "Market rate",
+	"mortgage.max.loan":           "the rate applies up to %vR, the rest at %.1f%%",
+	"mortgage.down.amount":        "%vR",
+	"mortgage.down.percent":       "%.0f%%",
+	"mortgage.payment":            "🏦 ≈%vR/mo",
+	"mortgage.payment.diff":       "🏦 %v→%vR/mo",
+	"mortgage.price":              "💰 Price: %vR",
+	"mortgage.loan":               "Down payment: %vR, loan: %vR",
+	"mortgage.monthly":            "Monthly payment: %vR",
+	"mortgage.monthly.diff":       "Monthly payment: from %vR down to %vR",
+	"mortgage.overpayment":        "Overpayment: %vR",
+	"mortgage.market.rate":        "(market rate)",
+	"mortgage.preset.not.applies": "⚠️ %v needs at least %.0f%% down payment, the whole loan is at the market rate %.1f%%",
+	"mortgage.no.loan":            "The down payment covers the price, no loan needed",
+
+	// grouping
+	"group.header":      "%v in %v, %v:",
+	"group.by.layout":   "by layout (%v)",
+	"group.by.bulk":     "by bulk (%v)",
+	"group.by.rooms":    "by rooms (%v)",
+	"group.layout.name": "%vr, %vm2",
+	"group.layout":      "%v ×%v: %vR, f%v, bulk %v",
+	"group.bulk":        "<b>Bulk %v</b>: %v, %vr, %vm2, %vR, f%v",
+	"group.rooms":       "<b>%vr</b>: %v, %vm2, %vR, f%v",
+
+	// input errors
+	"filter.invalid":           "Invalid filter condition: %v",
+	"filter.string.op":         "Only = and != are supported for %v",
+	"filter.unknown.field":     "Unknown filter field: %v",
+	"number.invalid":           "Invalid number: %v",
+	"sort.unknown":             "Unknown sort key: %v",
+	"group.unknown":            "Unknown grouping: %v",
+	"display.unknown.column":   "Unknown column: %v",
+	"display.invalid.millions": "Invalid millions mode: %v, expected on or off",
+	"display.unknown.option":   "Unknown display option: %v",
+	"clock.invalid":            "Invalid time %q, expected HH:MM",
+	"delivery.empty":           "Delivery mode is empty",
+	"delivery.unknown.weekday": "Unknown weekday: %v",
+	"delivery.unknown":         "Unknown delivery mode: %v",
+	"timezone.empty":           "Timezone is empty",
+	"timezone.unknown":         "Unknown timezone: %v",
+	"quiet.empty":              "Quiet window is empty",
+	"quiet.invalid":            "Invalid quiet window %q, expected HH:MM-HH:MM",
+	"quiet.unknown":            "Unknown quiet mode or timezone: %v",
+	"mortgage.invalid.rate":    "Invalid rate: %v",
+	"mortgage.invalid.term":    "Invalid term: %v",
+	"mortgage.unknown.param":   "Unknown mortgage parameter: %v",
+	"downpayment.invalid":      "Invalid down payment: %v",
+	"search.one.sort":          "Only one sort key is supported",
+	"near.not.number":          "Not a number: %v",
+	"near.no.location":         "Send your location first or pass the coordinates",
+	"near.too.many":            "Too many arguments",
+	"near.invalid.coordinates": "Invalid coordinates: %v,%v",
+	"near.invalid.radius":      "Radius must be within (0, %v] km",
+	"budget.not.set":           "Budget is not set",
+	"budget.invalid":           "Invalid budget: %v",
+}
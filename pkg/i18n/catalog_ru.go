@@ -0,0 +1,347 @@
+package i18n
+
+var ru = Catalog{
+	"lang.name": "Русский",
+
+	// flatstorage
+	// built-in text/template of a flat line (see flatstorage.FlatView), data/flat_templates.json overrides it
+	"flat.template": `{{if .Has "bulk"}}{{.Bulk}}: {{end}}<a href="{{.URL}}">` +
+		`{{if and (.Has "rooms") (.Has "area")}}{{.Rooms}}к, {{.Area}}м²{{else if .Has "rooms"}}{{.Rooms}}к{{else if .Has "area"}}{{.Area}}м²{{else}}#{{.ID}}{{end}}</a>` +
+		`{{if .Has "price"}}, {{.Price}}{{end}}{{if .Has "meterprice"}}, {{.MeterPrice}}{{end}}` +
+		`{{if .Has "floor"}}, эт.{{.Floor}}{{end}}{{if and (.Has "created") .Created}}, с {{.Created}}{{end}}{{.Marks}}`,
+	"price.full":             "%v₽",
+	"price.millions":         "%v млн₽",
+	"price.meter":            "%v₽/м²",
+	"flat.deal":              "💰 -%v%% к похожим",
+	"flats.header":           "%v в %v:",
+	"flats.header.relisted":  "%[3]v: новых квартир %[1]v, снова в продаже %[2]v:",
+	"flats.new.one":          "%v новая квартира",
+	"flats.new.few":          "%v новые квартиры",
+	"flats.new.many":         "%v новых квартир",
+	"flats.one":              "%v квартира",
+	"flats.few":              "%v квартиры",
+	"flats.many":             "%v квартир",
+	"complexes.one":          "%v ЖК",
+	"complexes.few":          "%v ЖК",
+	"complexes.many":         "%v ЖК",
+	"repricing.header":       "%v Переоценка в %v: цены изменились у %v из %v (%.0f%%)",
+	"repricing.rooms":        "%vк: %v, медиана %+.1f%%",
+	"digest.header":          "📬 Дайджест: %v в %v",
+	"blocks.new":             "#NewPikProjects\n\n%v\n\nЧтобы следить за новыми квартирами, напишите @pik_checker_bot",
+	"area.from.point":        "📍 %.1f км от вашей точки",
+	"button.unknown":         "Неизвестная кнопка",
+	"button.expired":         "Кнопка устарела, повторите команду",
+	"button.subscribed":      "Подписка на %v оформлена",
+	"button.unsubscribed":    "Подписка на %v отменена",
+	"button.sub.already":     "Вы уже подписаны на %v",
+	"button.unsub.already":   "Вы не подписаны на %v",
+	"button.sub.failed":      "Не удалось изменить подписку на %v, попробуйте позже",
+	"button.subscribe":       "🔔 Подписаться",
+	"button.unsubscribe":     "🔕 Отписаться",
+	"button.dump":            "📋 Все квартиры",
+	"button.rooms":           "%vк",
+	"button.prev":            "⬅️ Назад",
+	"button.next":            "Вперёд ➡️",
+	"button.only.subscribed": "✅ Только подписки",
+	"button.all.complexes":   "📃 Все ЖК",
+	"button.plans":           "🖼 Планировки",
+	"button.group.layout":    "🗂 По планировкам",
+	"button.group.bulk":      "🏢 По корпусам",
+	"button.group.rooms":     "🚪 По комнатам",
+
+	// blocks and subscriptions
+	"hello":             "Привет, %v!",
+	"slug.usage":        "использование: /%v [код]\n\nЧтобы узнать [код] ЖК, наберите /%v",
+	"dump.usage":        "использование: /%v [код] [фильтр] [group=layout|bulk|rooms], например /%v %v rooms=2 price<15m\nодинаковые планировки одной строкой: /%v %v group=layout",
+	"dump.empty":        "Нет известных квартир в ЖК %v",
+	"dump.empty.filter": "Нет известных квартир в ЖК %v по фильтру %v",
+	"bulks.unknown":     "Неизвестные корпуса в %v: %v",
+	"bulks.hint":        "Корпуса в %v: %v\nЧтобы подписаться только на некоторые из них: /%v %v %v",
+	"bulks.scope":       " (корпуса %v)",
+	"bulks.scope.all":   " (весь ЖК)",
+	"bulks.empty":       "Пока нет известных квартир в ЖК %v, сначала попробуйте /%v_%v",
+	"sub.already":       "Вы уже подписаны на ЖК %v%v.\nВсе квартиры: /%v_%v",
+	"sub.change.failed": "Не удалось изменить подписку на %v:\nошибка: %v",
+	"sub.changed":       "Подписка на %v изменена%v.\nЧтобы отписаться, нажмите: /%v_%v",
+	"sub.failed":        "Не удалось подписаться на %v:\nошибка: %v\nПопробуйте позже: /%v_%v",
+	"sub.done": "Вы подписаны на новые квартиры в: %v%v.\n" +
+		"Чтобы отписаться, нажмите: /%v_%v\n" +
+		"Все известные квартиры: /%v_%v",
+	"unsub.already": "Вы не подписаны на ЖК %v.\n" +
+		"Подписаться: /%v_%v\n" +
+		"Все квартиры: /%v_%v",
+	"unsub.failed": "Не удалось отписаться от %v:\nошибка: %v\nПопробуйте позже: /%v_%v",
+	"unsub.done": "Вы отписались от: %v.\n" +
+		"Чтобы подписаться снова, нажмите: /%v_%v\n" +
+		"Все известные квартиры: /%v_%v",
+
+	// list and search
+	"list.header":            "Список известных ЖК",
+	"list.header.subscribed": "Ваши подписки",
+	"list.matching":          "по запросу «%v»",
+	"list.page":              "стр. %v/%v",
+	"list.empty":             "Ничего не найдено. Поиск: /%v [текст]",
+	"find.usage":             "использование: /%v [текст], например /%v нагатинский\nДобавьте \"sub\", чтобы искать только среди подписок",
+
+	// areas
+	"area.usage.regions": "использование: /%v [название]\n\nИзвестные регионы:\n%v",
+	"area.usage.metro":   "использование: /%v [название]\n\nИзвестные станции метро:\n%v",
+	"area.already":       "Вы уже подписаны на %v.\nОтписаться: /%v",
+	"area.failed":        "Не удалось подписаться на %v:\nошибка: %v",
+	"area.try.again":     "Попробуйте позже: /%v",
+	"area.done": "Вы подписаны на новые квартиры: %v.\n" +
+		"Сейчас это ЖК:\n%v\n\n" +
+		"Новые ЖК добавятся автоматически.\n" +
+		"Отписаться: /%v",
+	"area.unsub.already": "Вы не подписаны на %v.\nПодписаться: /%v",
+	"area.unsub.done":    "Вы отписались от %v.\nПодписаться снова: /%v",
+	"area.list":          "Подписки на районы: %v",
+	"area.radius":        "%v км вокруг %.5f,%.5f",
+	"area.distance":      "(%.1f км)",
+	"near.location": "Получена ваша точка: %.5f,%.5f\n" +
+		"Чтобы подписаться на все ЖК в радиусе, отправьте /%v [км], например /%v %v",
+	"near.usage": "использование: отправьте свою геопозицию (📎 => Геопозиция), затем /%v [км]\n" +
+		"или: /%v [широта] [долгота] [км], например /%v 55.6836 37.6217 %v",
+	"near.unsub.already": "У вас нет подписок по радиусу.\nПодписаться: /%v",
+	"near.unsub.done":    "Вы отписались от:\n%v",
+
+	// flat card
+	"card.usage":         "использование: /%v [id], например /%v 819556",
+	"card.not.found":     "Квартира %v не найдена",
+	"card.title":         "🏠 <a href=\"%v\">Квартира %v</a>",
+	"card.block":         "ЖК %v, корпус %v",
+	"card.params":        "%vк, %vм², этаж %v/%v",
+	"card.price":         "💰 %v₽ (%v₽/м²)",
+	"card.sold":          "❌ Больше не продаётся",
+	"card.reserve":       "🔒 Забронирована",
+	"card.relisted":      "♻️ Снова в продаже, раньше квартира %v",
+	"card.ceiling":       "Потолки: %.2f м",
+	"card.finish":        "Отделка: %v",
+	"card.windows":       "Окна: %v",
+	"card.benefits":      "Акции: %v",
+	"card.history":       "История цены:",
+	"card.history.point": "%v: %v₽",
+	"finish.1":           "чистовая",
+	"finish.2":           "white box",
+
+	// floor plans
+	"plans.usage":     "использование: /%v [код] [фильтр], например /%v %v rooms=2",
+	"plans.empty":     "Нет известных планировок в ЖК %v",
+	"plans.more.one":  "ещё %v квартира с такой планировкой",
+	"plans.more.few":  "ещё %v квартиры с такой планировкой",
+	"plans.more.many": "ещё %v квартир с такой планировкой",
+
+	// settings
+	"delivery.help":    "Текущий режим доставки: %v\n\n%v\nнапример /%v daily 09:00\nСменить часовой пояс: /%v [название], например /%v %v",
+	"delivery.usage":   "использование: /%v instant|hourly|daily [ЧЧ:ММ]|weekly [mon..sun] [ЧЧ:ММ]",
+	"delivery.failed":  "Не удалось изменить режим доставки:\nошибка: %v",
+	"delivery.changed": "Режим доставки: %v",
+	"delivery.next":    "Следующий дайджест: %v",
+	"delivery.instant": "сразу",
+	"delivery.hourly":  "каждый час",
+	"delivery.daily":   "ежедневно в %v (%v)",
+	"delivery.weekly":  "еженедельно, %v в %v (%v)",
+	"weekday.0":        "воскресенье",
+	"weekday.1":        "понедельник",
+	"weekday.2":        "вторник",
+	"weekday.3":        "среда",
+	"weekday.4":        "четверг",
+	"weekday.5":        "пятница",
+	"weekday.6":        "суббота",
+	"timezone.help":    "Текущий часовой пояс: %v\n\nиспользование: /%v [название], например /%v %v",
+	"timezone.changed": "Часовой пояс: %v\nРежим доставки: %v",
+	"quiet.usage": "использование: /%v ЧЧ:ММ-ЧЧ:ММ [hold|silent] [часовой пояс] или /%v off\n" +
+		"hold: сообщения придут после окончания тихих часов\n" +
+		"silent: сообщения придут без звука\n" +
+		"например /%v 23:00-08:00 hold %v",
+	"quiet.changed": "Тихие часы: %v",
+	"quiet.failed":  "Не удалось изменить тихие часы:\nошибка: %v",
+	"quiet.off":     "выключены",
+	"quiet.hold":    "отложить",
+	"quiet.silent":  "без звука",
+	"lang.help":     "Текущий язык: %v\n\nиспользование: /%v ru|en|auto, например /%v ru",
+	"lang.changed":  "Язык: %v",
+
+	// watchlist
+	"watch.usage":      "использование: /%v [id], например /%v 819556",
+	"watch.already":    "Квартира %v уже в списке отслеживания, /%v_%v чтобы перестать следить",
+	"watch.failed":     "Не удалось отследить квартиру %v: %v",
+	"watch.done":       "👁 Следим за квартирой %v: изменения цены и статуса придут сюда. /%v_%v чтобы перестать, /%v весь список",
+	"unwatch.already":  "Квартиры %v нет в списке отслеживания, /%v_%v чтобы следить",
+	"unwatch.done":     "Квартира %v убрана из списка отслеживания, /%v_%v чтобы следить снова",
+	"watch.gone":       "❌ Отслеживаемая квартира снята с продажи",
+	"watch.back":       "♻️ Отслеживаемая квартира снова в продаже",
+	"watch.price":      "💰 Цена отслеживаемой квартиры: %v₽ → %v₽ (%+.1f%%)",
+	"watch.status":     "🔄 Статус отслеживаемой квартиры: %v → %v",
+	"watchlist.empty":  "Список отслеживания пуст\n\nиспользование: /%v [id], например /%v 819556",
+	"watchlist.header": "👁 Отслеживаемые квартиры (%v):",
+	"watchlist.gone":   "❌ не в продаже",
+	"status.free":      "свободна",
+	"status.reserve":   "забронирована",
+	"button.watch":     "👁 Следить",
+	"button.unwatch":   "🙈 Не следить",
+
+	// hidden flats
+	"hide.usage":          "использование: /%v [id] [layout], например /%v 819556 или /%v 819556 layout, чтобы скрыть все квартиры с такой планировкой",
+	"hide.no.layout":      "У квартиры %v нет известной планировки",
+	"hide.failed":         "Не удалось сохранить скрытые квартиры: %v",
+	"hide.done":           "🙈 Квартира %v скрыта, /%v_%v чтобы показывать снова",
+	"hide.layout.done":    "🙈 Квартиры с планировкой %v скрыты, /%v чтобы посмотреть",
+	"unhide.usage":        "использование: /%v [id], например /%v 819556",
+	"unhide.not.hidden":   "Квартира %v не скрыта",
+	"unhide.done":         "Квартира %v снова показывается",
+	"unhide.layout.usage": "использование: /%v [номер], номера см. в /%v",
+	"unhide.layout.done":  "Планировка %v снова показывается",
+	"hidden.empty":        "Нет скрытых квартир\n\nиспользование: /%v [id] [layout], например /%v 819556 или /%v 819556 layout",
+	"hidden.flats":        "🙈 Скрытые квартиры (%v):",
+	"hidden.layouts":      "🙈 Скрытые планировки (%v):",
+	"hidden.layout.link":  "<a href=\"%v\">планировка</a>",
+	"button.hide":         "🚫 Скрыть",
+	"button.unhide":       "👀 Показывать",
+	"button.hide.layout":  "🚫 Скрыть планировку",
+
+	// export
+	"export.usage":        "использование: /%v [код] [csv|xlsx] [фильтр], например /%v %v xlsx rooms=2",
+	"export.caption.one":  "%v квартира в ЖК %v",
+	"export.caption.few":  "%v квартиры в ЖК %v",
+	"export.caption.many": "%v квартир в ЖК %v",
+
+	// statistics
+	"stats.header":         "📊 <b>%v</b>: в продаже %v (свободно %v, в брони %v)",
+	"stats.col.rooms":      "комн",
+	"stats.col.count":      "всего",
+	"stats.col.free":       "своб",
+	"stats.col.reserved":   "бронь",
+	"stats.col.price":      "цена, млн",
+	"stats.col.meterprice": "м2, тыс",
+	"stats.rooms":          "%vк",
+	"stats.sold":           "⏱ Продано: %v, в среднем %.0f дн. в продаже",
+	"stats.listed":         "Сейчас в продаже в среднем %.0f дн.",
+
+	// charts
+	"chart.block":  "📈 <b>%v</b>: медианная цена за м2 по числу комнат, последние %v дн.",
+	"chart.flat":   "📈 Квартира %v (%v): история цены",
+	"chart.empty":  "Истории цен пока нет",
+	"button.chart": "📈 График",
+
+	// deals
+	"deals.help":    "Только выгодные: %v\n\nВ этом режиме приходят только квартиры, которые дешевле похожих в ЖК (те же комнаты, близкие площадь и этаж) хотя бы на %.0f%% за м2\n\nиспользование: /%v on|off, например /%v on",
+	"deals.changed": "Только выгодные: %v",
+	"deals.on":      "вкл",
+	"deals.off":     "выкл",
+
+	// flat lists
+	"display.usage": "использование: /%v [sort=ключ] [columns=список] [millions=on|off] или /%v reset\n" +
+		"ключи сортировки: price, meterprice, area, floor, rooms, created; -price чтобы сначала дорогие\n" +
+		"колонки: %v\n" +
+		"например /%v sort=-area columns=rooms,area,price,meterprice millions=on",
+	"display.changed": "Списки квартир: %v",
+
+	// market report
+	"report.header":     "📈 <b>%v</b>: скорость продаж",
+	"report.window":     "%v дн.",
+	"report.line":       "%v: продано %v из %v (%.0f%%)",
+	"report.days":       ", в среднем %.0f дн. в продаже",
+	"report.rooms":      "По комнатности, %v дн.:",
+	"report.bulks":      "По корпусам, %v дн.:",
+	"report.layouts":    "Быстрее всего продаются планировки, %v дн.:",
+	"report.layout":     "<a href=\"%v\">%vк, %vм²</a>",
+	"report.weekly.on":  "📅 Еженедельный отчёт по ЖК из подписок: вкл, каждый понедельник в %v",
+	"report.weekly.off": "📅 Еженедельный отчёт: выкл",
+
+	// search
+	"search.usage":   "использование: /%v [фильтр] [sort=ключ], например /%v rooms=2 price<15m metro=Нагатинская sort=meterprice\n\nключи сортировки: price, meterprice, area, floor, rooms, created; -price чтобы сначала дорогие",
+	"search.empty":   "Квартиры не найдены: %v",
+	"search.header":  "🔎 %v: найдено %v, стр. %v/%v",
+	"search.expired": "Поиск устарел, повторите /%v",
+
+	// comparison
+	"compare.usage":      "использование: /%v [код] [код]..., до %v ЖК, например /%v 2ngt utnv\n\nкоды: /%v",
+	"compare.unknown":    "Неизвестные ЖК: %v",
+	"compare.header":     "⚖️ Квартиры в продаже, кол-во: мин/медиана цены в млн",
+	"compare.metro":      "метро",
+	"compare.total":      "квартир",
+	"compare.meterprice": "м2, тыс",
+	"compare.settlement": "ключи",
+
+	// budget
+	"budget.usage":            "использование: /%v [бюджет] [фильтр] [downpayment=сумма] [sort=ключ], например /%v 14m rooms=2 downpayment=3m\n\nквартиры из ЖК в подписках, сначала самые большие; sort=meterprice чтобы сначала самые дешёвые за м2",
+	"budget.no.subscriptions": "Подписок пока нет, /%v [код] чтобы подписаться, см. /%v",
+	"budget.empty":            "В ЖК из подписок нет квартир до %v₽%v",
+	"budget.header":           "💰 До %v₽%v: %v в %v ЖК",
+	"budget.found.one":        "%v квартира",
+	"budget.found.few":        "%v квартиры",
+	"budget.found.many":       "%v квартир",
+	"budget.block":            "<b>%v</b> (%v) /%v_%v",
+	"budget.payment":          "≈%v₽/мес",
+	"budget.mortgage":         "Оценка ипотеки: %v",
+
+	// mortgage
+	"mortgage.usage": "использование: /%v [family|it|market] [rate=%%] [years=N] [down=сумма|%%] [annuity|diff] [цена]\n" +
+		"family, it: льготные программы, market: %.0f%%\n" +
+		"например /%v family years=20 down=20%%\n/%v 15m чтобы посчитать для цены, /%v off чтобы скрыть платежи",
+	"mortgage.current":            "🏦 Ипотека: %v",
+	"mortgage.changed":            "🏦 Ипотека: %v\n\nЕжемесячный платёж показывается в карточках квартир (/%v) и в /%v",
+	"mortgage.off":                "🏦 Платежи по ипотеке не показываются, /%v [параметры] чтобы настроить",
+	"mortgage.params":             "первый взнос %v, %.1f%%, %v лет, %v",
+	"mortgage.annuity":            "аннуитетный",
+	"mortgage.diff":               "дифференцированный",
+	"mortgage.preset.family":      "Семейная ипотека",
+	"mortgage.preset.it":          "IT-ипотека",
+	"mortgage.preset.market":      "Рыночная ставка",
+	"mortgage.max.loan":           "ставка действует до %v₽, остаток под %.1f%%",
+	"mortgage.down.amount":        "%v₽",
+	"mortgage.down.percent":       "%.0f%%",
+	"mortgage.payment":            "🏦 ≈%v₽/мес",
+	"mortgage.payment.diff":       "🏦 %v→%v₽/мес",
+	"mortgage.price":              "💰 Цена: %v₽",
+	"mortgage.loan":               "Первый взнос: %v₽, кредит: %v₽",
+	"mortgage.monthly":            "Ежемесячный платёж: %v₽",
+	"mortgage.monthly.diff":       "Ежемесячный платёж: от %v₽ до %v₽",
+	"mortgage.overpayment":        "Переплата: %v₽",
+	"mortgage.market.rate":        "(рыночная ставка)",
+	"mortgage.preset.not.applies": "⚠️ %v требует первый взнос от %.0f%%, весь кредит по рыночной ставке %.1f%%",
+	"mortgage.no.loan":            "Первый взнос покрывает цену, кредит не нужен",
+
+	// grouping
+	"group.header":      "%v в %v, %v:",
+	"group.by.layout":   "по планировкам (%v)",
+	"group.by.bulk":     "по корпусам (%v)",
+	"group.by.rooms":    "по комнатности (%v)",
+	"group.layout.name": "%vк, %vм²",
+	"group.layout":      "%v ×%v: %v₽, эт.%v, корп. %v",
+	"group.bulk":        "<b>Корпус %v</b>: %v, %vк, %vм², %v₽, эт.%v",
+	"group.rooms":       "<b>%vк</b>: %v, %vм², %v₽, эт.%v",
+
+	// input errors
+	"filter.invalid":           "Неверное условие фильтра: %v",
+	"filter.string.op":         "Для %v поддерживаются только = и !=",
+	"filter.unknown.field":     "Неизвестное поле фильтра: %v",
+	"number.invalid":           "Неверное число: %v",
+	"sort.unknown":             "Неизвестный ключ сортировки: %v",
+	"group.unknown":            "Неизвестная группировка: %v",
+	"display.unknown.column":   "Неизвестная колонка: %v",
+	"display.invalid.millions": "Неверный режим millions: %v, ожидается on или off",
+	"display.unknown.option":   "Неизвестная настройка отображения: %v",
+	"clock.invalid":            "Неверное время %q, ожидается ЧЧ:ММ",
+	"delivery.empty":           "Не указан режим доставки",
+	"delivery.unknown.weekday": "Неизвестный день недели: %v",
+	"delivery.unknown":         "Неизвестный режим доставки: %v",
+	"timezone.empty":           "Не указан часовой пояс",
+	"timezone.unknown":         "Неизвестный часовой пояс: %v",
+	"quiet.empty":              "Не указано время тишины",
+	"quiet.invalid":            "Неверное время тишины %q, ожидается ЧЧ:ММ-ЧЧ:ММ",
+	"quiet.unknown":            "Неизвестный режим тишины или часовой пояс: %v",
+	"mortgage.invalid.rate":    "Неверная ставка: %v",
+	"mortgage.invalid.term":    "Неверный срок: %v",
+	"mortgage.unknown.param":   "Неизвестный параметр ипотеки: %v",
+	"downpayment.invalid":      "Неверный первоначальный взнос: %v",
+	"search.one.sort":          "Поддерживается только один ключ сортировки",
+	"near.not.number":          "Не число: %v",
+	"near.no.location":         "Сначала отправьте геопозицию или укажите координаты",
+	"near.too.many":            "Слишком много аргументов",
+	"near.invalid.coordinates": "Неверные координаты: %v,%v",
+	"near.invalid.radius":      "Радиус должен быть в пределах (0, %v] км",
+	"budget.not.set":           "Не указан бюджет",
+	"budget.invalid":           "Неверный бюджет: %v",
+}
@@ -0,0 +1,121 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type Lang string
+
+const (
+	En Lang = "en"
+	Ru Lang = "ru"
+
+	// DefaultLang for chats with unknown language, e.g. channels, the bot spoke English before the localization
+	DefaultLang = En
+)
+
+var Langs = []Lang{En, Ru}
+
+// Catalog message key => fmt format
+type Catalog map[string]string
+
+var catalogs = map[Lang]Catalog{
+	En: en,
+	Ru: ru,
+}
+
+// FromCode Telegram language_code => supported language, example: "ru", "uk", "be" => Ru, "en-US" => En
+func FromCode(code string) Lang {
+	code, _, _ = strings.Cut(strings.ToLower(code), "-")
+	switch code {
+	case "":
+		return DefaultLang
+	case "ru", "uk", "be", "kk":
+		return Ru
+	}
+	return En
+}
+
+// Parse example: "ru", "рус", "English" => Ru, Ru, En
+func Parse(s string) (Lang, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case s == "ru" || strings.HasPrefix(s, "rus") || strings.HasPrefix(s, "рус"):
+		return Ru, true
+	case s == "en" || strings.HasPrefix(s, "eng") || strings.HasPrefix(s, "англ"):
+		return En, true
+	}
+	return "", false
+}
+
+// T translated message, falls back to English and then to the key itself
+func T(lang Lang, key string, args ...any) string {
+	format, ok := catalogs[lang][key]
+	if !ok {
+		format, ok = catalogs[En][key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// N message with the plural form for n, n is the first argument of the format,
+// example: N(Ru, "flats", 5) => "5 квартир"
+func N(lang Lang, key string, n int, args ...any) string {
+	return T(lang, key+"."+PluralForm(lang, n), append([]any{n}, args...)...)
+}
+
+// PluralForm "one", "few" or "many", e.g. for Russian: 1 квартира, 2 квартиры, 5 квартир, 21 квартира
+func PluralForm(lang Lang, n int) string {
+	if n < 0 {
+		n = -n
+	}
+	if lang != Ru {
+		if n == 1 {
+			return "one"
+		}
+		return "many"
+	}
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return "one"
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return "few"
+	}
+	return "many"
+}
+
+func (l Lang) String() string {
+	return T(l, "lang.name")
+}
+
+// Error invalid user input: the message key and its arguments, rendered in the chat language by ErrorText
+type Error struct {
+	Key  string
+	Args []any
+}
+
+// NewError example: NewError("filter.unknown.field", "color")
+func NewError(key string, args ...any) error {
+	return &Error{Key: key, Args: args}
+}
+
+// Error the English text for the logs
+func (e *Error) Error() string {
+	return T(En, e.Key, e.Args...)
+}
+
+// ErrorText the translated message of the wrapped *Error, the text of other errors as is
+func ErrorText(lang Lang, err error) string {
+	var res *Error
+	if errors.As(err, &res) {
+		return T(lang, res.Key, res.Args...)
+	}
+	return err.Error()
+}
@@ -0,0 +1,80 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluralForm(t *testing.T) {
+	tests := []struct {
+		n        int
+		expected string
+	}{
+		{n: 1, expected: "1 квартира"},
+		{n: 2, expected: "2 квартиры"},
+		{n: 4, expected: "4 квартиры"},
+		{n: 5, expected: "5 квартир"},
+		{n: 11, expected: "11 квартир"},
+		{n: 12, expected: "12 квартир"},
+		{n: 21, expected: "21 квартира"},
+		{n: 22, expected: "22 квартиры"},
+		{n: 111, expected: "111 квартир"},
+		{n: 0, expected: "0 квартир"},
+	}
+
+	for i, test := range tests {
+		require.Equal(t, test.expected, N(Ru, "flats", test.n), fmt.Sprintf("failed case %v", i))
+	}
+
+	require.Equal(t, "1 flat", N(En, "flats", 1))
+	require.Equal(t, "2 flats", N(En, "flats", 2))
+}
+
+func TestT(t *testing.T) {
+	require.Equal(t, "Hello, georgri!", T(En, "hello", "georgri"))
+	require.Equal(t, "Привет, georgri!", T(Ru, "hello", "georgri"))
+	require.Equal(t, "no.such.key", T(Ru, "no.such.key"))
+	require.Equal(t, "2ngt: новых квартир 3, снова в продаже 1:", T(Ru, "flats.header.relisted", 3, 1, "2ngt"))
+}
+
+var verbRegexp = regexp.MustCompile(`%(\[\d+\])?[-+#0 ]*[\d.]*[a-zA-Z%]`)
+
+// TestCatalogs every message is translated and takes the same arguments
+func TestCatalogs(t *testing.T) {
+	for key, format := range en {
+		translated, ok := ru[key]
+		if !ok && strings.HasSuffix(key, ".many") {
+			translated, ok = ru[strings.TrimSuffix(key, ".many")+".few"]
+		}
+		require.True(t, ok, fmt.Sprintf("no translation of %v", key))
+		require.Equal(t, len(verbRegexp.FindAllString(format, -1)), len(verbRegexp.FindAllString(translated, -1)),
+			fmt.Sprintf("different arguments of %v", key))
+	}
+	for key := range ru {
+		if strings.HasSuffix(key, ".few") {
+			continue
+		}
+		_, ok := en[key]
+		require.True(t, ok, fmt.Sprintf("unknown key %v", key))
+	}
+}
+
+func TestErrorText(t *testing.T) {
+	err := NewError("hello", "georgri")
+	require.Equal(t, "Hello, georgri!", err.Error())
+	require.Equal(t, "Привет, georgri!", ErrorText(Ru, err))
+	require.Equal(t, "Привет, georgri!", ErrorText(Ru, fmt.Errorf("wrapped: %w", err)))
+	require.Equal(t, "plain", ErrorText(Ru, errors.New("plain")))
+}
+
+func TestFromCode(t *testing.T) {
+	require.Equal(t, Ru, FromCode("ru"))
+	require.Equal(t, Ru, FromCode("uk"))
+	require.Equal(t, En, FromCode("en-US"))
+	require.Equal(t, DefaultLang, FromCode(""))
+}
@@ -0,0 +1,186 @@
+// Package mortgage estimates monthly mortgage payments
+package mortgage
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	DefaultRatePercent = 18.0 // market rate
+	DefaultYears       = 30
+)
+
+type Type string
+
+const (
+	TypeAnnuity        Type = "annuity"
+	TypeDifferentiated Type = "diff" // the principal is repaid in equal parts, the payment decreases
+)
+
+// Params of the loan, zero values mean defaults
+type Params struct {
+	Type        Type    `json:"type,omitempty"`
+	RatePercent float64 `json:"rate_percent,omitempty"` // annual
+	Years       int     `json:"years,omitempty"`
+
+	DownPayment int64   `json:"down_payment,omitempty"` // takes precedence over DownPercent
+	DownPercent float64 `json:"down_percent,omitempty"`
+
+	// MaxLoan the rate applies up to this amount, the rest is at DefaultRatePercent, e.g. for family mortgage
+	MaxLoan int64  `json:"max_loan,omitempty"`
+	Preset  string `json:"preset,omitempty"`
+}
+
+// Presets subsidized programs, the down payment and the term are kept when applied
+var Presets = map[string]Params{
+	"market": {RatePercent: DefaultRatePercent},
+	"family": {RatePercent: 6, MaxLoan: 12_000_000, DownPercent: 20},
+	"it":     {RatePercent: 6, MaxLoan: 18_000_000, DownPercent: 20},
+}
+
+// WithPreset example: "family" => 6% up to 12m with at least 20% down payment,
+// an absolute down payment is checked against the minimum for each price, see PresetApplies
+func (p Params) WithPreset(name string) (Params, error) {
+	name = strings.ToLower(name)
+	preset, ok := Presets[name]
+	if !ok {
+		return p, fmt.Errorf("unknown preset: %v", name)
+	}
+	p.Preset = name
+	p.RatePercent, p.MaxLoan = preset.RatePercent, preset.MaxLoan
+	if preset.DownPercent > p.DownPercent && p.DownPayment == 0 {
+		p.DownPercent = preset.DownPercent
+	}
+	return p, nil
+}
+
+func (p Params) PaymentType() Type {
+	if p.Type == TypeDifferentiated {
+		return TypeDifferentiated
+	}
+	return TypeAnnuity
+}
+
+func (p Params) Rate() float64 {
+	if p.RatePercent <= 0 {
+		return DefaultRatePercent
+	}
+	return p.RatePercent
+}
+
+func (p Params) Months() int {
+	if p.Years <= 0 {
+		return DefaultYears * 12
+	}
+	return p.Years * 12
+}
+
+// Down the down payment for the price
+func (p Params) Down(price int64) int64 {
+	if p.DownPayment > 0 {
+		return p.DownPayment
+	}
+	return int64(math.Round(float64(price) * p.DownPercent / 100))
+}
+
+// PresetApplies false if the down payment is below the minimum of the preset for the price,
+// the whole loan is at DefaultRatePercent then
+func (p Params) PresetApplies(price int64) bool {
+	preset, ok := Presets[p.Preset]
+	if !ok || preset.DownPercent <= 0 {
+		return true
+	}
+	return p.Down(price) >= int64(math.Round(float64(price)*preset.DownPercent/100))
+}
+
+// MinDownPercent the minimum down payment of the preset, 0 if none
+func (p Params) MinDownPercent() float64 {
+	return Presets[p.Preset].DownPercent
+}
+
+// Principal the loan amount for the price
+func (p Params) Principal(price int64) int64 {
+	down := p.Down(price)
+	if price <= down {
+		return 0
+	}
+	return price - down
+}
+
+type loan struct {
+	principal   int64
+	ratePercent float64
+}
+
+// loans parts of the principal with their rates
+func (p Params) loans(price int64) []loan {
+	principal := p.Principal(price)
+	if !p.PresetApplies(price) {
+		return []loan{{principal, DefaultRatePercent}}
+	}
+	if p.MaxLoan > 0 && principal > p.MaxLoan {
+		return []loan{{p.MaxLoan, p.Rate()}, {principal - p.MaxLoan, DefaultRatePercent}}
+	}
+	return []loan{{principal, p.Rate()}}
+}
+
+// Payments the first and the last monthly payments, equal for annuity
+func (p Params) Payments(price int64) (int64, int64) {
+	var first, last float64
+	months := p.Months()
+	for _, loan := range p.loans(price) {
+		if p.PaymentType() == TypeDifferentiated {
+			f, l := Differentiated(loan.principal, loan.ratePercent, months)
+			first, last = first+f, last+l
+			continue
+		}
+		payment := Annuity(loan.principal, loan.ratePercent, months)
+		first, last = first+payment, last+payment
+	}
+	return int64(math.Round(first)), int64(math.Round(last))
+}
+
+// MonthlyPayment the first monthly payment for the price
+func (p Params) MonthlyPayment(price int64) int64 {
+	first, _ := p.Payments(price)
+	return first
+}
+
+// Overpayment total interest over the term
+func (p Params) Overpayment(price int64) int64 {
+	var res float64
+	months := float64(p.Months())
+	for _, loan := range p.loans(price) {
+		principal := float64(loan.principal)
+		if p.PaymentType() == TypeDifferentiated {
+			res += principal * loan.ratePercent / 100 / 12 * (months + 1) / 2
+			continue
+		}
+		res += Annuity(loan.principal, loan.ratePercent, p.Months())*months - principal
+	}
+	return int64(math.Round(res))
+}
+
+// Annuity equal monthly payment: P * r / (1 - (1 + r)^-n), r is the monthly rate
+func Annuity(principal int64, ratePercent float64, months int) float64 {
+	if principal <= 0 || months <= 0 {
+		return 0
+	}
+	r := ratePercent / 100 / 12
+	if r == 0 {
+		return float64(principal) / float64(months)
+	}
+	return float64(principal) * r / (1 - math.Pow(1+r, -float64(months)))
+}
+
+// Differentiated the first and the last payments: P/n plus the interest on the remaining principal
+func Differentiated(principal int64, ratePercent float64, months int) (float64, float64) {
+	if principal <= 0 || months <= 0 {
+		return 0, 0
+	}
+	r := ratePercent / 100 / 12
+	part := float64(principal) / float64(months)
+	return part + float64(principal)*r, part + part*r
+}
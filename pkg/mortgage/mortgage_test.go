@@ -0,0 +1,96 @@
+package mortgage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnuity(t *testing.T) {
+	tests := []struct {
+		principal   int64
+		ratePercent float64
+		months      int
+		expected    float64
+	}{
+		{10_000_000, 12, 240, 110_108.61},
+		{1_200_000, 0, 120, 10_000},
+		{0, 12, 240, 0},
+	}
+
+	for i, test := range tests {
+		require.InDelta(t, test.expected, Annuity(test.principal, test.ratePercent, test.months), 0.01, fmt.Sprintf("failed case %v", i))
+	}
+}
+
+func TestMonthlyPayment(t *testing.T) {
+	params := Params{RatePercent: 12, Years: 20, DownPayment: 4_000_000}
+	require.Equal(t, int64(110_109), params.MonthlyPayment(14_000_000))
+	require.Equal(t, int64(0), params.MonthlyPayment(3_000_000))
+	require.Equal(t, DefaultYears*12, Params{}.Months())
+}
+
+func TestPayments(t *testing.T) {
+	tests := []struct {
+		params      Params
+		price       int64
+		first       int64
+		last        int64
+		overpayment int64
+	}{
+		{Params{RatePercent: 12, Years: 20, DownPayment: 4_000_000}, 14_000_000, 110_109, 110_109, 16_426_067},
+		{Params{RatePercent: 12, Years: 20, DownPercent: 20}, 12_500_000, 110_109, 110_109, 16_426_067},
+		{Params{Type: TypeDifferentiated, RatePercent: 12, Years: 20, DownPayment: 4_000_000}, 14_000_000, 141_667, 42_083, 12_050_000},
+		{Params{RatePercent: 12, Years: 20, DownPayment: 4_000_000}, 3_000_000, 0, 0, 0},
+		// 6% up to 12m, the rest 3m at the market rate
+		{Params{RatePercent: 6, Years: 20, DownPayment: 5_000_000, MaxLoan: 12_000_000}, 20_000_000, 132_271, 132_271, 16_745_057},
+	}
+
+	for i, test := range tests {
+		first, last := test.params.Payments(test.price)
+		require.Equal(t, test.first, first, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.last, last, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.overpayment, test.params.Overpayment(test.price), fmt.Sprintf("failed case %v", i))
+	}
+}
+
+func TestWithPreset(t *testing.T) {
+	params, err := Params{Years: 20, DownPercent: 30}.WithPreset("Family")
+	require.NoError(t, err)
+	require.Equal(t, Params{RatePercent: 6, Years: 20, DownPercent: 30, MaxLoan: 12_000_000, Preset: "family"}, params)
+
+	params, err = Params{}.WithPreset("it")
+	require.NoError(t, err)
+	require.Equal(t, 20.0, params.DownPercent)
+
+	_, err = Params{}.WithPreset("rural")
+	require.Error(t, err)
+}
+
+func TestPresetApplies(t *testing.T) {
+	family, err := Params{Years: 20, DownPayment: 2_000_000}.WithPreset("family")
+	require.NoError(t, err)
+
+	tests := []struct {
+		params   Params
+		price    int64
+		expected bool
+	}{
+		{family, 10_000_000, true},  // exactly 20%
+		{family, 12_000_000, false}, // 16.7%
+		{Params{DownPercent: 10, Preset: "it"}, 12_000_000, false},
+		{Params{DownPercent: 20, Preset: "it"}, 12_000_000, true},
+		{Params{DownPayment: 1, Preset: "market"}, 12_000_000, true},
+		{Params{DownPayment: 1}, 12_000_000, true},
+	}
+
+	for i, test := range tests {
+		require.Equal(t, test.expected, test.params.PresetApplies(test.price), fmt.Sprintf("failed case %v", i))
+	}
+
+	// below the minimum the whole loan is at the market rate
+	market := Params{Years: 20, DownPayment: 2_000_000}
+	require.Equal(t, market.MonthlyPayment(12_000_000), family.MonthlyPayment(12_000_000))
+	require.Less(t, family.MonthlyPayment(10_000_000), market.MonthlyPayment(10_000_000))
+}
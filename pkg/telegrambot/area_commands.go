@@ -0,0 +1,272 @@
+package telegrambot
+
+import (
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"log"
+	"strconv"
+	"strings"
+)
+
+const (
+	SubscribeMetroCommand    = "submetro"
+	UnsubscribeMetroCommand  = "unsubmetro"
+	SubscribeRegionCommand   = "subregion"
+	UnsubscribeRegionCommand = "unsubregion"
+	NearCommand              = "near"
+	UnsubscribeNearCommand   = "unnear"
+
+	DefaultRadiusKm = 3.0
+	MaxRadiusKm     = 100.0
+)
+
+// pendingLocations the last location sent by chat, waiting for /near [km]
+var pendingLocations = make(map[int64][2]float64)
+
+func newAreaSubscription(chatID int64, name string, metro bool) AreaSubscription {
+	if metro {
+		return AreaSubscription{ChatID: chatID, Metro: name}
+	}
+	return AreaSubscription{ChatID: chatID, Region: name}
+}
+
+// validateArea returns the canonical name of a known metro station or region
+func validateArea(chatID int64, name string, metro bool, command string) (string, error) {
+	name = strings.TrimSpace(name)
+	known := GetKnownAreas(metro)
+
+	var suggestions []string
+	for _, area := range known {
+		if len(name) > 0 && NormalizeAreaName(area) == NormalizeAreaName(name) {
+			return area, nil
+		}
+		if len(name) > 0 && strings.Contains(NormalizeAreaName(area), NormalizeAreaName(name)) {
+			suggestions = append(suggestions, area)
+		}
+	}
+	if len(suggestions) == 0 {
+		suggestions = known
+	}
+
+	key := "area.usage.regions"
+	if metro {
+		key = "area.usage.metro"
+	}
+	err := SendMessage(chatID, i18n.T(ChatLang(chatID), key, command, strings.Join(suggestions, "\n")))
+	if err != nil {
+		return "", fmt.Errorf("failed to send /%v help message: %v", command, err)
+	}
+	return "", fmt.Errorf("area is empty or unknown: %v", name)
+}
+
+func subscribeArea(chatID int64, args string, metro bool) {
+	command, unsubscribeCommand := SubscribeRegionCommand, UnsubscribeRegionCommand
+	if metro {
+		command, unsubscribeCommand = SubscribeMetroCommand, UnsubscribeMetroCommand
+	}
+
+	name, err := validateArea(chatID, args, metro, command)
+	if err != nil {
+		log.Printf("failed to subscribe %v to area %v: %v", chatID, args, err)
+		return
+	}
+	lang := ChatLang(chatID)
+
+	area := newAreaSubscription(chatID, name, metro)
+	if CheckAreaSubscribed(area) {
+		err = SendMessage(chatID, i18n.T(lang, "area.already", area.Format(lang), unsubscribeCommand+" "+name))
+		if err != nil {
+			log.Printf("failed to send already subscribed message to %v: %v", chatID, err)
+		}
+		return
+	}
+
+	err = AddAreaSubscriber(area)
+	if err != nil {
+		err = SendMessage(chatID, i18n.T(lang, "area.failed", area.Format(lang), err)+"\n"+
+			i18n.T(lang, "area.try.again", command+" "+name))
+		if err != nil {
+			log.Printf("failed to send subscription failed message to %v: %v", chatID, err)
+		}
+		log.Printf("failed to subscribe %v to %v", chatID, area)
+		return
+	}
+
+	var blocks []string
+	for _, block := range GetAreaBlocks(area) {
+		blocks = append(blocks, block.String())
+	}
+	err = SendMessage(chatID, i18n.T(lang, "area.done", area.Format(lang), strings.Join(blocks, "\n"), unsubscribeCommand+" "+name))
+	if err != nil {
+		log.Printf("failed to send subscribed message to %v: %v", chatID, err)
+	}
+}
+
+func unsubscribeArea(chatID int64, args string, metro bool) {
+	command, subscribeCommand := UnsubscribeRegionCommand, SubscribeRegionCommand
+	if metro {
+		command, subscribeCommand = UnsubscribeMetroCommand, SubscribeMetroCommand
+	}
+
+	// any stored subscription can be removed, even if its area is not known anymore
+	area, ok := FindAreaSubscription(newAreaSubscription(chatID, args, metro))
+	if !ok {
+		name, err := validateArea(chatID, args, metro, command)
+		if err != nil {
+			log.Printf("failed to unsubscribe %v from area %v: %v", chatID, args, err)
+			return
+		}
+		area = newAreaSubscription(chatID, name, metro)
+	}
+	name := area.Region
+	if metro {
+		name = area.Metro
+	}
+	lang := ChatLang(chatID)
+
+	err := RemoveAreaSubscriber(area)
+	if err != nil {
+		err = SendMessage(chatID, i18n.T(lang, "area.unsub.already", area.Format(lang), subscribeCommand+" "+name))
+		if err != nil {
+			log.Printf("failed to send unsubscription failed message to %v: %v", chatID, err)
+		}
+		return
+	}
+
+	err = SendMessage(chatID, i18n.T(lang, "area.unsub.done", area.Format(lang), subscribeCommand+" "+name))
+	if err != nil {
+		log.Printf("failed to send unsubscribed message to %v: %v", chatID, err)
+	}
+}
+
+// areaSubscriptionsList example:
+// Subscribed areas: м.Нагатинская, Москва
+func areaSubscriptionsList(chatID int64) string {
+	lang := ChatLang(chatID)
+	var areas []string
+	for _, area := range GetChatAreaSubscriptions(chatID) {
+		areas = append(areas, area.Format(lang))
+	}
+	if len(areas) == 0 {
+		return ""
+	}
+	return i18n.T(lang, "area.list", strings.Join(areas, ", "))
+}
+
+func receiveLocation(chatID int64, latitude, longitude float64) {
+	pendingLocations[chatID] = [2]float64{latitude, longitude}
+
+	err := SendMessage(chatID, i18n.T(ChatLang(chatID), "near.location", latitude, longitude,
+		NearCommand, NearCommand, DefaultRadiusKm))
+	if err != nil {
+		log.Printf("failed to send location received message to %v: %v", chatID, err)
+	}
+}
+
+// parseNearArgs accepts "[km]" (with a location sent before), "[lat] [lon] [km]" or "[lat],[lon] [km]"
+func parseNearArgs(chatID int64, args string) (AreaSubscription, error) {
+	fields := strings.Fields(strings.ReplaceAll(args, ",", " "))
+
+	var numbers []float64
+	for _, field := range fields {
+		number, err := strconv.ParseFloat(strings.TrimSuffix(field, "km"), 64)
+		if err != nil {
+			return AreaSubscription{}, i18n.NewError("near.not.number", field)
+		}
+		numbers = append(numbers, number)
+	}
+
+	area := AreaSubscription{ChatID: chatID, RadiusKm: DefaultRadiusKm}
+	switch len(numbers) {
+	case 0, 1:
+		location, ok := pendingLocations[chatID]
+		if !ok {
+			return AreaSubscription{}, i18n.NewError("near.no.location")
+		}
+		area.Latitude, area.Longitude = location[0], location[1]
+		if len(numbers) == 1 {
+			area.RadiusKm = numbers[0]
+		}
+	case 2, 3:
+		area.Latitude, area.Longitude = numbers[0], numbers[1]
+		if len(numbers) == 3 {
+			area.RadiusKm = numbers[2]
+		}
+	default:
+		return AreaSubscription{}, i18n.NewError("near.too.many")
+	}
+
+	if area.Latitude < -90 || area.Latitude > 90 || area.Longitude < -180 || area.Longitude > 180 {
+		return AreaSubscription{}, i18n.NewError("near.invalid.coordinates", area.Latitude, area.Longitude)
+	}
+	if area.RadiusKm <= 0 || area.RadiusKm > MaxRadiusKm {
+		return AreaSubscription{}, i18n.NewError("near.invalid.radius", MaxRadiusKm)
+	}
+
+	return area, nil
+}
+
+func subscribeNear(chatID int64, args string) {
+	lang := ChatLang(chatID)
+	area, err := parseNearArgs(chatID, args)
+	if err != nil {
+		err = SendMessage(chatID, fmt.Sprintf("%v\n\n%v", i18n.ErrorText(lang, err), i18n.T(lang, "near.usage", NearCommand, NearCommand, NearCommand, DefaultRadiusKm)))
+		if err != nil {
+			log.Printf("failed to send /%v help message to %v: %v", NearCommand, chatID, err)
+		}
+		return
+	}
+
+	if CheckAreaSubscribed(area) {
+		err = SendMessage(chatID, i18n.T(lang, "area.already", area.Format(lang), UnsubscribeNearCommand))
+		if err != nil {
+			log.Printf("failed to send already subscribed message to %v: %v", chatID, err)
+		}
+		return
+	}
+
+	err = AddAreaSubscriber(area)
+	if err != nil {
+		err = SendMessage(chatID, i18n.T(lang, "area.failed", area.Format(lang), err))
+		if err != nil {
+			log.Printf("failed to send subscription failed message to %v: %v", chatID, err)
+		}
+		log.Printf("failed to subscribe %v to %v", chatID, area)
+		return
+	}
+	delete(pendingLocations, chatID)
+
+	var blocks []string
+	for _, block := range GetAreaBlocks(area) {
+		distance, _ := area.Distance(block)
+		blocks = append(blocks, fmt.Sprintf("%v %v", block, i18n.T(lang, "area.distance", distance)))
+	}
+	err = SendMessage(chatID, i18n.T(lang, "area.done", area.Format(lang), strings.Join(blocks, "\n"), UnsubscribeNearCommand))
+	if err != nil {
+		log.Printf("failed to send subscribed message to %v: %v", chatID, err)
+	}
+}
+
+// unsubscribeNear removes all radius subscriptions of the chat
+func unsubscribeNear(chatID int64) {
+	lang := ChatLang(chatID)
+
+	removed, err := RemoveChatRadiusSubscriptions(chatID)
+	if err != nil {
+		log.Printf("failed to sync area subscriptions to file: %v", err)
+	}
+
+	msg := i18n.T(lang, "near.unsub.already", NearCommand)
+	if len(removed) > 0 {
+		var areas []string
+		for _, area := range removed {
+			areas = append(areas, area.Format(lang))
+		}
+		msg = i18n.T(lang, "near.unsub.done", strings.Join(areas, "\n"))
+	}
+
+	err = SendMessage(chatID, msg)
+	if err != nil {
+		log.Printf("failed to send unsubscribed message to %v: %v", chatID, err)
+	}
+}
@@ -0,0 +1,324 @@
+package telegrambot
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+const AreaSubscriptionsFile = "data/area_subscriptions.json"
+
+// AreaSubscription covers all current and future blocks near the metro station, in the region
+// or within the radius around the point
+type AreaSubscription struct {
+	ChatID int64  `json:"chat_id"`
+	Metro  string `json:"metro,omitempty"`  // Нагатинская
+	Region string `json:"region,omitempty"` // Москва
+
+	Latitude  float64 `json:"latitude,omitempty"`
+	Longitude float64 `json:"longitude,omitempty"`
+	RadiusKm  float64 `json:"radius_km,omitempty"`
+}
+
+type AreaSubscriptionsFileMap map[string][]AreaSubscription
+
+var (
+	AreaSubscriptions      = make(map[util.EnvType][]AreaSubscription)
+	areaSubscriptionsMutex sync.Mutex
+)
+
+func init() {
+	content, err := os.ReadFile(AreaSubscriptionsFile)
+	if err != nil {
+		log.Printf("unable to read area subscriptions file: %v", err)
+		return
+	}
+
+	fileMap := make(AreaSubscriptionsFileMap)
+	err = json.Unmarshal(content, &fileMap)
+	if err != nil {
+		log.Printf("unable to unmarshal area subscriptions file: %v", err)
+		return
+	}
+
+	for envTypeStr, subscriptions := range fileMap {
+		envType, ok := util.EnvTypeFromString[envTypeStr]
+		if !ok {
+			log.Printf("unknown envtype in area subscriptions file: %v", envTypeStr)
+			continue
+		}
+		AreaSubscriptions[envType] = subscriptions
+	}
+}
+
+// SyncAreaSubscriptionsToFile the caller holds areaSubscriptionsMutex
+func SyncAreaSubscriptionsToFile() error {
+	fileMap := make(AreaSubscriptionsFileMap, len(AreaSubscriptions))
+	for envtype, subscriptions := range AreaSubscriptions {
+		fileMap[envtype.String()] = subscriptions
+	}
+	newContent, err := json.Marshal(fileMap)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(AreaSubscriptionsFile, newContent, 0644)
+}
+
+// NormalizeAreaName example: "м. Нагатинская" => "нагатинская"
+func NormalizeAreaName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.ReplaceAll(name, "ё", "е")
+	name = strings.TrimPrefix(name, "м.")
+	return strings.TrimSpace(name)
+}
+
+// Matches true if the block is covered by the subscription
+func (a AreaSubscription) Matches(block BlockInfo) bool {
+	if len(a.Metro) > 0 && NormalizeAreaName(a.Metro) == NormalizeAreaName(GetBlockMetro(block)) {
+		return true
+	}
+	if len(a.Region) > 0 && NormalizeAreaName(a.Region) == NormalizeAreaName(block.Region) {
+		return true
+	}
+	if distance, ok := a.Distance(block); ok && distance <= a.RadiusKm {
+		return true
+	}
+	return false
+}
+
+// Distance in km from the subscription point to the block
+func (a AreaSubscription) Distance(block BlockInfo) (float64, bool) {
+	if a.RadiusKm <= 0 || !block.HasCoordinates() {
+		return 0, false
+	}
+	return util.Haversine(a.Latitude, a.Longitude, block.Latitude, block.Longitude), true
+}
+
+func (a AreaSubscription) String() string {
+	return a.Format(i18n.En)
+}
+
+func (a AreaSubscription) Format(lang i18n.Lang) string {
+	if len(a.Metro) > 0 {
+		return fmt.Sprintf("м.%v", a.Metro)
+	}
+	if a.RadiusKm > 0 {
+		return i18n.T(lang, "area.radius", a.RadiusKm, a.Latitude, a.Longitude)
+	}
+	return a.Region
+}
+
+// GetChatBlockDistance distance to the block from the closest chat point covering it
+func GetChatBlockDistance(chatID int64, block BlockInfo) (float64, bool) {
+	var res float64
+	var found bool
+	for _, area := range GetChatAreaSubscriptions(chatID) {
+		distance, ok := area.Distance(block)
+		if !ok || distance > area.RadiusKm {
+			continue
+		}
+		if !found || distance < res {
+			res = distance
+			found = true
+		}
+	}
+	return res, found
+}
+
+// chatBlockDistance example: " (2.3 km)"
+func chatBlockDistance(chatID int64, block BlockInfo) string {
+	distance, ok := GetChatBlockDistance(chatID, block)
+	if !ok {
+		return ""
+	}
+	return " " + i18n.T(ChatLang(chatID), "area.distance", distance)
+}
+
+var (
+	// blockMetros metro of the blocks without one in the metadata, taken from the stored flats once
+	blockMetros      = make(map[string]string)
+	blockMetrosMutex sync.Mutex
+)
+
+// GetBlockMetro metro from the block metadata, falls back to the stored flats
+func GetBlockMetro(block BlockInfo) string {
+	if len(block.Metro) > 0 {
+		return block.Metro
+	}
+
+	blockMetrosMutex.Lock()
+	defer blockMetrosMutex.Unlock()
+
+	if metro, ok := blockMetros[block.Slug]; ok {
+		return metro
+	}
+	flats, err := ReadStoredFlats(block.Slug)
+	if err != nil || len(flats.Flats) == 0 {
+		// not cached: the flats may be downloaded later
+		return ""
+	}
+	blockMetros[block.Slug] = flats.Flats[0].Metro.Name
+	return blockMetros[block.Slug]
+}
+
+// GetAreaBlocks all known blocks covered by the subscription
+func GetAreaBlocks(area AreaSubscription) []BlockInfo {
+	var res []BlockInfo
+	for _, slug := range util.SortedKeys(BlockSlugs) {
+		if area.Matches(BlockSlugs[slug]) {
+			res = append(res, BlockSlugs[slug])
+		}
+	}
+	return res
+}
+
+// GetAreaChannels expand area subscriptions into block subscriptions not already made explicitly
+func GetAreaChannels(envType util.EnvType) []ChannelInfo {
+	known := make(map[string]struct{})
+	for _, channel := range ChannelIDs[envType] {
+		known[fmt.Sprintf("%v_%v", channel.BlockSlug, channel.ChatID)] = struct{}{}
+	}
+
+	var res []ChannelInfo
+	for _, area := range getAreaSubscriptions(envType) {
+		for _, block := range GetAreaBlocks(area) {
+			key := fmt.Sprintf("%v_%v", block.Slug, area.ChatID)
+			if _, ok := known[key]; ok {
+				continue
+			}
+			known[key] = struct{}{}
+			res = append(res, ChannelInfo{
+				ChatID:    area.ChatID,
+				BlockSlug: block.Slug,
+			})
+		}
+	}
+	return res
+}
+
+// getAreaSubscriptions a copy to be read without the lock: the blocks of the areas are matched slowly
+func getAreaSubscriptions(envType util.EnvType) []AreaSubscription {
+	areaSubscriptionsMutex.Lock()
+	defer areaSubscriptionsMutex.Unlock()
+
+	return append([]AreaSubscription(nil), AreaSubscriptions[envType]...)
+}
+
+func GetChatAreaSubscriptions(chatID int64) []AreaSubscription {
+	var res []AreaSubscription
+	for _, area := range getAreaSubscriptions(util.GetEnvType()) {
+		if area.ChatID == chatID {
+			res = append(res, area)
+		}
+	}
+	return res
+}
+
+func AddAreaSubscriber(area AreaSubscription) error {
+	envtype := util.GetEnvType()
+
+	areaSubscriptionsMutex.Lock()
+	defer areaSubscriptionsMutex.Unlock()
+
+	AreaSubscriptions[envtype] = append(AreaSubscriptions[envtype], area)
+
+	err := SyncAreaSubscriptionsToFile()
+	if err != nil {
+		n := len(AreaSubscriptions[envtype])
+		AreaSubscriptions[envtype] = AreaSubscriptions[envtype][:n-1]
+		return err
+	}
+
+	return nil
+}
+
+func RemoveAreaSubscriber(area AreaSubscription) error {
+	envtype := util.GetEnvType()
+
+	areaSubscriptionsMutex.Lock()
+	defer areaSubscriptionsMutex.Unlock()
+
+	indexToRemove := -1
+	for i, subscription := range AreaSubscriptions[envtype] {
+		if sameArea(subscription, area) {
+			indexToRemove = i
+			break
+		}
+	}
+	if indexToRemove < 0 {
+		return fmt.Errorf("chat %v was not subscribed to %v", area.ChatID, area)
+	}
+
+	AreaSubscriptions[envtype] = util.RemoveSliceElement(AreaSubscriptions[envtype], indexToRemove)
+
+	return SyncAreaSubscriptionsToFile()
+}
+
+// RemoveChatRadiusSubscriptions removes all radius subscriptions of the chat, returns the removed ones
+func RemoveChatRadiusSubscriptions(chatID int64) ([]AreaSubscription, error) {
+	envtype := util.GetEnvType()
+
+	areaSubscriptionsMutex.Lock()
+	defer areaSubscriptionsMutex.Unlock()
+
+	var removed []AreaSubscription
+	AreaSubscriptions[envtype] = util.FilterSliceInPlace(AreaSubscriptions[envtype], func(i int) bool {
+		area := AreaSubscriptions[envtype][i]
+		if area.ChatID == chatID && area.RadiusKm > 0 {
+			removed = append(removed, area)
+			return false
+		}
+		return true
+	})
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	return removed, SyncAreaSubscriptionsToFile()
+}
+
+func CheckAreaSubscribed(area AreaSubscription) bool {
+	_, ok := FindAreaSubscription(area)
+	return ok
+}
+
+// FindAreaSubscription the stored subscription of the chat to the same area, the name may differ in case
+func FindAreaSubscription(area AreaSubscription) (AreaSubscription, bool) {
+	for _, subscription := range getAreaSubscriptions(util.GetEnvType()) {
+		if sameArea(subscription, area) {
+			return subscription, true
+		}
+	}
+	return AreaSubscription{}, false
+}
+
+func sameArea(a, b AreaSubscription) bool {
+	return a.ChatID == b.ChatID &&
+		NormalizeAreaName(a.Metro) == NormalizeAreaName(b.Metro) &&
+		NormalizeAreaName(a.Region) == NormalizeAreaName(b.Region) &&
+		a.Latitude == b.Latitude && a.Longitude == b.Longitude && a.RadiusKm == b.RadiusKm
+}
+
+// GetKnownAreas all known metro stations or regions of the blocks
+func GetKnownAreas(metro bool) []string {
+	areas := make(map[string]string)
+	for _, block := range BlockSlugs {
+		name := block.Region
+		if metro {
+			name = GetBlockMetro(block)
+		}
+		if len(name) > 0 {
+			areas[NormalizeAreaName(name)] = name
+		}
+	}
+
+	var res []string
+	for _, key := range util.SortedKeys(areas) {
+		res = append(res, areas[key])
+	}
+	return res
+}
@@ -0,0 +1,168 @@
+package telegrambot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAreaMatches(t *testing.T) {
+	block := BlockInfo{Slug: "test-nagatinskaya", Metro: "Нагатинская", Region: "Москва"}
+
+	tests := []struct {
+		area     AreaSubscription
+		block    BlockInfo
+		expected bool
+	}{
+		{AreaSubscription{Metro: "Нагатинская"}, block, true},
+		{AreaSubscription{Metro: "м. нагатинская"}, block, true},
+		{AreaSubscription{Metro: "Тульская"}, block, false},
+		{AreaSubscription{Region: "москва"}, block, true},
+		{AreaSubscription{Region: "Московская область"}, block, false},
+		{AreaSubscription{Metro: "Нагатинская"}, BlockInfo{Slug: "test-no-metro"}, false},
+		{AreaSubscription{}, block, false},
+	}
+
+	for i, test := range tests {
+		require.Equal(t, test.expected, test.area.Matches(test.block), fmt.Sprintf("failed case %v", i))
+	}
+}
+
+func TestGetAreaChannels(t *testing.T) {
+	envType := util.EnvTypeTesting
+	oldChannels, oldAreas := ChannelIDs[envType], AreaSubscriptions[envType]
+	defer func() {
+		ChannelIDs[envType], AreaSubscriptions[envType] = oldChannels, oldAreas
+		delete(BlockSlugs, "test-nagatinskaya")
+		delete(BlockSlugs, "test-tulskaya")
+	}()
+
+	BlockSlugs["test-nagatinskaya"] = BlockInfo{Slug: "test-nagatinskaya", Metro: "Нагатинская", Region: "Москва"}
+	BlockSlugs["test-tulskaya"] = BlockInfo{Slug: "test-tulskaya", Metro: "Тульская", Region: "Москва"}
+
+	ChannelIDs[envType] = []ChannelInfo{{ChatID: 1, BlockSlug: "test-nagatinskaya"}}
+	AreaSubscriptions[envType] = []AreaSubscription{
+		{ChatID: 1, Region: "Москва"},
+		{ChatID: 1, Metro: "Тульская"},
+		{ChatID: 2, Metro: "Нагатинская"},
+	}
+
+	// the explicit subscription and the duplicates are skipped
+	require.Equal(t, []ChannelInfo{
+		{ChatID: 1, BlockSlug: "test-tulskaya"},
+		{ChatID: 2, BlockSlug: "test-nagatinskaya"},
+	}, GetAreaChannels(envType))
+}
+
+func TestAreaMatchesRadius(t *testing.T) {
+	// the block and the point are ~5.2 km apart
+	area := AreaSubscription{Latitude: 55.729806, Longitude: 37.639206, RadiusKm: 6}
+
+	tests := []struct {
+		area     AreaSubscription
+		block    BlockInfo
+		expected bool
+	}{
+		{area, BlockInfo{Latitude: 55.684019, Longitude: 37.621436}, true},
+		{AreaSubscription{Latitude: 55.729806, Longitude: 37.639206, RadiusKm: 5}, BlockInfo{Latitude: 55.684019, Longitude: 37.621436}, false},
+		{area, BlockInfo{Latitude: 55.729806, Longitude: 37.639206}, true},
+		{area, BlockInfo{}, false}, // not yet downloaded, no coordinates
+		{AreaSubscription{Latitude: 55.729806, Longitude: 37.639206}, BlockInfo{Latitude: 55.729806, Longitude: 37.639206}, false},
+	}
+
+	for i, test := range tests {
+		require.Equal(t, test.expected, test.area.Matches(test.block), fmt.Sprintf("failed case %v", i))
+	}
+
+	distance, ok := area.Distance(BlockInfo{Latitude: 55.684019, Longitude: 37.621436})
+	require.True(t, ok)
+	require.InDelta(t, 5.2, distance, 0.1)
+}
+
+func TestParseNearArgs(t *testing.T) {
+	const chatID, noLocationChatID = 1, 2
+	pendingLocations[chatID] = [2]float64{55.7, 37.6}
+	defer delete(pendingLocations, chatID)
+
+	tests := []struct {
+		chatID   int64
+		args     string
+		expected AreaSubscription
+		isErr    bool
+	}{
+		{chatID, "", AreaSubscription{ChatID: chatID, Latitude: 55.7, Longitude: 37.6, RadiusKm: DefaultRadiusKm}, false},
+		{chatID, "5km", AreaSubscription{ChatID: chatID, Latitude: 55.7, Longitude: 37.6, RadiusKm: 5}, false},
+		{chatID, "55.68 37.62 2.5", AreaSubscription{ChatID: chatID, Latitude: 55.68, Longitude: 37.62, RadiusKm: 2.5}, false},
+		{noLocationChatID, "55.68,37.62", AreaSubscription{ChatID: noLocationChatID, Latitude: 55.68, Longitude: 37.62, RadiusKm: DefaultRadiusKm}, false},
+		{noLocationChatID, "", AreaSubscription{}, true},
+		{noLocationChatID, "3", AreaSubscription{}, true},
+		{chatID, "near", AreaSubscription{}, true},
+		{chatID, "1 2 3 4", AreaSubscription{}, true},
+		{chatID, "95 37 3", AreaSubscription{}, true},
+		{chatID, "0", AreaSubscription{}, true},
+		{chatID, "101", AreaSubscription{}, true},
+	}
+
+	for i, test := range tests {
+		res, err := parseNearArgs(test.chatID, test.args)
+		if test.isErr {
+			require.Error(t, err, fmt.Sprintf("failed case %v", i))
+			continue
+		}
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.expected, res, fmt.Sprintf("failed case %v", i))
+	}
+}
+
+func TestUnmarshallBlocks(t *testing.T) {
+	body := `{"success":true,"data":{"items":[{"id":1240,"name":"Второй Нагатинский","path":"/2ngt",
+"metro":{"id":148,"name":"Нагатинская","color":"#ACADAF"},"location":{"id":2,"name":"Москва"},
+"latitude":55.684019,"longitude":37.621436},{"id":1,"name":"Без координат","path":"nocoords"}]}}`
+
+	blocks, err := UnmarshallBlocks([]byte(body))
+	require.NoError(t, err)
+	require.Equal(t, []BlockInfo{
+		{ID: 1240, Name: "Второй Нагатинский", Slug: "2ngt", Metro: "Нагатинская", Region: "Москва", Latitude: 55.684019, Longitude: 37.621436},
+		{ID: 1, Name: "Без координат", Slug: "nocoords"},
+	}, blocks.BlockList)
+	require.False(t, blocks.BlockList[1].HasCoordinates())
+}
+
+func TestAddAreaPolls(t *testing.T) {
+	oldPolls := lastAreaPolls
+	defer func() { lastAreaPolls = oldPolls }()
+	lastAreaPolls = make(map[string]time.Time)
+
+	now := time.Date(2024, 5, 18, 12, 0, 0, 0, time.UTC)
+	area := []ChannelInfo{{ChatID: 2}}
+	areaSlugs := map[string][]ChannelInfo{"stored": area, "explicit": area, "explicit-new": area}
+	var newSlugs []string
+	for i := 0; i < areaSeedsPerRun+2; i++ {
+		slug := fmt.Sprintf("new-%v", i)
+		areaSlugs[slug] = area
+		newSlugs = append(newSlugs, slug)
+	}
+	stored := func(slug string) bool { return slug == "stored" || slug == "explicit" }
+	explicit := func() map[string][]ChannelInfo {
+		return map[string][]ChannelInfo{"explicit": {{ChatID: 1}}, "explicit-new": {{ChatID: 1}}}
+	}
+
+	// the unstored blocks are seeded a few per run, the explicitly subscribed ones are stored by their own download
+	slugs := explicit()
+	require.Equal(t, newSlugs[:areaSeedsPerRun], addAreaPolls(slugs, areaSlugs, now, stored))
+	require.Equal(t, map[string][]ChannelInfo{
+		"explicit":     {{ChatID: 1}, {ChatID: 2}},
+		"explicit-new": {{ChatID: 1}},
+		"stored":       area,
+	}, slugs)
+
+	// the next run seeds the rest, the stored area block is not due yet
+	slugs = explicit()
+	require.Equal(t, newSlugs[areaSeedsPerRun:], addAreaPolls(slugs, areaSlugs, now.Add(invokeEvery), stored))
+	require.NotContains(t, slugs, "stored")
+
+	require.Empty(t, addAreaPolls(explicit(), areaSlugs, now.Add(2*invokeEvery), stored))
+}
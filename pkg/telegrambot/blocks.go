@@ -3,6 +3,7 @@ package telegrambot
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
 	"log"
 	"os"
 	"strconv"
@@ -12,9 +13,14 @@ import (
 const BlocksFile = "data/blocks.json"
 
 type BlockInfo struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
-	Slug string `json:"slug"`
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	Slug   string `json:"slug"`
+	Metro  string `json:"metro,omitempty"`  // Нагатинская
+	Region string `json:"region,omitempty"` // Москва
+
+	Latitude  float64 `json:"latitude,omitempty"`
+	Longitude float64 `json:"longitude,omitempty"`
 }
 
 type BlockInfoMap map[string]BlockInfo
@@ -54,12 +60,17 @@ func (b BlockInfo) String() string {
 	return fmt.Sprintf("%v: <a href=\"%v\">%v</a>", b.Name, GetBlockURLBySlug(b.Slug), b.Slug)
 }
 
-func (b BlockInfo) StringWithSub(subscribed bool) string {
+func (b BlockInfo) StringWithSub(lang i18n.Lang, subscribed bool, bulks []string, distance string) string {
 	embeddedSlug := embedSlug(b.Slug)
 	if subscribed {
-		return fmt.Sprintf("✅<a href=\"%v\">%v</a> /%v_%v", GetBlockURLBySlug(b.Slug), b.Name, UnsubscribeCommand, embeddedSlug)
+		return fmt.Sprintf("✅<a href=\"%v\">%v</a>%v%v /%v_%v", GetBlockURLBySlug(b.Slug), b.Name, distance, bulksScope(lang, bulks), UnsubscribeCommand, embeddedSlug)
 	}
-	return fmt.Sprintf("<a href=\"%v\">%v</a> /%v_%v", GetBlockURLBySlug(b.Slug), b.Name, SubscribeCommand, embeddedSlug)
+	return fmt.Sprintf("<a href=\"%v\">%v</a>%v /%v_%v", GetBlockURLBySlug(b.Slug), b.Name, distance, SubscribeCommand, embeddedSlug)
+}
+
+// HasCoordinates false for the blocks not yet downloaded from the site
+func (b BlockInfo) HasCoordinates() bool {
+	return b.Latitude != 0 || b.Longitude != 0
 }
 
 func init() {
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/georgri/sledopyt_addresses/pkg/downloader"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
 	"github.com/georgri/sledopyt_addresses/pkg/util"
 	"log"
 	"sort"
@@ -17,13 +18,25 @@ const (
 	UpdateBlocksEvery = 1 * time.Hour
 )
 
+// expected source shape, not yet checked against a captured response (the radius subscriptions need the coordinates):
+// {"success":true,"data":{"items":[{"id":1240,"name":"\u0412\u0442\u043e\u0440\u043e\u0439 \u041d\u0430\u0433\u0430\u0442\u0438\u043d\u0441\u043a\u0438\u0439",
+// "path":"2ngt","metro":{"id":148,"name":"\u041d\u0430\u0433\u0430\u0442\u0438\u043d\u0441\u043a\u0430\u044f","color":"#ACADAF"},
+// "location":{"id":2,"name":"\u041c\u043e\u0441\u043a\u0432\u0430"},"latitude":55.684019,"longitude":37.621436}]}}
 type BlockSiteData struct {
 	Success bool `json:"success"`
 	Data    struct {
 		Items []struct {
-			Id   int64  `json:"id"`
-			Name string `json:"name"`
-			Path string `json:"path"` // = slug
+			Id    int64  `json:"id"`
+			Name  string `json:"name"`
+			Path  string `json:"path"` // = slug
+			Metro struct {
+				Name string `json:"name"`
+			} `json:"metro"`
+			Location struct {
+				Name string `json:"name"` // Москва, Московская область
+			} `json:"location"`
+			Latitude  float64 `json:"latitude"`
+			Longitude float64 `json:"longitude"`
 		} `json:"items"`
 	} `json:"data"`
 }
@@ -35,8 +48,12 @@ func DownloadBlocks() (*BlocksFileData, error) {
 		return nil, fmt.Errorf("error while getting url %v: %v", url, err)
 	}
 
+	return UnmarshallBlocks(body)
+}
+
+func UnmarshallBlocks(body []byte) (*BlocksFileData, error) {
 	blockSiteData := &BlockSiteData{}
-	err = json.Unmarshal(body, blockSiteData)
+	err := json.Unmarshal(body, blockSiteData)
 	if err != nil {
 		return nil, err
 	}
@@ -44,9 +61,14 @@ func DownloadBlocks() (*BlocksFileData, error) {
 	blockData := &BlocksFileData{}
 	for _, block := range blockSiteData.Data.Items {
 		blockData.BlockList = append(blockData.BlockList, BlockInfo{
-			ID:   block.Id,
-			Name: block.Name,
-			Slug: strings.TrimLeft(block.Path, "/"),
+			ID:     block.Id,
+			Name:   block.Name,
+			Slug:   strings.TrimLeft(block.Path, "/"),
+			Metro:  block.Metro.Name,
+			Region: block.Location.Name,
+
+			Latitude:  block.Latitude,
+			Longitude: block.Longitude,
 		})
 	}
 
@@ -97,15 +119,14 @@ func NotifyAboutNewBlocks(newBlocks []BlockInfo) error {
 		return newBlocks[i].Slug < newBlocks[j].Slug
 	})
 
-	var res []string
-	res = append(res, "#NewPikProjects\n")
+	var blocks []string
 	for _, block := range newBlocks {
-		res = append(res, block.String())
+		blocks = append(blocks, block.String())
 	}
-	res = append(res, "\nTo follow new updates, write @pik_checker_bot")
-	msg := strings.Join(res, "\n")
 
-	err := SendToAllKnownChats(msg)
+	err := SendToAllKnownChats(func(lang i18n.Lang) string {
+		return i18n.T(lang, "blocks.new", strings.Join(blocks, "\n"))
+	})
 	if err != nil {
 		return err
 	}
@@ -113,10 +134,11 @@ func NotifyAboutNewBlocks(newBlocks []BlockInfo) error {
 	return nil
 }
 
-func SendToAllKnownChats(msg string) error {
+// SendToAllKnownChats render the message in the language of each chat and send it
+func SendToAllKnownChats(render func(lang i18n.Lang) string) error {
 	chatIDs := GetAllKnownChatIDs()
 	for _, chatID := range chatIDs {
-		err := SendMessage(chatID, msg)
+		err := NotifyChat(chatID, render(ChatLang(chatID)), nil)
 		if err != nil {
 			return err
 		}
@@ -3,8 +3,10 @@ package telegrambot
 import (
 	"fmt"
 	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
 	"github.com/georgri/sledopyt_addresses/pkg/util"
 	"log"
+	"sort"
 	"strings"
 	"time"
 )
@@ -13,51 +15,38 @@ const (
 	DumpCommand        = "dump"
 	SubscribeCommand   = "sub"
 	UnsubscribeCommand = "unsub"
+	BulksCommand       = "bulks"
+
+	dumpGroupPrefix = "group="
 )
 
 func sendHello(chatID int64, username string) {
-	msg := fmt.Sprintf("Hello, %v!", username)
+	msg := i18n.T(ChatLang(chatID), "hello", username)
 	err := SendMessage(chatID, msg)
 	if err != nil {
 		log.Printf("failed to send message %v to chatID %v: %v", msg, chatID, err)
 	}
 }
 
-func sendList(chatID int64) {
-
-	subscribedTo := GetChatSubscriptions(chatID)
-
-	var complexes []string
-	for _, comp := range util.SortedKeys(BlockSlugs) {
-		isSubscribed := subscribedTo[comp]
-		complexes = append(complexes, BlockSlugs[comp].StringWithSub(isSubscribed))
-	}
-	msg := fmt.Sprintf("List of known complexes:\n") + strings.Join(complexes, "\n")
-	err := SendMessage(chatID, msg)
-	if err != nil {
-		log.Printf("failed to send list of all blocks to chatID %v: %v", chatID, err)
-	}
-}
-
-func GetChatSubscriptions(chatID int64) map[string]bool {
+func GetChatSubscriptions(chatID int64) map[string]ChannelInfo {
 	envtype := util.GetEnvType()
-	res := make(map[string]bool, 10)
+	res := make(map[string]ChannelInfo, 10)
 	for _, channel := range ChannelIDs[envtype] {
 		if channel.ChatID == chatID {
-			res[channel.BlockSlug] = true
+			res[channel.BlockSlug] = channel
 		}
 	}
 	return res
 }
 
 func validateSlug(chatID int64, slug string, command string) (string, error) {
-	slug = strings.TrimLeft(strings.TrimSpace(slug), "/")
+	slug = normalizeSlug(slug)
 
 	_, slugIsValid := BlockSlugs[slug]
 
 	if len(slug) == 0 || !slugIsValid {
 		// send help message
-		err := SendMessage(chatID, fmt.Sprintf("usage: /%v [code]\n\nTo get [code] of any complex type /list", command))
+		err := SendMessage(chatID, i18n.T(ChatLang(chatID), "slug.usage", command, ListCommand))
 		if err != nil {
 			return "", fmt.Errorf("failed to send /%v help message: %v", command, err)
 		}
@@ -66,7 +55,30 @@ func validateSlug(chatID int64, slug string, command string) (string, error) {
 	return slug, nil
 }
 
-func sendDump(chatID int64, slug string) {
+// ParseDumpFilter example: "rooms=2 group=layout", the grouping is empty for a line per flat
+func ParseDumpFilter(s string) (flatstorage.FlatFilter, flatstorage.GroupBy, error) {
+	var conditions []string
+	var groupBy flatstorage.GroupBy
+	for _, word := range strings.Fields(s) {
+		if !strings.HasPrefix(strings.ToLower(word), dumpGroupPrefix) {
+			conditions = append(conditions, word)
+			continue
+		}
+		var err error
+		groupBy, err = flatstorage.ParseGroupBy(word[len(dumpGroupPrefix):])
+		if err != nil {
+			return flatstorage.FlatFilter{}, "", err
+		}
+	}
+	filter, err := flatstorage.ParseFlatFilter(strings.Join(conditions, " "))
+	return filter, groupBy, err
+}
+
+// sendDump args: "[code] [filter] [group=layout|bulk|rooms]", e.g. "2ngt rooms=2 price<15m group=layout"
+func sendDump(chatID int64, args string) {
+	slug, filterStr, _ := strings.Cut(strings.TrimSpace(args), " ")
+	settings := GetChatSettings(chatID)
+	lang := settings.Lang()
 
 	slug, err := validateSlug(chatID, slug, DumpCommand)
 	if err != nil {
@@ -74,42 +86,59 @@ func sendDump(chatID int64, slug string) {
 		return
 	}
 
-	var msg string
-
-	// send all known flats for complex with slug "slug"
-	fileName, err := GetStorageFileNameByBlockSlug(slug)
-	if !flatstorage.FileExists(fileName) || flatstorage.FileNotUpdated(fileName) {
-		// force update flats into file
-		msg, err = DownloadAndUpdateFile(slug, 0)
-		if err != nil {
-			log.Printf("failed to download/update flats for slug %v: %v", slug, err)
-			return
-		}
-	} else {
-		allFlatsMessageData, err := flatstorage.ReadFlatStorage(fileName)
+	filter, groupBy, err := ParseDumpFilter(filterStr)
+	if err != nil {
+		err = SendMessage(chatID, fmt.Sprintf("%v\n\n%v", i18n.ErrorText(lang, err), i18n.T(lang, "dump.usage", DumpCommand, DumpCommand, slug, DumpCommand, slug)))
 		if err != nil {
-			log.Printf("failed to read file with flats %v: %v", fileName, err)
-			return
+			log.Printf("failed to send /%v help message to %v: %v", DumpCommand, chatID, err)
 		}
+		return
+	}
 
-		// output recently updated only
-		now := time.Now()
-		allFlatsMessageData.Flats = util.FilterSliceInPlace(allFlatsMessageData.Flats, func(i int) bool {
-			return allFlatsMessageData.Flats[i].RecentlyUpdated(now)
-		})
+	// send all known flats for complex with slug "slug"
+	allFlatsMessageData, err := ReadFreshFlats(slug)
+	if err != nil {
+		log.Printf("failed to read flats of %v: %v", slug, err)
+		return
+	}
+	allFlatsMessageData.ScoreDeals(allFlatsMessageData)
+	allFlatsMessageData = allFlatsMessageData.Filter(filter).Reject(GetChatHidden(chatID).Hides)
 
-		msg = allFlatsMessageData.String()
-		if len(allFlatsMessageData.Flats) == 0 {
-			msg = fmt.Sprintf("No known flats for complex %v", slug)
+	msg := allFlatsMessageData.FormatDisplay(lang, settings.FlatDisplay(), ChatMortgagePayment(settings))
+	if len(groupBy) > 0 {
+		msg = allFlatsMessageData.FormatGrouped(lang, groupBy)
+	}
+	if len(allFlatsMessageData.Flats) == 0 {
+		msg = i18n.T(lang, "dump.empty", slug)
+		if !filter.Empty() {
+			msg = i18n.T(lang, "dump.empty.filter", slug, filter)
 		}
 	}
 
-	err = SendMessageWithPin(chatID, msg, true)
+	err = SendMessageWithOptions(chatID, msg, MessageOptions{Pin: true, ReplyMarkup: BlockKeyboard(chatID, slug)})
 	if err != nil {
 		log.Printf("failed to send list of all blocks to chatID %v: %v", chatID, err)
 	}
 }
 
+// ReadFreshFlats recent flats of the block, downloaded first if the local file is missing or stale
+func ReadFreshFlats(slug string) (*flatstorage.MessageData, error) {
+	RefreshStaleFlats(slug)
+	return ReadRecentFlats(slug)
+}
+
+// RefreshStaleFlats download the flats of the block if the local file is missing or stale
+func RefreshStaleFlats(slug string) {
+	fileName, _ := GetStorageFileNameByBlockSlug(slug)
+	if !flatstorage.FileExists(fileName) || flatstorage.FileNotUpdated(fileName) {
+		// force update flats into file
+		_, err := DownloadAndUpdateFile(slug, 0)
+		if err != nil {
+			log.Printf("failed to download/update flats for slug %v: %v", slug, err)
+		}
+	}
+}
+
 func GetStorageFileNameByBlockSlug(blockSlug string) (string, error) {
 	// guess chatID
 	// TODO: go with empty chatID
@@ -126,11 +155,37 @@ func GetStorageFileNameByBlockSlug(blockSlug string) (string, error) {
 	return flatstorage.GetStorageFileNameByBlockSlugAndChatID(blockSlug, chatID), nil
 }
 
-func AddNewSubscriber(chatID int64, slug string) error {
+// ReadStoredFlats all flats of the block ever seen, including the sold ones
+func ReadStoredFlats(slug string) (*flatstorage.MessageData, error) {
+	fileName, err := GetStorageFileNameByBlockSlug(slug)
+	if err != nil {
+		fileName = flatstorage.GetStorageFileNameByBlockSlugAndEnv(slug)
+	}
+	return flatstorage.ReadFlatStorage(fileName)
+}
+
+// ReadRecentFlats all recently seen flats of the block from the local file
+func ReadRecentFlats(slug string) (*flatstorage.MessageData, error) {
+	msgData, err := ReadStoredFlats(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	// recently updated only
+	now := time.Now()
+	msgData.Flats = util.FilterSliceInPlace(msgData.Flats, func(i int) bool {
+		return msgData.Flats[i].RecentlyUpdated(now)
+	})
+
+	return msgData, nil
+}
+
+func AddNewSubscriber(chatID int64, slug string, bulks []string) error {
 	envtype := util.GetEnvType()
 	ChannelIDs[envtype] = append(ChannelIDs[envtype], ChannelInfo{
 		ChatID:    chatID,
 		BlockSlug: slug,
+		Bulks:     bulks,
 	})
 
 	err := SyncChannelStorageToFile()
@@ -167,6 +222,35 @@ func RemoveSubscriber(chatID int64, slug string) error {
 	return nil
 }
 
+func UpdateSubscriberBulks(chatID int64, slug string, bulks []string) error {
+	envtype := util.GetEnvType()
+
+	for i, subscription := range ChannelIDs[envtype] {
+		if subscription.BlockSlug == slug && subscription.ChatID == chatID {
+			oldBulks := subscription.Bulks
+			ChannelIDs[envtype][i].Bulks = bulks
+			err := SyncChannelStorageToFile()
+			if err != nil {
+				ChannelIDs[envtype][i].Bulks = oldBulks
+				return err
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("chat %v was not subscribed to %v", chatID, slug)
+}
+
+func GetChatSubscription(chatID int64, slug string) (ChannelInfo, bool) {
+	envtype := util.GetEnvType()
+
+	for _, subscription := range ChannelIDs[envtype] {
+		if subscription.BlockSlug == slug && subscription.ChatID == chatID {
+			return subscription, true
+		}
+	}
+	return ChannelInfo{}, false
+}
+
 func CheckSubscribed(chatID int64, slug string) bool {
 	envtype := util.GetEnvType()
 
@@ -178,46 +262,194 @@ func CheckSubscribed(chatID int64, slug string) bool {
 	return false
 }
 
-func subscribeChat(chatID int64, slug string) {
+// splitSlugAndBulks example: "2ngt 1.1 1.3" => "2ngt", ["1.1", "1.3"]
+func splitSlugAndBulks(args string) (string, []string) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	var bulks []string
+	for _, bulk := range fields[1:] {
+		bulks = append(bulks, flatstorage.NormalizeBulk(bulk))
+	}
+	bulks = util.FilterUnique(bulks, func(i int) string {
+		return bulks[i]
+	})
+	sort.Strings(bulks)
+	return fields[0], bulks
+}
 
-	slug, err := validateSlug(chatID, slug, SubscribeCommand)
+// validateBulks checks bulks against the stored flats; unknown block data means any bulk is fine
+func validateBulks(chatID int64, slug string, bulks []string) error {
+	if len(bulks) == 0 {
+		return nil
+	}
+	flats, err := ReadRecentFlats(slug)
+	if err != nil || len(flats.Flats) == 0 {
+		return nil
+	}
+	counts := flats.BulkCounts()
+
+	var unknown []string
+	for _, bulk := range bulks {
+		if _, ok := counts[bulk]; !ok {
+			unknown = append(unknown, bulk)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	lang := ChatLang(chatID)
+	err = SendMessage(chatID, i18n.T(lang, "bulks.unknown", slug, strings.Join(unknown, ", "))+"\n\n"+bulksHint(lang, slug))
 	if err != nil {
-		log.Printf("failed to subscribe %v to slug %v: %v", chatID, slug, err)
+		return fmt.Errorf("failed to send unknown bulks message: %v", err)
+	}
+	return fmt.Errorf("unknown bulks in %v: %v", slug, unknown)
+}
+
+// bulksHint example:
+// Bulks in 2ngt: 1.1 (25), 1.2 (10), 1.3 (7)
+// To subscribe to some of them only: /sub 2ngt 1.1 1.3
+func bulksHint(lang i18n.Lang, slug string) string {
+	flats, err := ReadRecentFlats(slug)
+	if err != nil || len(flats.Flats) == 0 {
+		return ""
+	}
+	counts := flats.BulkCounts()
+
+	var bulks []string
+	for _, bulk := range util.SortedKeys(counts) {
+		bulks = append(bulks, fmt.Sprintf("%v (%v)", bulk, counts[bulk]))
+	}
+
+	return i18n.T(lang, "bulks.hint", slug, strings.Join(bulks, ", "), SubscribeCommand, slug,
+		strings.Join(util.SortedKeys(counts)[:util.Min(2, len(counts))], " "))
+}
+
+func bulksScope(lang i18n.Lang, bulks []string) string {
+	if len(bulks) == 0 {
+		return ""
+	}
+	return i18n.T(lang, "bulks.scope", strings.Join(bulks, ", "))
+}
+
+func sameBulks(a, b []string) bool {
+	return strings.Join(a, " ") == strings.Join(b, " ")
+}
+
+func sendBulks(chatID int64, slug string) {
+	slug, err := validateSlug(chatID, slug, BulksCommand)
+	if err != nil {
+		log.Printf("failed to send bulks to %v: %v", chatID, err)
 		return
 	}
 
-	embeddedSlug := embedSlug(slug)
+	lang := ChatLang(chatID)
+	msg := bulksHint(lang, slug)
+	if len(msg) == 0 {
+		msg = i18n.T(lang, "bulks.empty", slug, DumpCommand, embedSlug(slug))
+	}
+
+	err = SendMessage(chatID, msg)
+	if err != nil {
+		log.Printf("failed to send bulks of %v to chatID %v: %v", slug, chatID, err)
+	}
+}
+
+// SubscriptionResult what addSubscription or removeSubscription did
+type SubscriptionResult int
 
-	if CheckSubscribed(chatID, slug) {
-		// send already subscribed message
-		err = SendMessage(chatID, fmt.Sprintf("You are already subscribed to complex %v.\n"+
-			"To view all flats: /%v_%v", slug, DumpCommand, embeddedSlug))
+const (
+	SubscriptionDone SubscriptionResult = iota
+	SubscriptionChanged
+	SubscriptionAlready // nothing to do: already subscribed with the same bulks or not subscribed at all
+)
+
+// addSubscription subscribe the chat to the valid slug or change the bulks of the subscription
+func addSubscription(chatID int64, slug string, bulks []string) (SubscriptionResult, error) {
+	subscription, ok := GetChatSubscription(chatID, slug)
+	if ok && sameBulks(subscription.Bulks, bulks) {
+		return SubscriptionAlready, nil
+	}
+	if ok {
+		err := UpdateSubscriberBulks(chatID, slug, bulks)
 		if err != nil {
-			log.Printf("failed to send already subscribed message to %v: %v", chatID, err)
+			return SubscriptionChanged, err
 		}
-		log.Printf("chat %v is already subscribed to %v", chatID, slug)
+		return SubscriptionChanged, nil
+	}
+
+	err := AddNewSubscriber(chatID, slug, bulks)
+	if err != nil {
+		return SubscriptionDone, err
+	}
+	return SubscriptionDone, nil
+}
+
+// removeSubscription unsubscribe the chat from the valid slug
+func removeSubscription(chatID int64, slug string) (SubscriptionResult, error) {
+	if !CheckSubscribed(chatID, slug) {
+		return SubscriptionAlready, nil
+	}
+	err := RemoveSubscriber(chatID, slug)
+	if err != nil {
+		return SubscriptionDone, err
+	}
+	return SubscriptionDone, nil
+}
+
+func subscribeChat(chatID int64, args string) {
+
+	slug, bulks := splitSlugAndBulks(args)
+
+	slug, err := validateSlug(chatID, slug, SubscribeCommand)
+	if err != nil {
+		log.Printf("failed to subscribe %v to slug %v: %v", chatID, slug, err)
 		return
 	}
 
-	err = AddNewSubscriber(chatID, slug)
+	err = validateBulks(chatID, slug, bulks)
 	if err != nil {
-		// send something went wrong while subscribing message
-		err = SendMessage(chatID, fmt.Sprintf("Something went wrong while subscribing to %v:\n"+
-			"error: %v\n"+
-			"You can try again later with /%v_%v", slug, err, SubscribeCommand, embeddedSlug))
-		if err != nil {
-			log.Printf("failed to send subscription failed message to %v: %v", chatID, err)
-		}
-		log.Printf("failed to subscribe %v to %v", chatID, slug)
+		log.Printf("failed to subscribe %v to slug %v: %v", chatID, slug, err)
 		return
 	}
 
-	// send message "You are subscribed"
-	err = SendMessage(chatID, fmt.Sprintf("You are now subscribed to new flats from: %v.\n"+
-		"To unsubscribe, click here: /%v_%v\n"+
-		"To get all known flats click here: /%v_%v", slug, UnsubscribeCommand, embeddedSlug, DumpCommand, embeddedSlug))
+	embeddedSlug := embedSlug(slug)
+	lang := ChatLang(chatID)
+
+	result, err := addSubscription(chatID, slug, bulks)
 	if err != nil {
-		log.Printf("failed to send subscribed message to %v: %v", chatID, err)
+		log.Printf("failed to subscribe %v to %v: %v", chatID, slug, err)
+	}
+
+	var msg string
+	var keyboard *InlineKeyboardMarkup
+	switch {
+	case result == SubscriptionAlready:
+		log.Printf("chat %v is already subscribed to %v", chatID, slug)
+		msg = i18n.T(lang, "sub.already", slug, bulksScope(lang, bulks), DumpCommand, embeddedSlug)
+	case result == SubscriptionChanged && err != nil:
+		msg = i18n.T(lang, "sub.change.failed", slug, err)
+	case result == SubscriptionChanged:
+		scope := bulksScope(lang, bulks)
+		if len(scope) == 0 {
+			scope = i18n.T(lang, "bulks.scope.all")
+		}
+		msg = i18n.T(lang, "sub.changed", slug, scope, UnsubscribeCommand, embeddedSlug)
+	case err != nil:
+		msg = i18n.T(lang, "sub.failed", slug, err, SubscribeCommand, embeddedSlug)
+	default:
+		msg = i18n.T(lang, "sub.done", slug, bulksScope(lang, bulks), UnsubscribeCommand, embeddedSlug, DumpCommand, embeddedSlug)
+		if hint := bulksHint(lang, slug); len(bulks) == 0 && len(hint) > 0 {
+			msg += "\n\n" + hint
+		}
+		keyboard = BlockKeyboard(chatID, slug)
+	}
+
+	err = SendMessageWithOptions(chatID, msg, MessageOptions{ReplyMarkup: keyboard})
+	if err != nil {
+		log.Printf("failed to send subscription message to %v: %v", chatID, err)
 	}
 }
 
@@ -229,38 +461,29 @@ func unsubscribeChat(chatID int64, slug string) {
 	}
 
 	embeddedSlug := embedSlug(slug)
+	lang := ChatLang(chatID)
 
-	if !CheckSubscribed(chatID, slug) {
-		// send already subscribed message
-		err = SendMessage(chatID, fmt.Sprintf("You are not currently subscribed to complex %v.\n"+
-			"To subscribe: /%v_%v\n"+
-			"To view all flats: /%v_%v", slug, DumpCommand, embeddedSlug, SubscribeCommand, embeddedSlug))
-		if err != nil {
-			log.Printf("failed to send already unsubscribed message to %v: %v", chatID, err)
-		}
-		log.Printf("chat %v is already unsubscribed to %v", chatID, slug)
-		return
+	result, err := removeSubscription(chatID, slug)
+	if err != nil {
+		log.Printf("failed to unsubscribe %v from %v: %v", chatID, slug, err)
 	}
 
-	err = RemoveSubscriber(chatID, slug)
-	if err != nil {
-		// send something went wrong while unsubscribing message
-		err = SendMessage(chatID, fmt.Sprintf("Something went wrong while unsubscribing from %v:\n"+
-			"error: %v\n"+
-			"You might need to try again later with /%v_%v", slug, err, UnsubscribeCommand, embeddedSlug))
-		if err != nil {
-			log.Printf("failed to send unsubscription failed message to %v: %v", chatID, err)
-		}
-		log.Printf("failed to unsubscribe %v from %v", chatID, slug)
-		return
+	var msg string
+	var keyboard *InlineKeyboardMarkup
+	switch {
+	case result == SubscriptionAlready:
+		log.Printf("chat %v is already unsubscribed to %v", chatID, slug)
+		msg = i18n.T(lang, "unsub.already", slug, SubscribeCommand, embeddedSlug, DumpCommand, embeddedSlug)
+	case err != nil:
+		msg = i18n.T(lang, "unsub.failed", slug, err, UnsubscribeCommand, embeddedSlug)
+	default:
+		msg = i18n.T(lang, "unsub.done", slug, SubscribeCommand, embeddedSlug, DumpCommand, embeddedSlug)
+		keyboard = BlockKeyboard(chatID, slug)
 	}
 
-	// send message "You are unsubscribed"
-	err = SendMessage(chatID, fmt.Sprintf("You were unsubscribed from: %v.\n"+
-		"To subscribe again, click here: /%v_%v\n"+
-		"To get all known flats click here: /%v_%v", slug, SubscribeCommand, embeddedSlug, DumpCommand, embeddedSlug))
+	err = SendMessageWithOptions(chatID, msg, MessageOptions{ReplyMarkup: keyboard})
 	if err != nil {
-		log.Printf("failed to send unsubscribed message to %v: %v", chatID, err)
+		log.Printf("failed to send unsubscription message to %v: %v", chatID, err)
 	}
 }
 
@@ -271,3 +494,8 @@ func embedSlug(slug string) string {
 func unEmbedSlug(slug string) string {
 	return strings.ReplaceAll(strings.ReplaceAll(slug, "__", "/"), "_", "-")
 }
+
+// normalizeSlug the typed slugs may be copied from the commands, example: "/alt_53" => "alt-53", "moskva__x" => "moskva/x"
+func normalizeSlug(slug string) string {
+	return unEmbedSlug(strings.TrimLeft(strings.TrimSpace(slug), "/"))
+}
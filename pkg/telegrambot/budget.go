@@ -0,0 +1,216 @@
+package telegrambot
+
+import (
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/mortgage"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"html"
+	"log"
+	"sort"
+	"strings"
+)
+
+const (
+	BudgetCommand = "budget"
+
+	BudgetPerBlock = 5
+
+	budgetDownPaymentPrefix = "downpayment="
+	budgetDownPaymentAlias  = "dp="
+)
+
+// BudgetQuery example: "14m rooms=2 downpayment=3m sort=meterprice"
+type BudgetQuery struct {
+	Budget      int64
+	DownPayment int64            // overrides the down payment of the chat mortgage settings
+	Mortgage    *mortgage.Params // the payment is estimated if set, see /mortgage
+	Filter      flatstorage.FlatFilter
+	Sort        flatstorage.FlatSort // biggest area first by default
+}
+
+func ParseBudgetQuery(s string) (BudgetQuery, error) {
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		return BudgetQuery{}, i18n.NewError("budget.not.set")
+	}
+	budget, err := flatstorage.ParseFilterNumber(words[0])
+	if err != nil || budget <= 0 {
+		return BudgetQuery{}, i18n.NewError("budget.invalid", words[0])
+	}
+	res := BudgetQuery{Budget: int64(budget), Sort: flatstorage.FlatSort{Field: "area", Desc: true}}
+
+	var conditions []string
+	for _, word := range words[1:] {
+		lower := strings.ToLower(word)
+		switch {
+		case strings.HasPrefix(lower, budgetDownPaymentPrefix), strings.HasPrefix(lower, budgetDownPaymentAlias):
+			_, value, _ := strings.Cut(word, "=")
+			downPayment, err := flatstorage.ParseFilterNumber(value)
+			if err != nil || downPayment < 0 {
+				return BudgetQuery{}, i18n.NewError("downpayment.invalid", value)
+			}
+			res.DownPayment = int64(downPayment)
+			res.Mortgage = &mortgage.Params{DownPayment: res.DownPayment}
+		case strings.HasPrefix(lower, searchSortPrefix):
+			res.Sort, err = flatstorage.ParseFlatSort(word[len(searchSortPrefix):])
+			if err != nil {
+				return BudgetQuery{}, err
+			}
+		default:
+			conditions = append(conditions, word)
+		}
+	}
+
+	res.Filter, err = flatstorage.ParseFlatFilter(strings.Join(conditions, " "))
+	if err != nil {
+		return BudgetQuery{}, err
+	}
+	return res, nil
+}
+
+// Match within the budget and the filter
+func (q BudgetQuery) Match(flat *flatstorage.Flat) bool {
+	return flat.Price <= q.Budget && q.Filter.Match(flat)
+}
+
+// chatBlockScopes explicit and area subscriptions of the chat, slug => bulks
+func chatBlockScopes(chatID int64) map[string][]string {
+	res := make(map[string][]string)
+	for slug, subscription := range GetChatSubscriptions(chatID) {
+		res[slug] = subscription.Bulks
+	}
+	for _, channel := range GetAreaChannels(util.GetEnvType()) {
+		if _, ok := res[channel.BlockSlug]; !ok && channel.ChatID == chatID {
+			res[channel.BlockSlug] = nil
+		}
+	}
+	return res
+}
+
+// BudgetBlock the best flats of the block within the budget
+type BudgetBlock struct {
+	Slug  string
+	Name  string
+	Found int
+	Flats []flatstorage.Flat // up to BudgetPerBlock in the query order
+}
+
+// BudgetBlocks blocks are ranked by their best flat
+func BudgetBlocks(blocks map[string]*flatstorage.MessageData, query BudgetQuery) []BudgetBlock {
+	var res []BudgetBlock
+	for _, slug := range util.SortedKeys(blocks) {
+		found := blocks[slug].Reject(func(flat *flatstorage.Flat) bool {
+			return !query.Match(flat)
+		})
+		if len(found.Flats) == 0 {
+			continue
+		}
+		found.Sort(query.Sort)
+		res = append(res, BudgetBlock{
+			Slug:  slug,
+			Name:  found.Flats[0].BlockName,
+			Found: len(found.Flats),
+			Flats: found.Flats[:util.Min(BudgetPerBlock, len(found.Flats))],
+		})
+	}
+
+	// the best flats of all blocks in the query order define the block order
+	best := &flatstorage.MessageData{}
+	for _, block := range res {
+		best.Flats = append(best.Flats, block.Flats[0])
+	}
+	best.Sort(query.Sort)
+	rank := make(map[string]int, len(best.Flats))
+	for i, flat := range best.Flats {
+		rank[flat.BlockSlug] = i
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return rank[res[i].Slug] < rank[res[j].Slug]
+	})
+	return res
+}
+
+func renderBudget(lang i18n.Lang, query BudgetQuery, blocks []BudgetBlock, display flatstorage.Display) string {
+	var found int
+	for _, block := range blocks {
+		found += block.Found
+	}
+
+	budget := util.ThousandSep(query.Budget, " ")
+	var filter string
+	if !query.Filter.Empty() {
+		filter = ", " + html.EscapeString(query.Filter.String())
+	}
+	if len(blocks) == 0 {
+		return i18n.T(lang, "budget.empty", budget, filter)
+	}
+
+	res := []string{i18n.T(lang, "budget.header", budget, filter, i18n.N(lang, "budget.found", found), len(blocks))}
+	for _, block := range blocks {
+		res = append(res, "", i18n.T(lang, "budget.block", block.Name, block.Found, DumpCommand, embedSlug(block.Slug)))
+		for i := range block.Flats {
+			line := block.Flats[i].FormatDisplay(lang, display)
+			if query.Mortgage != nil {
+				price := block.Flats[i].Price
+				line += " " + i18n.T(lang, "budget.payment", util.ThousandSep(query.Mortgage.MonthlyPayment(price), " ")) +
+					MortgageMarketRate(lang, *query.Mortgage, price)
+			}
+			res = append(res, line)
+		}
+	}
+	if query.Mortgage != nil {
+		res = append(res, "", i18n.T(lang, "budget.mortgage", MortgageString(lang, *query.Mortgage)))
+	}
+	return strings.Join(res, "\n")
+}
+
+// sendBudget example args: "14m rooms=2 downpayment=3m"
+func sendBudget(chatID int64, args string) {
+	lang := ChatLang(chatID)
+
+	query, err := ParseBudgetQuery(args)
+	if err != nil {
+		err = SendMessage(chatID, fmt.Sprintf("%v\n\n%v", i18n.ErrorText(lang, err), i18n.T(lang, "budget.usage", BudgetCommand, BudgetCommand)))
+		if err != nil {
+			log.Printf("failed to send /%v help message to %v: %v", BudgetCommand, chatID, err)
+		}
+		return
+	}
+
+	settings := GetChatSettings(chatID)
+	if settings.Mortgage != nil {
+		params := *settings.Mortgage
+		if query.DownPayment > 0 {
+			params.DownPayment, params.DownPercent = query.DownPayment, 0
+		}
+		query.Mortgage = &params
+	}
+
+	scopes := chatBlockScopes(chatID)
+	if len(scopes) == 0 {
+		err = SendMessage(chatID, i18n.T(lang, "budget.no.subscriptions", SubscribeCommand, ListCommand))
+		if err != nil {
+			log.Printf("failed to send /%v no subscriptions message to %v: %v", BudgetCommand, chatID, err)
+		}
+		return
+	}
+
+	hidden := GetChatHidden(chatID)
+	blocks := make(map[string]*flatstorage.MessageData, len(scopes))
+	for slug, bulks := range scopes {
+		msgData, err := ReadRecentFlats(slug)
+		if err != nil {
+			log.Printf("failed to read flats of %v: %v", slug, err)
+			continue
+		}
+		msgData.ScoreDeals(msgData)
+		blocks[slug] = msgData.FilterBulks(bulks).Reject(hidden.Hides)
+	}
+
+	err = SendMessage(chatID, renderBudget(lang, query, BudgetBlocks(blocks, query), settings.FlatDisplay()))
+	if err != nil {
+		log.Printf("failed to send budget flats to %v: %v", chatID, err)
+	}
+}
@@ -0,0 +1,73 @@
+package telegrambot
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBudgetQuery(t *testing.T) {
+	tests := []struct {
+		args        string
+		budget      int64
+		downPayment int64
+		filter      string
+		sort        string
+		isErr       bool
+	}{
+		{"14000000", 14_000_000, 0, "", "-area", false},
+		{"14m rooms=2 downpayment=3m", 14_000_000, 3_000_000, "rooms=2", "-area", false},
+		{"14.5m dp=2_500k sort=meterprice", 14_500_000, 2_500_000, "", "meterprice", false},
+		{"", 0, 0, "", "", true},
+		{"rooms=2", 0, 0, "", "", true},
+		{"14m downpayment=many", 0, 0, "", "", true},
+		{"14m sort=metro", 0, 0, "", "", true},
+	}
+
+	for i, test := range tests {
+		query, err := ParseBudgetQuery(test.args)
+		if test.isErr {
+			require.Error(t, err, fmt.Sprintf("failed case %v", i))
+			continue
+		}
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.budget, query.Budget, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.downPayment, query.DownPayment, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.filter, query.Filter.String(), fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.sort, query.Sort.String(), fmt.Sprintf("failed case %v", i))
+	}
+}
+
+func TestBudgetBlocks(t *testing.T) {
+	blocks := map[string]*flatstorage.MessageData{
+		"a": {Flats: []flatstorage.Flat{
+			{ID: 1, BlockSlug: "a", Rooms: 2, Area: 50, Price: 13_000_000},
+			{ID: 2, BlockSlug: "a", Rooms: 2, Area: 60, Price: 15_000_000},
+		}},
+		"b": {Flats: []flatstorage.Flat{
+			{ID: 3, BlockSlug: "b", Rooms: 2, Area: 55, Price: 14_000_000},
+			{ID: 4, BlockSlug: "b", Rooms: 1, Area: 40, Price: 9_000_000},
+		}},
+		"c": {Flats: []flatstorage.Flat{
+			{ID: 5, BlockSlug: "c", Rooms: 3, Area: 80, Price: 20_000_000},
+		}},
+	}
+
+	query, err := ParseBudgetQuery("14m downpayment=4m")
+	require.NoError(t, err)
+
+	res := BudgetBlocks(blocks, query)
+	require.Len(t, res, 2)
+	require.Equal(t, "b", res[0].Slug)
+	require.Equal(t, 2, res[0].Found)
+	require.Equal(t, int64(3), res[0].Flats[0].ID)
+	require.Equal(t, "a", res[1].Slug)
+	require.Equal(t, 1, res[1].Found)
+
+	msg := renderBudget(i18n.En, query, res, flatstorage.Display{})
+	require.Contains(t, msg, "💰 Up to 14 000 000R: 3 flats in 2 complexes")
+	require.Contains(t, msg, "Mortgage estimate: down payment 4 000 000R, 18.0%, 30 years")
+}
@@ -0,0 +1,138 @@
+package telegrambot
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CallbackAliasesFile = "data/callback_aliases.json"
+
+	callbackAliasHashLength = 8 // base64 chars of the argument hash, 48 bits
+	callbackAliasTTL        = 30 * 24 * time.Hour
+	callbackAliasesLimit    = 10000 // the least recently used aliases are dropped above the limit
+)
+
+// ErrCallbackExpired the button refers to an alias that was dropped or never existed
+var ErrCallbackExpired = errors.New("callback alias expired")
+
+// CallbackAlias the original argument of the button and when the button was rendered last time
+type CallbackAlias struct {
+	Arg  string    `json:"arg"`
+	Used time.Time `json:"used"`
+}
+
+var (
+	// callbackAliases long slugs and query texts replaced by short ids to fit into callbackDataLimit,
+	// the id is a hash of the argument, so the buttons keep working after restart until the alias expires
+	callbackAliases      = make(map[string]CallbackAlias)
+	callbackAliasesMutex sync.Mutex
+)
+
+func init() {
+	content, err := os.ReadFile(CallbackAliasesFile)
+	if err != nil {
+		log.Printf("unable to read callback aliases file: %v", err)
+		return
+	}
+
+	err = json.Unmarshal(content, &callbackAliases)
+	if err != nil {
+		log.Printf("unable to unmarshal callback aliases file: %v", err)
+	}
+}
+
+func syncCallbackAliasesToFile() error {
+	newContent, err := json.Marshal(callbackAliases)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(CallbackAliasesFile, newContent, 0644)
+}
+
+// callbackAliasID example: "Второй Нагатинский" => "~Xb3kQ9aZ", longer on the hash collision
+func callbackAliasID(arg string, length int) string {
+	hash := sha256.Sum256([]byte(arg))
+	return callbackAliasPrefix + base64.RawURLEncoding.EncodeToString(hash[:])[:length]
+}
+
+func aliasCallbackArg(arg string) string {
+	return aliasCallbackArgAt(arg, time.Now())
+}
+
+func aliasCallbackArgAt(arg string, now time.Time) string {
+	callbackAliasesMutex.Lock()
+	defer callbackAliasesMutex.Unlock()
+
+	var id string
+	for length := callbackAliasHashLength; ; length += callbackAliasHashLength {
+		id = callbackAliasID(arg, length)
+		if alias, ok := callbackAliases[id]; !ok || alias.Arg == arg {
+			break
+		}
+	}
+	_, known := callbackAliases[id]
+	callbackAliases[id] = CallbackAlias{Arg: arg, Used: now}
+	if known {
+		// the refreshed time reaches the file with the next new alias
+		return id
+	}
+
+	pruneCallbackAliases(now)
+	err := syncCallbackAliasesToFile()
+	if err != nil {
+		log.Printf("failed to sync callback aliases: %v", err)
+	}
+	return id
+}
+
+// pruneCallbackAliases drop the expired aliases and the least recently used ones above the limit
+func pruneCallbackAliases(now time.Time) {
+	for id, alias := range callbackAliases {
+		if now.Sub(alias.Used) > callbackAliasTTL {
+			delete(callbackAliases, id)
+		}
+	}
+	if len(callbackAliases) <= callbackAliasesLimit {
+		return
+	}
+	ids := make([]string, 0, len(callbackAliases))
+	for id := range callbackAliases {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return callbackAliases[ids[i]].Used.Before(callbackAliases[ids[j]].Used)
+	})
+	for _, id := range ids[:len(ids)-callbackAliasesLimit] {
+		delete(callbackAliases, id)
+	}
+}
+
+// resolveCallbackArg the original argument of the alias, the argument itself if it is not an alias,
+// ErrCallbackExpired if the alias is unknown
+func resolveCallbackArg(arg string) (string, error) {
+	return resolveCallbackArgAt(arg, time.Now())
+}
+
+func resolveCallbackArgAt(arg string, now time.Time) (string, error) {
+	if !strings.HasPrefix(arg, callbackAliasPrefix) {
+		return arg, nil
+	}
+	callbackAliasesMutex.Lock()
+	defer callbackAliasesMutex.Unlock()
+
+	alias, ok := callbackAliases[arg]
+	if !ok || now.Sub(alias.Used) > callbackAliasTTL {
+		return "", fmt.Errorf("%w: %v", ErrCallbackExpired, arg)
+	}
+	return alias.Arg, nil
+}
@@ -0,0 +1,260 @@
+package telegrambot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CallbackQuery button pressed under a bot message, example json:
+// {"id":"1112308409475384614","from":{"id":258990915,"is_bot":false,"first_name":"Georgy","username":"georgri","language_code":"ru"},
+// "message":{"message_id":250,"chat":{"id":258990915,"type":"private"},"date":1716056923,"text":"..."},
+// "chat_instance":"-3245476712345","data":"sub|2ngt|b"}
+type CallbackQuery struct {
+	Id   string `json:"id"`
+	From struct {
+		Id           int64  `json:"id"`
+		Username     string `json:"username"`
+		LanguageCode string `json:"language_code"`
+	} `json:"from"`
+	Message *struct {
+		MessageId int64 `json:"message_id"`
+		Chat      struct {
+			Id int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message,omitempty"`
+	Data string `json:"data"`
+}
+
+type BotMethodResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int64           `json:"error_code"`
+	Description string          `json:"description"`
+}
+
+// botApiUrl the base of the Bot API methods, replaced in tests
+var botApiUrl = "https://api.telegram.org"
+
+// CallBotMethod post the values to https://api.telegram.org/bot{token}/{method}
+func CallBotMethod(method string, values url.Values) (json.RawMessage, error) {
+	methodUrl := fmt.Sprintf("%v/bot%v/%v", botApiUrl, util.GetBotToken(), method)
+
+	resp, err := http.PostForm(methodUrl, values)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading Body of %v: %v", method, err)
+	}
+
+	response := &BotMethodResponse{}
+	err = json.Unmarshal(body, response)
+	if err != nil {
+		return nil, fmt.Errorf("error while unmarshalling Body: %v", string(body))
+	}
+
+	if !response.OK {
+		return nil, fmt.Errorf("%v response is not OK: %v", method, string(body))
+	}
+
+	return response.Result, nil
+}
+
+func AnswerCallbackQuery(queryID string, text string) error {
+	_, err := CallBotMethod("answerCallbackQuery", url.Values{
+		"callback_query_id": []string{queryID},
+		"text":              []string{text},
+	})
+	return err
+}
+
+func EditMessageReplyMarkup(chatID int64, messageID int64, markup *InlineKeyboardMarkup) error {
+	if markup == nil {
+		markup = &InlineKeyboardMarkup{InlineKeyboard: [][]InlineKeyboardButton{}}
+	}
+	content, err := json.Marshal(markup)
+	if err != nil {
+		return err
+	}
+	_, err = CallBotMethod("editMessageReplyMarkup", url.Values{
+		"chat_id":      []string{fmt.Sprintf("%v", chatID)},
+		"message_id":   []string{fmt.Sprintf("%v", messageID)},
+		"reply_markup": []string{string(content)},
+	})
+	return err
+}
+
+func EditMessageText(chatID int64, messageID int64, text string, markup *InlineKeyboardMarkup) error {
+	values := url.Values{
+		"chat_id":                  []string{fmt.Sprintf("%v", chatID)},
+		"message_id":               []string{fmt.Sprintf("%v", messageID)},
+		"text":                     []string{text},
+		"parse_mode":               []string{"HTML"},
+		"disable_web_page_preview": []string{"True"},
+	}
+	if markup != nil {
+		content, err := json.Marshal(markup)
+		if err != nil {
+			return err
+		}
+		values.Set("reply_markup", string(content))
+	}
+	_, err := CallBotMethod("editMessageText", values)
+	return err
+}
+
+func processCallbackQuery(query *CallbackQuery) {
+	if query == nil || query.Message == nil {
+		return
+	}
+	chatID := query.Message.Chat.Id
+	messageID := query.Message.MessageId
+
+	data, err := DecodeCallbackData(query.Data)
+	if errors.Is(err, ErrCallbackExpired) {
+		answerCallback(query, i18n.T(ChatLang(chatID), "button.expired"))
+		return
+	}
+	if err != nil {
+		log.Printf("failed to decode callback data from %v: %v", chatID, err)
+		answerCallback(query, i18n.T(ChatLang(chatID), "button.unknown"))
+		return
+	}
+	if quickCallbacks[data.Action] {
+		answerCallback(query, handleCallback(chatID, messageID, data))
+		return
+	}
+
+	// stop the button spinner before the slow work, the callback query expires otherwise
+	answerCallback(query, "")
+	if res := handleCallback(chatID, messageID, data); len(res) > 0 {
+		err = SendMessage(chatID, res)
+		if err != nil {
+			log.Printf("failed to send callback result to %v: %v", chatID, err)
+		}
+	}
+}
+
+// quickCallbacks are answered with their result, the rest are answered right away and may send messages later
+var quickCallbacks = map[string]bool{
+	CallbackSubscribe:   true,
+	CallbackUnsubscribe: true,
+	CallbackWatch:       true,
+	CallbackUnwatch:     true,
+	CallbackHide:        true,
+	CallbackUnhide:      true,
+}
+
+func answerCallback(query *CallbackQuery, text string) {
+	err := AnswerCallbackQuery(query.Id, text)
+	if err != nil {
+		log.Printf("failed to answer callback query %v: %v", query.Id, err)
+	}
+}
+
+// handleCallback returns a short text for the user: the callback answer of the quickCallbacks, a message otherwise
+func handleCallback(chatID int64, messageID int64, data CallbackData) string {
+	slug := data.Arg(0)
+	switch data.Action {
+	case CallbackSubscribe, CallbackUnsubscribe:
+		res := toggleSubscription(chatID, slug, data.Action == CallbackSubscribe)
+		refreshKeyboard(chatID, messageID, data)
+		return res
+	case CallbackDump:
+		sendDump(chatID, strings.Join(data.Args, " "))
+		return ""
+	case CallbackPlans:
+		sendBlockPlans(chatID, strings.Join(data.Args, " "))
+		return ""
+	case CallbackWatch:
+		res := watchFlat(chatID, data.Arg(0))
+		refreshKeyboard(chatID, messageID, data)
+		return res
+	case CallbackUnwatch:
+		res := unwatchFlat(chatID, data.Arg(0))
+		refreshKeyboard(chatID, messageID, data)
+		return res
+	case CallbackHide:
+		res := hideFlat(chatID, strings.TrimSpace(data.Arg(0)+" "+data.Arg(2)))
+		refreshKeyboard(chatID, messageID, data)
+		return res
+	case CallbackUnhide:
+		res := unhideFlat(chatID, data.Arg(0))
+		refreshKeyboard(chatID, messageID, data)
+		return res
+	case CallbackChart:
+		sendChart(chatID, data.Arg(0))
+		return ""
+	case CallbackSearchPage:
+		return editSearchPage(chatID, messageID, data.Args)
+	case CallbackListPage:
+		editListPage(chatID, messageID, ParseListQueryArgs(data.Args))
+		return ""
+	}
+	log.Printf("unknown callback action from %v: %v", chatID, data.Action)
+	return i18n.T(ChatLang(chatID), "button.unknown")
+}
+
+// toggleSubscription the button answer instead of the messages of /sub and /unsub
+func toggleSubscription(chatID int64, slug string, subscribe bool) string {
+	lang := ChatLang(chatID)
+	if _, ok := BlockSlugs[slug]; !ok {
+		return i18n.T(lang, "button.unknown")
+	}
+
+	var result SubscriptionResult
+	var err error
+	if subscribe {
+		result, err = addSubscription(chatID, slug, nil)
+	} else {
+		result, err = removeSubscription(chatID, slug)
+	}
+	switch {
+	case err != nil:
+		log.Printf("failed to change subscription of %v to %v: %v", chatID, slug, err)
+		return i18n.T(lang, "button.sub.failed", slug)
+	case result == SubscriptionAlready && subscribe:
+		return i18n.T(lang, "button.sub.already", slug)
+	case result == SubscriptionAlready:
+		return i18n.T(lang, "button.unsub.already", slug)
+	case subscribe:
+		return i18n.T(lang, "button.subscribed", slug)
+	}
+	return i18n.T(lang, "button.unsubscribed", slug)
+}
+
+// refreshKeyboard update the button state in place,
+// data example: "unsub|2ngt|l|2s" => unsubscribed from 2ngt on the 3rd page of the subscriptions list
+func refreshKeyboard(chatID int64, messageID int64, data CallbackData) {
+	switch data.Arg(1) {
+	case keyboardBlock:
+		err := EditMessageReplyMarkup(chatID, messageID, BlockKeyboard(chatID, data.Arg(0)))
+		if err != nil {
+			log.Printf("failed to refresh keyboard of message %v in %v: %v", messageID, chatID, err)
+		}
+	case keyboardFlat:
+		id, _ := parseFlatID(data.Arg(0))
+		flat, ok := FindFlat(id)
+		if !ok {
+			return
+		}
+		err := EditMessageReplyMarkup(chatID, messageID, FlatKeyboard(chatID, flat))
+		if err != nil {
+			log.Printf("failed to refresh keyboard of message %v in %v: %v", messageID, chatID, err)
+		}
+	case keyboardList:
+		// the text has subscription marks too
+		editListPage(chatID, messageID, ParseListQueryArgs(data.Args[2:]))
+	}
+}
@@ -19,8 +19,9 @@ type ChannelsFileMap map[string]ChannelFileList
 type ChannelFileList []ChannelInfo
 
 type ChannelInfo struct {
-	ChatID    int64  `json:"chat_id"`
-	BlockSlug string `json:"block_slug"` // real estate project, e.g 2ngt, utnv
+	ChatID    int64    `json:"chat_id"`
+	BlockSlug string   `json:"block_slug"`      // real estate project, e.g 2ngt, utnv
+	Bulks     []string `json:"bulks,omitempty"` // short bulk names, e.g. 1.1, 1.3; empty means the whole block
 }
 
 func init() {
@@ -68,8 +69,9 @@ func MergeChannelsWithHardcode(channels *ChannelsFileData) error {
 			return fmt.Errorf("unknown envtype: %v", envTypeStr)
 		}
 
-		oldList := ChannelIDs[envType]
-		oldList = append(oldList, channelList...)
+		// file goes first: it keeps the latest subscription scope
+		oldList := append(ChannelFileList{}, channelList...)
+		oldList = append(oldList, ChannelIDs[envType]...)
 
 		oldList = util.FilterUnique(oldList, func(i int) string {
 			return fmt.Sprintf("%v_%v", oldList[i].BlockSlug, oldList[i].ChatID)
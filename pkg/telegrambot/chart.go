@@ -0,0 +1,112 @@
+package telegrambot
+
+import (
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/chart"
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"log"
+	"strings"
+	"time"
+)
+
+const ChartCommand = "chart"
+
+// pricePointsSeries dates of the points are RFC3339 or "2006-01-02", unparsable ones are skipped
+func pricePointsSeries(label string, points []flatstorage.PricePoint) chart.Series {
+	series := chart.Series{Label: label}
+	for _, point := range points {
+		date, err := time.Parse(time.RFC3339, point.Date)
+		if err != nil {
+			date, err = time.Parse(time.DateOnly, point.Date)
+		}
+		if err != nil {
+			continue
+		}
+		series.Points = append(series.Points, chart.Point{X: date, Y: float64(point.Price)})
+	}
+	return series
+}
+
+// BlockChart median price per m2 by day, a line for each number of rooms
+func BlockChart(msgData *flatstorage.MessageData) chart.Chart {
+	res := chart.Chart{YFormat: chart.FormatThousands}
+	history := msgData.MedianMeterPriceHistory()
+	for _, rooms := range util.SortedKeys(history) {
+		res.Series = append(res.Series, pricePointsSeries(fmt.Sprintf("%vr", rooms), history[rooms]))
+	}
+	return res
+}
+
+// FlatChart price history of the single flat
+func FlatChart(flat *flatstorage.Flat) chart.Chart {
+	return chart.Chart{
+		YFormat: chart.FormatMillions,
+		Series:  []chart.Series{pricePointsSeries("", flat.PricePoints())},
+	}
+}
+
+// sendChart example args: "2ngt" for the block chart or "819556" for the flat price history
+func sendChart(chatID int64, args string) {
+	arg, _, _ := strings.Cut(strings.TrimSpace(args), " ")
+	if _, ok := BlockSlugs[normalizeSlug(arg)]; !ok {
+		if id, ok := parseFlatID(arg); ok {
+			sendFlatChart(chatID, id)
+			return
+		}
+	}
+
+	slug, err := validateSlug(chatID, arg, ChartCommand)
+	if err != nil {
+		log.Printf("failed to send chart to %v: %v", chatID, err)
+		return
+	}
+
+	RefreshStaleFlats(slug)
+	msgData, err := ReadStoredFlats(slug)
+	if err != nil {
+		log.Printf("failed to read flats of %v: %v", slug, err)
+		return
+	}
+
+	lang := ChatLang(chatID)
+	caption := i18n.T(lang, "chart.block", BlockSlugs[slug].Name, flatstorage.HistoryDays)
+	sendChartImage(chatID, fmt.Sprintf("%v.png", slug), BlockChart(msgData), caption)
+}
+
+func sendFlatChart(chatID int64, id int64) {
+	lang := ChatLang(chatID)
+
+	flat, found := FindFlat(id)
+	if !found {
+		err := SendMessage(chatID, i18n.T(lang, "card.not.found", id))
+		if err != nil {
+			log.Printf("failed to send /%v not found message to %v: %v", ChartCommand, chatID, err)
+		}
+		return
+	}
+
+	caption := i18n.T(lang, "chart.flat", id, flat.BlockName)
+	sendChartImage(chatID, fmt.Sprintf("%v.png", id), FlatChart(flat), caption)
+}
+
+func sendChartImage(chatID int64, fileName string, c chart.Chart, caption string) {
+	if c.Empty() {
+		err := SendMessage(chatID, i18n.T(ChatLang(chatID), "chart.empty"))
+		if err != nil {
+			log.Printf("failed to send empty chart message to %v: %v", chatID, err)
+		}
+		return
+	}
+
+	content, err := c.PNG()
+	if err != nil {
+		log.Printf("failed to render chart %v: %v", fileName, err)
+		return
+	}
+	err = SendPhoto(chatID, fileName, content, caption)
+	if err != nil {
+		log.Printf("failed to send chart %v to %v: %v", fileName, chatID, err)
+	}
+}
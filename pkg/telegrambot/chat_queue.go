@@ -0,0 +1,312 @@
+package telegrambot
+
+import (
+	"encoding/json"
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ChatQueueFile = "data/chat_queue.json"
+
+	sendDigestsEvery = 1 * time.Minute
+)
+
+// ChatQueue durable queue of everything not yet delivered to the chat
+type ChatQueue struct {
+	Flats    []flatstorage.Flat `json:"flats,omitempty"`
+	Messages []string           `json:"messages,omitempty"` // e.g. repricing summaries
+	Held     []string           `json:"held,omitempty"`     // ready messages held during quiet hours
+}
+
+type ChatQueueFileMap map[string]map[int64]*ChatQueue
+
+var (
+	ChatQueues     = make(map[util.EnvType]map[int64]*ChatQueue)
+	chatQueueMutex sync.Mutex
+)
+
+func init() {
+	content, err := os.ReadFile(ChatQueueFile)
+	if err != nil {
+		log.Printf("unable to read chat queue file: %v", err)
+		return
+	}
+
+	fileMap := make(ChatQueueFileMap)
+	err = json.Unmarshal(content, &fileMap)
+	if err != nil {
+		log.Printf("unable to unmarshal chat queue file: %v", err)
+		return
+	}
+
+	for envTypeStr, queues := range fileMap {
+		envType, ok := util.EnvTypeFromString[envTypeStr]
+		if !ok {
+			log.Printf("unknown envtype in chat queue file: %v", envTypeStr)
+			continue
+		}
+		ChatQueues[envType] = queues
+	}
+}
+
+func syncChatQueuesToFile() error {
+	fileMap := make(ChatQueueFileMap, len(ChatQueues))
+	for envtype, queues := range ChatQueues {
+		fileMap[envtype.String()] = queues
+	}
+	newContent, err := json.Marshal(fileMap)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ChatQueueFile, newContent, 0644)
+}
+
+// getChatQueue must be called under chatQueueMutex
+func getChatQueue(chatID int64) *ChatQueue {
+	envtype := util.GetEnvType()
+	if ChatQueues[envtype] == nil {
+		ChatQueues[envtype] = make(map[int64]*ChatQueue)
+	}
+	queue, ok := ChatQueues[envtype][chatID]
+	if !ok {
+		queue = &ChatQueue{}
+		ChatQueues[envtype][chatID] = queue
+	}
+	return queue
+}
+
+// EnqueueForChat keep the update until the next digest of the chat
+func EnqueueForChat(chatID int64, update *flatstorage.BlockUpdate) error {
+	chatQueueMutex.Lock()
+	defer chatQueueMutex.Unlock()
+
+	queue := getChatQueue(chatID)
+
+	if update.NewFlats != nil {
+		queue.Flats = append(queue.Flats, update.NewFlats.Flats...)
+		queue.Flats = util.FilterUnique(queue.Flats, func(i int) int64 {
+			return queue.Flats[i].ID
+		})
+	}
+	if update.Repricing.IsWave() {
+		queue.Messages = append(queue.Messages, update.Repricing.Format(ChatLang(chatID)))
+	}
+
+	return syncChatQueuesToFile()
+}
+
+// HoldForChat keep the ready message until the quiet hours of the chat end
+func HoldForChat(chatID int64, msg string) error {
+	chatQueueMutex.Lock()
+	defer chatQueueMutex.Unlock()
+
+	queue := getChatQueue(chatID)
+	queue.Held = append(queue.Held, msg)
+
+	return syncChatQueuesToFile()
+}
+
+// RequeueForChat put the popped queue back in front of anything queued meanwhile
+func RequeueForChat(chatID int64, popped *ChatQueue) error {
+	chatQueueMutex.Lock()
+	defer chatQueueMutex.Unlock()
+
+	queue := getChatQueue(chatID)
+	queue.Flats = append(popped.Flats, queue.Flats...)
+	queue.Messages = append(popped.Messages, queue.Messages...)
+	queue.Held = append(popped.Held, queue.Held...)
+
+	return syncChatQueuesToFile()
+}
+
+// PopChatQueue remove the digest part of the chat queue and return it
+func PopChatQueue(chatID int64) (*ChatQueue, error) {
+	return popChatQueue(chatID, func(queue, popped *ChatQueue) {
+		popped.Flats, queue.Flats = queue.Flats, nil
+		popped.Messages, queue.Messages = queue.Messages, nil
+	})
+}
+
+// PopHeldMessages remove the messages held during quiet hours and return them
+func PopHeldMessages(chatID int64) (*ChatQueue, error) {
+	return popChatQueue(chatID, func(queue, popped *ChatQueue) {
+		popped.Held, queue.Held = queue.Held, nil
+	})
+}
+
+func popChatQueue(chatID int64, move func(queue, popped *ChatQueue)) (*ChatQueue, error) {
+	chatQueueMutex.Lock()
+	defer chatQueueMutex.Unlock()
+
+	envtype := util.GetEnvType()
+	queue, ok := ChatQueues[envtype][chatID]
+	if !ok {
+		return &ChatQueue{}, nil
+	}
+	backup := *queue
+
+	popped := &ChatQueue{}
+	move(queue, popped)
+	if queue.Empty() && len(queue.Held) == 0 {
+		delete(ChatQueues[envtype], chatID)
+	}
+
+	err := syncChatQueuesToFile()
+	if err != nil {
+		ChatQueues[envtype][chatID] = &backup
+		return nil, err
+	}
+	return popped, nil
+}
+
+// Empty true if there is nothing for the digest
+func (q *ChatQueue) Empty() bool {
+	return q == nil || len(q.Flats) == 0 && len(q.Messages) == 0
+}
+
+// DigestString example:
+// 📬 Digest: 12 new flats in 2 complexes
+//
+// 5 new flats in Второй Нагатинский:
+// ...
+// blockDistance (may be nil) adds the distance line of the radius subscriptions, the same as the instant delivery
+func (q *ChatQueue) DigestString(lang i18n.Lang, display flatstorage.Display, blockDistance func(blockSlug string) (float64, bool)) string {
+	if q.Empty() {
+		return ""
+	}
+
+	blocks := make(map[string]*flatstorage.MessageData)
+	for _, flat := range q.Flats {
+		if _, ok := blocks[flat.BlockName]; !ok {
+			blocks[flat.BlockName] = &flatstorage.MessageData{}
+		}
+		blocks[flat.BlockName].Flats = append(blocks[flat.BlockName].Flats, flat)
+	}
+
+	res := []string{i18n.T(lang, "digest.header", i18n.N(lang, "flats.new", len(q.Flats)), i18n.N(lang, "complexes", len(blocks)))}
+	res = append(res, q.Messages...)
+
+	for _, name := range util.SortedKeys(blocks) {
+		msg := blocks[name].FormatDisplay(lang, display, nil)
+		if blockDistance != nil {
+			if distance, ok := blockDistance(blocks[name].GetBlockSlug()); ok {
+				msg = i18n.T(lang, "area.from.point", distance) + "\n" + msg
+			}
+		}
+		res = append(res, msg)
+	}
+
+	return strings.Join(res, "\n\n")
+}
+
+// SendScheduledForever sends digests and releases messages held during quiet hours
+func SendScheduledForever() {
+	for {
+		SendScheduledOnce(time.Now())
+		time.Sleep(sendDigestsEvery)
+	}
+}
+
+func SendScheduledOnce(now time.Time) {
+	for _, settings := range GetAllChatSettings() {
+		quiet := settings.InQuietHours(now)
+		if quiet && settings.QuietModeOrDefault() == QuietHold {
+			continue
+		}
+		options := MessageOptions{Silent: quiet}
+
+		err := SendHeldMessages(settings.ChatID, options)
+		if err != nil {
+			log.Printf("failed to send held messages to %v: %v", settings.ChatID, err)
+		}
+
+		if settings.MarketReportDue(now) {
+			SendMarketReports(settings.ChatID, now, options)
+			settings = GetChatSettings(settings.ChatID)
+			settings.LastMarketReport = now.Format(time.RFC3339)
+			err = SetChatSettings(settings)
+			if err != nil {
+				log.Printf("failed to save last market report time of %v: %v", settings.ChatID, err)
+			}
+		}
+
+		if !settings.DigestDue(now) {
+			continue
+		}
+		err = SendDigest(settings.ChatID, options)
+		if err != nil {
+			log.Printf("failed to send digest to %v: %v", settings.ChatID, err)
+			continue
+		}
+		settings = GetChatSettings(settings.ChatID)
+		settings.LastDigest = now.Format(time.RFC3339)
+		err = SetChatSettings(settings)
+		if err != nil {
+			log.Printf("failed to save last digest time of %v: %v", settings.ChatID, err)
+		}
+	}
+}
+
+func SendDigest(chatID int64, options MessageOptions) error {
+	queue, err := PopChatQueue(chatID)
+	if err != nil {
+		return err
+	}
+	if queue.Empty() {
+		return nil
+	}
+
+	settings := GetChatSettings(chatID)
+	err = SendMessageWithOptions(chatID, queue.DigestString(settings.Lang(), settings.FlatDisplay(), func(blockSlug string) (float64, bool) {
+		return GetChatBlockDistance(chatID, BlockSlugs[blockSlug])
+	}), options)
+	if err != nil {
+		// put it back for the next try
+		requeueErr := RequeueForChat(chatID, queue)
+		if requeueErr != nil {
+			log.Printf("failed to requeue digest of %v: %v", chatID, requeueErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func SendHeldMessages(chatID int64, options MessageOptions) error {
+	queue, err := PopHeldMessages(chatID)
+	if err != nil {
+		return err
+	}
+	if len(queue.Held) == 0 {
+		return nil
+	}
+
+	err = SendMessageWithOptions(chatID, strings.Join(queue.Held, "\n\n"), options)
+	if err != nil {
+		requeueErr := RequeueForChat(chatID, queue)
+		if requeueErr != nil {
+			log.Printf("failed to requeue held messages of %v: %v", chatID, requeueErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// NotifyChat send an automatic (not requested) message respecting the quiet hours of the chat,
+// the keyboard (may be nil) is dropped if the message is held
+func NotifyChat(chatID int64, msg string, keyboard *InlineKeyboardMarkup) error {
+	settings := GetChatSettings(chatID)
+	if !settings.InQuietHours(time.Now()) {
+		return SendMessageWithOptions(chatID, msg, MessageOptions{ReplyMarkup: keyboard})
+	}
+	if settings.QuietModeOrDefault() == QuietSilent {
+		return SendMessageWithOptions(chatID, msg, MessageOptions{Silent: true, ReplyMarkup: keyboard})
+	}
+	return HoldForChat(chatID, msg)
+}
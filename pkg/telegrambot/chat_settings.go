@@ -0,0 +1,331 @@
+package telegrambot
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/mortgage"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // chat timezones must work on hosts without tzdata
+)
+
+const (
+	ChatSettingsFile = "data/chat_settings.json"
+
+	DefaultTimezone = "Europe/Moscow"
+
+	MarketReportTime = "09:00" // on Mondays
+)
+
+type DeliveryMode string
+
+const (
+	DeliveryInstant DeliveryMode = "instant"
+	DeliveryHourly  DeliveryMode = "hourly"
+	DeliveryDaily   DeliveryMode = "daily"
+	DeliveryWeekly  DeliveryMode = "weekly"
+)
+
+type QuietMode string
+
+const (
+	QuietHold   QuietMode = "hold"   // keep messages until the quiet window ends
+	QuietSilent QuietMode = "silent" // send with disable_notification
+)
+
+// ChatSettings per-chat preferences, zero value means defaults
+type ChatSettings struct {
+	ChatID   int64  `json:"chat_id"`
+	Timezone string `json:"timezone,omitempty"` // Europe/Moscow
+
+	DeliveryMode  DeliveryMode `json:"delivery_mode,omitempty"`
+	DigestTime    string       `json:"digest_time,omitempty"`    // HH:MM for daily and weekly digests
+	DigestWeekday time.Weekday `json:"digest_weekday,omitempty"` // for weekly digests
+	LastDigest    string       `json:"last_digest,omitempty"`    // RFC3339
+
+	QuietFrom string    `json:"quiet_from,omitempty"` // HH:MM, e.g. 23:00
+	QuietTo   string    `json:"quiet_to,omitempty"`   // HH:MM, e.g. 08:00
+	QuietMode QuietMode `json:"quiet_mode,omitempty"`
+
+	Language     i18n.Lang `json:"language,omitempty"`      // set with /lang, overrides LanguageCode
+	LanguageCode string    `json:"language_code,omitempty"` // of the last user who wrote to the chat
+
+	DealsOnly bool `json:"deals_only,omitempty"` // notify only about the flats cheaper than the similar ones
+
+	MarketReport     bool   `json:"market_report,omitempty"`      // weekly sell-through report of the subscribed blocks
+	LastMarketReport string `json:"last_market_report,omitempty"` // RFC3339
+
+	Mortgage *mortgage.Params `json:"mortgage,omitempty"` // set with /mortgage, the payments are shown on flat cards and in /dump
+
+	Display *flatstorage.Display `json:"display,omitempty"` // sorting and columns of the flat lists, set with /display
+}
+
+type ChatSettingsFileMap map[string][]ChatSettings
+
+var (
+	ChatSettingsMap   = make(map[util.EnvType]map[int64]ChatSettings)
+	chatSettingsMutex sync.Mutex
+)
+
+func init() {
+	content, err := os.ReadFile(ChatSettingsFile)
+	if err != nil {
+		log.Printf("unable to read chat settings file: %v", err)
+		return
+	}
+
+	fileMap := make(ChatSettingsFileMap)
+	err = json.Unmarshal(content, &fileMap)
+	if err != nil {
+		log.Printf("unable to unmarshal chat settings file: %v", err)
+		return
+	}
+
+	for envTypeStr, settingsList := range fileMap {
+		envType, ok := util.EnvTypeFromString[envTypeStr]
+		if !ok {
+			log.Printf("unknown envtype in chat settings file: %v", envTypeStr)
+			continue
+		}
+		ChatSettingsMap[envType] = make(map[int64]ChatSettings, len(settingsList))
+		for _, settings := range settingsList {
+			ChatSettingsMap[envType][settings.ChatID] = settings
+		}
+	}
+}
+
+func syncChatSettingsToFile() error {
+	fileMap := make(ChatSettingsFileMap, len(ChatSettingsMap))
+	for envtype, settingsMap := range ChatSettingsMap {
+		for _, chatID := range util.SortedKeys(settingsMap) {
+			fileMap[envtype.String()] = append(fileMap[envtype.String()], settingsMap[chatID])
+		}
+	}
+	newContent, err := json.Marshal(fileMap)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ChatSettingsFile, newContent, 0644)
+}
+
+// GetChatSettings settings of the chat, defaults for unknown chats
+func GetChatSettings(chatID int64) ChatSettings {
+	chatSettingsMutex.Lock()
+	defer chatSettingsMutex.Unlock()
+
+	settings, ok := ChatSettingsMap[util.GetEnvType()][chatID]
+	if !ok {
+		settings = ChatSettings{ChatID: chatID}
+	}
+	return settings
+}
+
+// GetAllChatSettings settings of all chats with non-default settings
+func GetAllChatSettings() []ChatSettings {
+	chatSettingsMutex.Lock()
+	defer chatSettingsMutex.Unlock()
+
+	settingsMap := ChatSettingsMap[util.GetEnvType()]
+	res := make([]ChatSettings, 0, len(settingsMap))
+	for _, chatID := range util.SortedKeys(settingsMap) {
+		res = append(res, settingsMap[chatID])
+	}
+	return res
+}
+
+// SetChatSettings update the settings and persist them
+func SetChatSettings(settings ChatSettings) error {
+	chatSettingsMutex.Lock()
+	defer chatSettingsMutex.Unlock()
+
+	envtype := util.GetEnvType()
+	if ChatSettingsMap[envtype] == nil {
+		ChatSettingsMap[envtype] = make(map[int64]ChatSettings)
+	}
+	oldSettings, existed := ChatSettingsMap[envtype][settings.ChatID]
+	ChatSettingsMap[envtype][settings.ChatID] = settings
+
+	err := syncChatSettingsToFile()
+	if err != nil {
+		if existed {
+			ChatSettingsMap[envtype][settings.ChatID] = oldSettings
+		} else {
+			delete(ChatSettingsMap[envtype], settings.ChatID)
+		}
+		return err
+	}
+	return nil
+}
+
+// Location chat timezone, Moscow by default
+func (s ChatSettings) Location() *time.Location {
+	name := s.Timezone
+	if len(name) == 0 {
+		name = DefaultTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("unable to load timezone %v of chat %v: %v", name, s.ChatID, err)
+		return time.UTC
+	}
+	return loc
+}
+
+func (s ChatSettings) Mode() DeliveryMode {
+	if len(s.DeliveryMode) == 0 {
+		return DeliveryInstant
+	}
+	return s.DeliveryMode
+}
+
+// NextDigestAfter the first scheduled digest time strictly after t
+func (s ChatSettings) NextDigestAfter(t time.Time) time.Time {
+	t = t.In(s.Location())
+
+	if s.Mode() == DeliveryHourly {
+		return t.Truncate(time.Hour).Add(time.Hour)
+	}
+
+	hour, minute, err := ParseClock(s.DigestTime)
+	if err != nil {
+		hour, minute = 9, 0
+	}
+
+	next := time.Date(t.Year(), t.Month(), t.Day(), hour, minute, 0, 0, t.Location())
+	for !next.After(t) || (s.Mode() == DeliveryWeekly && next.Weekday() != s.DigestWeekday) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// DigestDue true if the scheduled digest time passed since the last digest
+func (s ChatSettings) DigestDue(now time.Time) bool {
+	if s.Mode() == DeliveryInstant {
+		return false
+	}
+	last, err := time.Parse(time.RFC3339, s.LastDigest)
+	if err != nil {
+		return true
+	}
+	return !now.Before(s.NextDigestAfter(last))
+}
+
+// MarketReportDue true if Monday morning in the chat timezone passed since the last market report
+func (s ChatSettings) MarketReportDue(now time.Time) bool {
+	if !s.MarketReport {
+		return false
+	}
+	last, err := time.Parse(time.RFC3339, s.LastMarketReport)
+	if err != nil {
+		return true
+	}
+	weekly := ChatSettings{Timezone: s.Timezone, DeliveryMode: DeliveryWeekly, DigestTime: MarketReportTime, DigestWeekday: time.Monday}
+	return !now.Before(weekly.NextDigestAfter(last))
+}
+
+// FlatDisplay sorting and columns of the flat lists, defaults if not set
+func (s ChatSettings) FlatDisplay() flatstorage.Display {
+	if s.Display == nil {
+		return flatstorage.Display{}
+	}
+	return *s.Display
+}
+
+// Lang the language of all messages to the chat
+func (s ChatSettings) Lang() i18n.Lang {
+	if len(s.Language) > 0 {
+		return s.Language
+	}
+	return i18n.FromCode(s.LanguageCode)
+}
+
+// ChatLang the language of all messages to the chat
+func ChatLang(chatID int64) i18n.Lang {
+	return GetChatSettings(chatID).Lang()
+}
+
+// LanguageCodeChanges true if the language code of the user changes the language of the chat
+func (s ChatSettings) LanguageCodeChanges(code string) bool {
+	return len(code) > 0 && len(s.Language) == 0 && i18n.FromCode(code) != i18n.FromCode(s.LanguageCode)
+}
+
+// RememberLanguageCode keep the language code of the last user who wrote to the chat,
+// persisted only if the language of the chat changes
+func RememberLanguageCode(chatID int64, code string) {
+	settings := GetChatSettings(chatID)
+	if !settings.LanguageCodeChanges(code) {
+		return
+	}
+	settings.LanguageCode = code
+	err := SetChatSettings(settings)
+	if err != nil {
+		log.Printf("failed to save language code of %v: %v", chatID, err)
+	}
+}
+
+// DeliveryString example: "daily at 09:00 (Europe/Moscow)"
+func (s ChatSettings) DeliveryString(lang i18n.Lang) string {
+	loc := s.Location().String()
+	switch s.Mode() {
+	case DeliveryHourly:
+		return i18n.T(lang, "delivery.hourly")
+	case DeliveryDaily:
+		return i18n.T(lang, "delivery.daily", s.DigestTime, loc)
+	case DeliveryWeekly:
+		return i18n.T(lang, "delivery.weekly", i18n.T(lang, fmt.Sprintf("weekday.%d", s.DigestWeekday)), s.DigestTime, loc)
+	}
+	return i18n.T(lang, "delivery.instant")
+}
+
+// InQuietHours true if now is within the quiet window of the chat, e.g. 23:00-08:00
+func (s ChatSettings) InQuietHours(now time.Time) bool {
+	fromHour, fromMinute, err := ParseClock(s.QuietFrom)
+	if err != nil {
+		return false
+	}
+	toHour, toMinute, err := ParseClock(s.QuietTo)
+	if err != nil {
+		return false
+	}
+
+	now = now.In(s.Location())
+	minutes := now.Hour()*60 + now.Minute()
+	from := fromHour*60 + fromMinute
+	to := toHour*60 + toMinute
+
+	if from <= to {
+		return from <= minutes && minutes < to
+	}
+	// the window goes over midnight
+	return minutes >= from || minutes < to
+}
+
+func (s ChatSettings) QuietModeOrDefault() QuietMode {
+	if len(s.QuietMode) == 0 {
+		return QuietHold
+	}
+	return s.QuietMode
+}
+
+// QuietString example: "23:00-08:00 (hold, Europe/Moscow)"
+func (s ChatSettings) QuietString(lang i18n.Lang) string {
+	if len(s.QuietFrom) == 0 || len(s.QuietTo) == 0 {
+		return i18n.T(lang, "quiet.off")
+	}
+	return fmt.Sprintf("%v-%v (%v, %v)", s.QuietFrom, s.QuietTo, i18n.T(lang, "quiet."+string(s.QuietModeOrDefault())), s.Location())
+}
+
+// ParseClock example: "9:05" => 9, 5
+func ParseClock(clock string) (hour int, minute int, err error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, 0, i18n.NewError("clock.invalid", clock)
+	}
+	return t.Hour(), t.Minute(), nil
+}
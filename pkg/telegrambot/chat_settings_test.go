@@ -0,0 +1,228 @@
+package telegrambot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/mortgage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextDigestAfter(t *testing.T) {
+	moscow, err := time.LoadLocation(DefaultTimezone)
+	require.NoError(t, err)
+
+	// Wednesday
+	now := time.Date(2024, 5, 15, 10, 30, 0, 0, moscow)
+
+	tests := []struct {
+		settings ChatSettings
+		expected time.Time
+	}{
+		{
+			ChatSettings{DeliveryMode: DeliveryHourly},
+			time.Date(2024, 5, 15, 11, 0, 0, 0, moscow),
+		},
+		{
+			ChatSettings{DeliveryMode: DeliveryDaily, DigestTime: "21:00"},
+			time.Date(2024, 5, 15, 21, 0, 0, 0, moscow),
+		},
+		{
+			ChatSettings{DeliveryMode: DeliveryDaily, DigestTime: "09:00"},
+			time.Date(2024, 5, 16, 9, 0, 0, 0, moscow),
+		},
+		{
+			ChatSettings{DeliveryMode: DeliveryWeekly, DigestTime: "09:00", DigestWeekday: time.Monday},
+			time.Date(2024, 5, 20, 9, 0, 0, 0, moscow),
+		},
+		{
+			ChatSettings{DeliveryMode: DeliveryDaily, DigestTime: "09:00", Timezone: "Asia/Yekaterinburg"},
+			time.Date(2024, 5, 16, 7, 0, 0, 0, moscow),
+		},
+	}
+
+	for i, test := range tests {
+		res := test.settings.NextDigestAfter(now)
+		require.True(t, test.expected.Equal(res), fmt.Sprintf("failed case %v: %v", i, res))
+	}
+}
+
+func TestDigestDue(t *testing.T) {
+	settings := ChatSettings{DeliveryMode: DeliveryDaily, DigestTime: "09:00", LastDigest: "2024-05-15T08:00:00+03:00"}
+
+	require.False(t, settings.DigestDue(time.Date(2024, 5, 15, 5, 59, 0, 0, time.UTC)))
+	require.True(t, settings.DigestDue(time.Date(2024, 5, 15, 6, 0, 0, 0, time.UTC)))
+	require.False(t, ChatSettings{}.DigestDue(time.Now()))
+}
+
+func TestParseDelivery(t *testing.T) {
+	tests := []struct {
+		args     string
+		expected ChatSettings
+		isErr    bool
+	}{
+		{"instant", ChatSettings{DeliveryMode: DeliveryInstant}, false},
+		{"daily", ChatSettings{DeliveryMode: DeliveryDaily, DigestTime: "09:00"}, false},
+		{"daily 7:30", ChatSettings{DeliveryMode: DeliveryDaily, DigestTime: "07:30"}, false},
+		{"weekly fri 18:00", ChatSettings{DeliveryMode: DeliveryWeekly, DigestTime: "18:00", DigestWeekday: time.Friday}, false},
+		{"weekly", ChatSettings{DeliveryMode: DeliveryWeekly, DigestTime: "09:00", DigestWeekday: time.Monday}, false},
+		{"daily 25:00", ChatSettings{}, true},
+		{"monthly", ChatSettings{}, true},
+	}
+
+	for i, test := range tests {
+		res, err := parseDelivery(ChatSettings{}, test.args)
+		if test.isErr {
+			require.Error(t, err, fmt.Sprintf("failed case %v", i))
+			continue
+		}
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.expected, res, fmt.Sprintf("failed case %v", i))
+	}
+}
+
+func TestInQuietHours(t *testing.T) {
+	moscow, err := time.LoadLocation(DefaultTimezone)
+	require.NoError(t, err)
+
+	overMidnight := ChatSettings{QuietFrom: "23:00", QuietTo: "08:00"}
+	daytime := ChatSettings{QuietFrom: "13:00", QuietTo: "15:00"}
+
+	tests := []struct {
+		settings ChatSettings
+		now      time.Time
+		expected bool
+	}{
+		{overMidnight, time.Date(2024, 5, 15, 3, 0, 0, 0, moscow), true},
+		{overMidnight, time.Date(2024, 5, 15, 23, 0, 0, 0, moscow), true},
+		{overMidnight, time.Date(2024, 5, 15, 8, 0, 0, 0, moscow), false},
+		{overMidnight, time.Date(2024, 5, 15, 12, 0, 0, 0, moscow), false},
+		// 23:30 UTC is 02:30 in Moscow
+		{overMidnight, time.Date(2024, 5, 15, 23, 30, 0, 0, time.UTC), true},
+		{daytime, time.Date(2024, 5, 15, 14, 0, 0, 0, moscow), true},
+		{daytime, time.Date(2024, 5, 15, 16, 0, 0, 0, moscow), false},
+		{ChatSettings{}, time.Date(2024, 5, 15, 3, 0, 0, 0, moscow), false},
+	}
+
+	for i, test := range tests {
+		require.Equal(t, test.expected, test.settings.InQuietHours(test.now), fmt.Sprintf("failed case %v", i))
+	}
+}
+
+func TestParseQuiet(t *testing.T) {
+	res, err := parseQuiet(ChatSettings{}, "23:00-8:00 silent Asia/Novosibirsk")
+	require.NoError(t, err)
+	require.Equal(t, ChatSettings{QuietFrom: "23:00", QuietTo: "08:00", QuietMode: QuietSilent, Timezone: "Asia/Novosibirsk"}, res)
+
+	res, err = parseQuiet(res, "off")
+	require.NoError(t, err)
+	require.Equal(t, ChatSettings{Timezone: "Asia/Novosibirsk"}, res)
+
+	// processUpdate un-embeds the underscores of the args into dashes
+	res, err = parseQuiet(ChatSettings{}, "23:00-08:00 America/New-York")
+	require.NoError(t, err)
+	require.Equal(t, "America/New_York", res.Timezone)
+	res, err = parseQuiet(ChatSettings{}, "23:00-08:00 America/New_York")
+	require.NoError(t, err)
+	require.Equal(t, "America/New_York", res.Timezone)
+
+	_, err = parseQuiet(ChatSettings{}, "23:00")
+	require.Error(t, err)
+	_, err = parseQuiet(ChatSettings{}, "23:00-08:00 loud")
+	require.Error(t, err)
+}
+
+func TestMarketReportDue(t *testing.T) {
+	// Wednesday
+	settings := ChatSettings{MarketReport: true, LastMarketReport: "2024-05-15T10:00:00+03:00"}
+
+	require.False(t, settings.MarketReportDue(time.Date(2024, 5, 20, 5, 59, 0, 0, time.UTC)))
+	require.True(t, settings.MarketReportDue(time.Date(2024, 5, 20, 6, 0, 0, 0, time.UTC)))
+	require.True(t, ChatSettings{MarketReport: true}.MarketReportDue(time.Now()))
+	require.False(t, ChatSettings{}.MarketReportDue(time.Now()))
+}
+
+func TestDigestString(t *testing.T) {
+	queue := &ChatQueue{Flats: []flatstorage.Flat{
+		{ID: 1, BlockSlug: "2ngt", BlockName: "Второй Нагатинский", BulkName: "Корпус 1.1", Price: 10_000_000},
+		{ID: 2, BlockSlug: "utnv", BlockName: "Уточкина 7", BulkName: "Корпус 2", Price: 12_000_000},
+	}}
+	blockDistance := func(blockSlug string) (float64, bool) {
+		return 2.34, blockSlug == "2ngt"
+	}
+
+	res := queue.DigestString(i18n.En, flatstorage.Display{}, blockDistance)
+	require.Contains(t, res, "📍 2.3 km from your point\n")
+	require.Equal(t, 1, strings.Count(res, "📍"))
+	require.Equal(t, 0, strings.Count(queue.DigestString(i18n.En, flatstorage.Display{}, nil), "📍"))
+}
+
+func TestLanguageCodeChanges(t *testing.T) {
+	tests := []struct {
+		settings ChatSettings
+		code     string
+		expected bool
+	}{
+		{ChatSettings{}, "", false},
+		{ChatSettings{}, "en-US", false},
+		{ChatSettings{}, "ru", true},
+		{ChatSettings{LanguageCode: "ru"}, "uk", false},
+		{ChatSettings{LanguageCode: "ru"}, "en", true},
+		{ChatSettings{Language: i18n.Ru}, "en", false},
+	}
+
+	for i, test := range tests {
+		require.Equal(t, test.expected, test.settings.LanguageCodeChanges(test.code), fmt.Sprintf("failed case %v", i))
+	}
+	require.Equal(t, i18n.En, ChatSettings{}.Lang())
+}
+
+func TestFilterForChat(t *testing.T) {
+	wave := &flatstorage.RepricingSummary{Compared: 10, Repriced: 5}
+	update := &flatstorage.BlockUpdate{
+		NewFlats:  &flatstorage.MessageData{Flats: []flatstorage.Flat{{ID: 1, Discount: 0.1}, {ID: 2}, {ID: 3, Discount: 0.1}}},
+		Repricing: wave,
+	}
+	hidden := ChatHidden{FlatIDs: []int64{3}}
+
+	res := FilterForChat(ChatSettings{}, hidden, update)
+	require.Len(t, res.NewFlats.Flats, 2)
+	require.Equal(t, wave, res.Repricing)
+
+	// the deals only mode keeps the repricing wave
+	res = FilterForChat(ChatSettings{DealsOnly: true}, hidden, update)
+	require.Len(t, res.NewFlats.Flats, 1)
+	require.Equal(t, int64(1), res.NewFlats.Flats[0].ID)
+	require.Equal(t, wave, res.Repricing)
+
+	res = FilterForChat(ChatSettings{DealsOnly: true}, ChatHidden{}, &flatstorage.BlockUpdate{Repricing: wave})
+	require.False(t, res.Empty())
+	require.Nil(t, FilterForChat(ChatSettings{DealsOnly: true}, ChatHidden{}, nil))
+}
+
+func TestParseErrorsTranslated(t *testing.T) {
+	tests := []struct {
+		parse    func() error
+		expected string
+	}{
+		{func() error { _, err := flatstorage.ParseFlatFilter("color=red"); return err }, "Неизвестное поле фильтра: color"},
+		{func() error { _, err := flatstorage.ParseFlatFilter("rooms=two"); return err }, "Неверное число: two"},
+		{func() error { _, _, err := ParseDumpFilter("group=floor"); return err }, "Неизвестная группировка: floor"},
+		{func() error { _, err := parseDelivery(ChatSettings{}, "monthly"); return err }, "Неизвестный режим доставки: monthly"},
+		{func() error { _, err := parseQuiet(ChatSettings{}, "23-08"); return err }, `Неверное время "23", ожидается ЧЧ:ММ`},
+		{func() error { _, _, err := ParseMortgage(mortgage.Params{}, "rate=abc"); return err }, "Неверная ставка: abc"},
+		{func() error { _, err := ParseSearchQuery("sort=price sort=area"); return err }, "Поддерживается только один ключ сортировки"},
+		{func() error { _, err := ParseBudgetQuery("lots"); return err }, "Неверный бюджет: lots"},
+		{func() error { _, err := parseNearArgs(1, "55.7 37.6 500"); return err }, "Радиус должен быть в пределах (0, 100] км"},
+	}
+
+	for i, test := range tests {
+		err := test.parse()
+		require.Error(t, err, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.expected, i18n.ErrorText(i18n.Ru, err), fmt.Sprintf("failed case %v", i))
+	}
+}
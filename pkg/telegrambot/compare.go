@@ -0,0 +1,164 @@
+package telegrambot
+
+import (
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	CompareCommand = "compare"
+
+	CompareLimit      = 4
+	compareMetroLimit = 12
+	compareMissing    = "—"
+)
+
+// settlementMonth example: "2025-06-15" => "06.25"
+func settlementMonth(date string) string {
+	t, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return date
+	}
+	return t.Format("01.06")
+}
+
+// settlementRange example: "06.25-03.26"
+func settlementRange(stats flatstorage.BlockStats) string {
+	if len(stats.SettlementFrom) == 0 {
+		return compareMissing
+	}
+	from, to := settlementMonth(stats.SettlementFrom), settlementMonth(stats.SettlementTo)
+	if from == to {
+		return from
+	}
+	return from + "-" + to
+}
+
+// CompareTable one column per block, example:
+//
+//	           2ngt          utnv
+//	metro      Нагатинская   Кунцевская
+//	flats      120           45
+//	1r         30: 9.0/10.2  10: 10.1/11.0
+//	m2, k      290/330       310/350
+//	settlement 06.25-03.26   12.25
+func CompareTable(lang i18n.Lang, slugs []string, metros []string, stats []flatstorage.BlockStats) string {
+	rooms := make(map[int8]struct{})
+	for _, blockStats := range stats {
+		for _, roomStats := range blockStats.Rooms {
+			rooms[roomStats.Rooms] = struct{}{}
+		}
+	}
+
+	rows := [][]string{append([]string{""}, slugs...)}
+	addRow := func(label string, cell func(i int) string) {
+		row := []string{label}
+		for i := range slugs {
+			row = append(row, cell(i))
+		}
+		rows = append(rows, row)
+	}
+
+	addRow(i18n.T(lang, "compare.metro"), func(i int) string {
+		metro := []rune(metros[i])
+		if len(metro) == 0 {
+			return compareMissing
+		}
+		if len(metro) > compareMetroLimit {
+			metro = append(metro[:compareMetroLimit-1], '…')
+		}
+		return string(metro)
+	})
+	addRow(i18n.T(lang, "compare.total"), func(i int) string {
+		return fmt.Sprintf("%v", stats[i].Total)
+	})
+	for _, roomCount := range util.SortedKeys(rooms) {
+		addRow(i18n.T(lang, "stats.rooms", roomCount), func(i int) string {
+			for _, roomStats := range stats[i].Rooms {
+				if roomStats.Rooms == roomCount {
+					return fmt.Sprintf("%v: %.1f/%.1f", roomStats.Count,
+						float64(roomStats.MinPrice)/1_000_000, float64(roomStats.MedianPrice)/1_000_000)
+				}
+			}
+			return compareMissing
+		})
+	}
+	addRow(i18n.T(lang, "compare.meterprice"), func(i int) string {
+		if stats[i].MedianMeterPrice == 0 {
+			return compareMissing
+		}
+		return fmt.Sprintf("%.0f/%.0f", float64(stats[i].MinMeterPrice)/1_000, float64(stats[i].MedianMeterPrice)/1_000)
+	})
+	addRow(i18n.T(lang, "compare.settlement"), func(i int) string {
+		return settlementRange(stats[i])
+	})
+
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for j, cell := range row {
+			widths[j] = util.Max(widths[j], len([]rune(cell)))
+		}
+	}
+	lines := make([]string, 0, len(rows))
+	for _, row := range rows {
+		cells := make([]string, 0, len(row))
+		for j, cell := range row {
+			cells = append(cells, fmt.Sprintf("%-*v", widths[j], cell))
+		}
+		lines = append(lines, strings.TrimRight(strings.Join(cells, "  "), " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sendCompare example args: "2ngt utnv"
+func sendCompare(chatID int64, args string) {
+	lang := ChatLang(chatID)
+
+	var slugs, unknown []string
+	for _, slug := range strings.Fields(args) {
+		slug = normalizeSlug(slug)
+		if _, ok := BlockSlugs[slug]; !ok {
+			unknown = append(unknown, slug)
+			continue
+		}
+		slugs = append(slugs, slug)
+	}
+
+	if len(unknown) > 0 || len(slugs) < 2 || len(slugs) > CompareLimit {
+		msg := i18n.T(lang, "compare.usage", CompareCommand, CompareLimit, CompareCommand, ListCommand)
+		if len(unknown) > 0 {
+			msg = i18n.T(lang, "compare.unknown", strings.Join(unknown, ", ")) + "\n\n" + msg
+		}
+		err := SendMessage(chatID, msg)
+		if err != nil {
+			log.Printf("failed to send /%v help message to %v: %v", CompareCommand, chatID, err)
+		}
+		return
+	}
+
+	now := time.Now()
+	var metros, legend []string
+	var stats []flatstorage.BlockStats
+	for _, slug := range slugs {
+		RefreshStaleFlats(slug)
+		msgData, err := ReadStoredFlats(slug)
+		if err != nil {
+			log.Printf("failed to read flats of %v: %v", slug, err)
+		}
+		stats = append(stats, msgData.Stats(now))
+		metros = append(metros, GetBlockMetro(BlockSlugs[slug]))
+		legend = append(legend, fmt.Sprintf("<b>%v</b> — %v /%v_%v", slug, BlockSlugs[slug].Name, DumpCommand, embedSlug(slug)))
+	}
+
+	msg := i18n.T(lang, "compare.header") + "\n<pre>" + CompareTable(lang, slugs, metros, stats) + "</pre>\n" +
+		strings.Join(legend, "\n")
+	err := SendMessage(chatID, msg)
+	if err != nil {
+		log.Printf("failed to send comparison to %v: %v", chatID, err)
+	}
+}
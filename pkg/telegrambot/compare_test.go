@@ -0,0 +1,30 @@
+package telegrambot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareTable(t *testing.T) {
+	stats := []flatstorage.BlockStats{
+		{Total: 120, MinMeterPrice: 290_000, MedianMeterPrice: 330_000, SettlementFrom: "2025-06-15", SettlementTo: "2026-03-31",
+			Rooms: []flatstorage.RoomStats{{Rooms: 1, Count: 30, MinPrice: 9_000_000, MedianPrice: 10_240_000}}},
+		{Total: 45, MinMeterPrice: 310_000, MedianMeterPrice: 350_000, SettlementFrom: "2025-12-01", SettlementTo: "2025-12-20",
+			Rooms: []flatstorage.RoomStats{{Rooms: 2, Count: 10, MinPrice: 15_000_000, MedianPrice: 16_000_000}}},
+	}
+
+	res := CompareTable(i18n.En, []string{"2ngt", "utnv"}, []string{"Нагатинская", "Верхние Лихоборы"}, stats)
+	require.Equal(t, strings.Join([]string{
+		"       2ngt          utnv",
+		"metro  Нагатинская   Верхние Лих…",
+		"flats  120           45",
+		"1r     30: 9.0/10.2  —",
+		"2r     —             10: 15.0/16.0",
+		"m2, k  290/330       310/350",
+		"keys   06.25-03.26   12.25",
+	}, "\n"), res)
+}
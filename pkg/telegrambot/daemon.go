@@ -1,18 +1,22 @@
 package telegrambot
 
 import (
+	"errors"
 	"fmt"
 	"github.com/georgri/sledopyt_addresses/pkg/backup_data"
 	"github.com/georgri/sledopyt_addresses/pkg/downloader"
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
 	"github.com/georgri/sledopyt_addresses/pkg/util"
 	"log"
 	"os"
-	"strings"
 	"time"
 )
 
 const (
-	invokeEvery = 5 * time.Minute
+	invokeEvery     = 5 * time.Minute
+	areaPollEvery   = 30 * time.Minute
+	areaSeedsPerRun = 5 // a new region subscription does not download all of its blocks at once
 
 	logfile = "logs/bot.log"
 )
@@ -38,6 +42,8 @@ func RunForever() {
 
 	go GetUpdatesForever()
 
+	go SendScheduledForever()
+
 	for {
 		RunOnce()
 		time.Sleep(invokeEvery)
@@ -47,57 +53,177 @@ func RunForever() {
 func RunOnce() {
 	envType := util.GetEnvType()
 
-	// 1. Get map of block slug => subscribed channels
+	// 1. Get map of block slug => subscriptions
 	// 2. Update block slug
-	// 3. Send info to all subscribed channels
+	// 3. Send info to all subscribed channels within their bulk scope
 
-	slugs := make(map[string][]int64, 10)
+	slugs := make(map[string][]ChannelInfo, 10)
 
 	for _, channelInfo := range ChannelIDs[envType] {
-		slugs[channelInfo.BlockSlug] = append(slugs[channelInfo.BlockSlug], channelInfo.ChatID)
+		slugs[channelInfo.BlockSlug] = append(slugs[channelInfo.BlockSlug], channelInfo)
+	}
+	// metro and region subscriptions cover the blocks not subscribed explicitly
+	areaSlugs := make(map[string][]ChannelInfo)
+	for _, channelInfo := range GetAreaChannels(envType) {
+		areaSlugs[channelInfo.BlockSlug] = append(areaSlugs[channelInfo.BlockSlug], channelInfo)
+	}
+	for _, slug := range addAreaPolls(slugs, areaSlugs, time.Now(), BlockStored) {
+		SeedBlockStorage(slug)
+	}
+	// watched flats are checked regardless of block subscriptions
+	for _, slug := range GetWatchedBlocks() {
+		if _, ok := slugs[slug]; !ok {
+			slugs[slug] = nil
+		}
+	}
+	for slug, subscriptions := range slugs {
+		ProcessWithSlugAndSubscriptions(slug, subscriptions)
+	}
+}
+
+// lastAreaPolls when the blocks covered by area subscriptions only were downloaded, RunOnce goroutine only
+var lastAreaPolls = make(map[string]time.Time)
+
+// addAreaPolls adds the area blocks to the polled slugs, the blocks covered by area subscriptions only
+// are downloaded once in areaPollEvery; returns the unstored blocks to seed, at most areaSeedsPerRun,
+// the rest are seeded by the next runs
+func addAreaPolls(slugs, areaSlugs map[string][]ChannelInfo, now time.Time, stored func(slug string) bool) []string {
+	var seeds []string
+	for _, slug := range util.SortedKeys(areaSlugs) {
+		_, polled := slugs[slug]
+		if !polled && now.Sub(lastAreaPolls[slug]) < areaPollEvery {
+			continue
+		}
+		if !stored(slug) {
+			// the first download only fills the storage, otherwise every flat of the block is new;
+			// the explicitly subscribed blocks are stored by their own download
+			if !polled && len(seeds) < areaSeedsPerRun {
+				lastAreaPolls[slug] = now
+				seeds = append(seeds, slug)
+			}
+			continue
+		}
+		if !polled {
+			lastAreaPolls[slug] = now
+		}
+		slugs[slug] = append(slugs[slug], areaSlugs[slug]...)
 	}
-	for slug, chatIDs := range slugs {
-		ProcessWithSlugAndChatIDs(slug, chatIDs)
+	return seeds
+}
+
+// BlockStored the flats of the block were downloaded at least once
+func BlockStored(slug string) bool {
+	fileName, err := GetStorageFileNameByBlockSlug(slug)
+	if err != nil {
+		fileName = flatstorage.GetStorageFileNameByBlockSlugAndEnv(slug)
 	}
+	return flatstorage.FileExists(fileName)
 }
 
-func ProcessWithSlugAndChatIDs(blockSlug string, chatIDs []int64) {
-	flats, err := DownloadAndUpdateFile(blockSlug, chatIDs[0])
+// SeedBlockStorage download the flats of the block into the storage without notifying anyone
+func SeedBlockStorage(slug string) {
+	_, err := DownloadAndUpdateFile(slug, 0)
+	if err != nil {
+		log.Printf("failed to seed flats of %v: %v", slug, err)
+		return
+	}
+	log.Printf("seeded flats of %v", slug)
+}
+
+// ProcessWithSlugAndSubscriptions subscriptions can be empty for the blocks with watched flats only
+func ProcessWithSlugAndSubscriptions(blockSlug string, subscriptions []ChannelInfo) {
+	var chatID int64
+	if len(subscriptions) > 0 {
+		chatID = subscriptions[0].ChatID
+	}
+	update, err := DownloadAndUpdateFile(blockSlug, chatID)
+	if errors.Is(err, downloader.ErrNoFlats) {
+		// the block is sold out: none of the stored flats is on sale anymore
+		NotifyWatchers(blockSlug, true)
+		return
+	}
 	if err != nil {
 		log.Printf("error while updating flats: %v", err)
 		return
 	}
 
-	for _, chatID := range chatIDs {
-		err = SendMessage(chatID, flats)
+	NotifyWatchers(blockSlug, false)
+
+	if update.NewFlats != nil && len(update.NewFlats.Flats) > 0 {
+		block, err := ReadRecentFlats(blockSlug)
+		if err != nil {
+			log.Printf("failed to read flats of %v to score deals: %v", blockSlug, err)
+		}
+		update.NewFlats.ScoreDeals(block)
+	}
+
+	for _, subscription := range subscriptions {
+		chatUpdate := update.ForBulks(subscription.Bulks)
+		if chatUpdate.Empty() {
+			continue
+		}
+		err = DeliverToChat(subscription.ChatID, blockSlug, chatUpdate)
 		if err != nil {
-			log.Printf("error while sending message in %v (chatID %v): %v", blockSlug, chatID, err)
+			log.Printf("error while sending message in %v (chatID %v): %v", blockSlug, subscription.ChatID, err)
 			return
 		}
 	}
 }
 
-func DownloadAndUpdateFile(blockSlug string, chatID int64) (string, error) {
+// FilterForChat the flats hidden by the chat are dropped,
+// only the deal flats and the repricing summary are kept in the deals only mode
+func FilterForChat(settings ChatSettings, hidden ChatHidden, update *flatstorage.BlockUpdate) *flatstorage.BlockUpdate {
+	update = update.Reject(hidden.Hides)
+	if settings.DealsOnly && update != nil {
+		// a price wave matters to deal hunters too
+		update = &flatstorage.BlockUpdate{NewFlats: update.NewFlats.Deals(), Repricing: update.Repricing}
+	}
+	return update
+}
+
+// DeliverToChat send the update right away or keep it for the digest depending on the chat delivery mode
+func DeliverToChat(chatID int64, blockSlug string, update *flatstorage.BlockUpdate) error {
+	settings := GetChatSettings(chatID)
+	update = FilterForChat(settings, GetChatHidden(chatID), update)
+	if update.Empty() {
+		return nil
+	}
+
+	if settings.Mode() != DeliveryInstant {
+		return EnqueueForChat(chatID, update)
+	}
+
+	lang := settings.Lang()
+	msg := update.FormatDisplay(lang, settings.FlatDisplay())
+	if distance, ok := GetChatBlockDistance(chatID, BlockSlugs[blockSlug]); ok {
+		msg = i18n.T(lang, "area.from.point", distance) + "\n" + msg
+	}
+	return NotifyChat(chatID, msg, BlockKeyboard(chatID, blockSlug))
+}
+
+func DownloadAndUpdateFile(blockSlug string, chatID int64) (*flatstorage.BlockUpdate, error) {
 	blockID := GetBlockIDBySlug(blockSlug)
 
 	envtype := util.GetEnvType().String()
 
 	// TODO: get rid of chatIDs[0] after safe migration
-	flats, filtered, updateCallback, err := downloader.GetFlats(chatID, blockID)
+	update, filtered, updateCallback, err := downloader.GetFlats(chatID, blockID)
 	if err != nil {
-		return "", fmt.Errorf("error getting response from pik.ru: %v", err)
+		return nil, fmt.Errorf("error getting response from pik.ru: %w", err)
 	}
 
 	err = updateCallback()
 	if err != nil {
-		return "", fmt.Errorf("update callback failed in %v (envtype %v): %v", blockSlug, envtype, err)
+		return nil, fmt.Errorf("update callback failed in %v (envtype %v): %v", blockSlug, envtype, err)
 	}
+	IndexFlats(blockSlug, update.NewFlats)
 
-	if len(strings.TrimSpace(flats)) == 0 {
-		return "", fmt.Errorf("no new flats in %v (envtype %v), aborting; filtered %v", blockSlug, envtype, filtered)
+	if update.Empty() {
+		log.Printf("no new flats in %v (envtype %v); filtered %v", blockSlug, envtype, filtered)
+		return update, nil
 	}
 
-	log.Printf("Got flats in %v (envtype %v): %v", blockSlug, envtype, flats)
+	log.Printf("Got flats in %v (envtype %v): %v", blockSlug, envtype, update)
 
-	return flats, nil
+	return update, nil
 }
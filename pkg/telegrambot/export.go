@@ -0,0 +1,95 @@
+package telegrambot
+
+import (
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	ExportCommand = "export"
+
+	ExportCSV  = "csv"
+	ExportXLSX = "xlsx"
+)
+
+// parseExportArgs example: "2ngt xlsx rooms=2" => "2ngt", ["xlsx"], "rooms=2";
+// both formats if none is given
+func parseExportArgs(args string) (string, []string, string) {
+	slug, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+	rest = strings.TrimSpace(rest)
+
+	format, filterStr, _ := strings.Cut(rest, " ")
+	switch strings.ToLower(format) {
+	case ExportCSV, ExportXLSX:
+		return slug, []string{strings.ToLower(format)}, strings.TrimSpace(filterStr)
+	}
+	return slug, []string{ExportCSV, ExportXLSX}, rest
+}
+
+// ExportFileName example: "2ngt_2024-05-15.csv"
+func ExportFileName(slug string, format string, now time.Time) string {
+	return fmt.Sprintf("%v_%v.%v", slug, now.Format(time.DateOnly), format)
+}
+
+// sendExport example args: "2ngt xlsx rooms=2 price<15m"
+func sendExport(chatID int64, args string) {
+	slug, formats, filterStr := parseExportArgs(args)
+	lang := ChatLang(chatID)
+
+	slug, err := validateSlug(chatID, slug, ExportCommand)
+	if err != nil {
+		log.Printf("failed to export to %v: %v", chatID, err)
+		return
+	}
+
+	filter, err := flatstorage.ParseFlatFilter(filterStr)
+	if err != nil {
+		err = SendMessage(chatID, fmt.Sprintf("%v\n\n%v", i18n.ErrorText(lang, err), i18n.T(lang, "export.usage", ExportCommand, ExportCommand, slug)))
+		if err != nil {
+			log.Printf("failed to send /%v help message to %v: %v", ExportCommand, chatID, err)
+		}
+		return
+	}
+
+	msgData, err := ReadFreshFlats(slug)
+	if err != nil {
+		log.Printf("failed to read flats of %v: %v", slug, err)
+		return
+	}
+	msgData = msgData.Filter(filter)
+
+	if len(msgData.Flats) == 0 {
+		msg := i18n.T(lang, "dump.empty", slug)
+		if !filter.Empty() {
+			msg = i18n.T(lang, "dump.empty.filter", slug, filter)
+		}
+		err = SendMessage(chatID, msg)
+		if err != nil {
+			log.Printf("failed to send empty export to %v: %v", chatID, err)
+		}
+		return
+	}
+
+	caption := i18n.N(lang, "export.caption", len(msgData.Flats), slug)
+	for _, format := range formats {
+		var content []byte
+		if format == ExportXLSX {
+			content, err = msgData.XLSX()
+		} else {
+			content, err = msgData.CSV()
+		}
+		if err != nil {
+			log.Printf("failed to export %v of %v: %v", format, slug, err)
+			continue
+		}
+
+		err = SendDocument(chatID, ExportFileName(slug, format, time.Now()), content, caption)
+		if err != nil {
+			log.Printf("failed to send %v export of %v to %v: %v", format, slug, chatID, err)
+		}
+	}
+}
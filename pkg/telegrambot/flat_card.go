@@ -0,0 +1,195 @@
+package telegrambot
+
+import (
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/downloader"
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/mortgage"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const FlatCommand = "flat"
+
+// flatBlocks flat ID => block slug of all stored flats, built on the first lookup and kept up to date by IndexFlats
+var (
+	flatBlocks      map[int64]string
+	flatBlocksMutex sync.Mutex
+)
+
+// IndexFlats remember the block of the flats
+func IndexFlats(blockSlug string, msgData *flatstorage.MessageData) {
+	if msgData == nil {
+		return
+	}
+	flatBlocksMutex.Lock()
+	defer flatBlocksMutex.Unlock()
+
+	if flatBlocks == nil {
+		// the whole index is built on the first lookup
+		return
+	}
+	for _, flat := range msgData.Flats {
+		flatBlocks[flat.ID] = blockSlug
+	}
+}
+
+func flatBlock(id int64) (string, bool) {
+	flatBlocksMutex.Lock()
+	defer flatBlocksMutex.Unlock()
+
+	if flatBlocks == nil {
+		flatBlocks = make(map[int64]string)
+		for _, slug := range util.SortedKeys(BlockSlugs) {
+			msgData, err := ReadStoredFlats(slug)
+			if err != nil {
+				continue
+			}
+			for _, flat := range msgData.Flats {
+				flatBlocks[flat.ID] = slug
+			}
+		}
+	}
+	slug, ok := flatBlocks[id]
+	return slug, ok
+}
+
+// FindFlat the flat with the ID in the local file of its block
+func FindFlat(id int64) (*flatstorage.Flat, bool) {
+	flat, _, ok := findStoredFlat(id)
+	return flat, ok
+}
+
+// findStoredFlat the flat with all the stored flats of its block
+func findStoredFlat(id int64) (*flatstorage.Flat, *flatstorage.MessageData, bool) {
+	slug, ok := flatBlock(id)
+	if !ok {
+		return nil, nil, false
+	}
+	msgData, err := ReadStoredFlats(slug)
+	if err != nil {
+		log.Printf("failed to read flats of %v to find flat %v: %v", slug, id, err)
+		return nil, nil, false
+	}
+	flat, ok := msgData.FindFlat(id)
+	return flat, msgData, ok
+}
+
+// FlatCard onSale if the last poll of the block found the flat, example:
+// 🏠 Flat 819556
+// Второй Нагатинский, bulk 1.1
+// 📍 Москва, Нагатинская наб., 10к1
+// 2r, 54.3m2, floor 5/33
+// 💰 18 150 000R (334 300R/m2)
+// 🏦 ≈190 000R/mo
+// Ceiling: 2.85 m
+// Finish: with finishing
+func FlatCard(lang i18n.Lang, id int64, flat *flatstorage.Flat, onSale bool, details *flatstorage.FlatDetails, params *mortgage.Params) string {
+	res := []string{i18n.T(lang, "card.title", fmt.Sprintf("https://www.pik.ru/flat/%v", id), id)}
+
+	if flat != nil {
+		res = append(res, i18n.T(lang, "card.block", flat.BlockName, flat.BulkShortName()))
+	}
+	if details != nil && len(details.Address) > 0 {
+		res = append(res, "📍 "+html.EscapeString(details.Address))
+	}
+
+	if flat != nil {
+		res = append(res, i18n.T(lang, "card.params", flat.Rooms, fmt.Sprintf("%.1f", flat.Area), flat.Floor, flat.MaxFloor))
+		res = append(res, i18n.T(lang, "card.price", util.ThousandSep(flat.Price, " "), util.ThousandSep(flat.MeterPrice, " ")))
+		if params != nil {
+			if payment := MortgagePayment(lang, *params, flat.Price); len(payment) > 0 {
+				res = append(res, payment)
+			}
+		}
+		switch {
+		case !onSale:
+			res = append(res, i18n.T(lang, "card.sold"))
+		case flat.Status == "reserve":
+			res = append(res, i18n.T(lang, "card.reserve"))
+		}
+		if flat.RelistedFrom != 0 {
+			res = append(res, i18n.T(lang, "card.relisted", flat.RelistedFrom))
+		}
+	}
+
+	if details != nil {
+		if details.CeilingHeight > 0 {
+			res = append(res, i18n.T(lang, "card.ceiling", details.CeilingHeight))
+		}
+		if finish, ok := finishLabel(lang, details.FinishType); ok {
+			res = append(res, i18n.T(lang, "card.finish", finish))
+		}
+		if len(details.WindowView) > 0 {
+			res = append(res, i18n.T(lang, "card.windows", html.EscapeString(strings.Join(details.WindowView, ", "))))
+		}
+		if len(details.Benefits) > 0 {
+			res = append(res, i18n.T(lang, "card.benefits", html.EscapeString(strings.Join(details.Benefits, "; "))))
+		}
+	}
+
+	if flat != nil && len(flat.PriceHistory) > 0 {
+		res = append(res, "", i18n.T(lang, "card.history"))
+		for _, point := range flat.PriceHistory {
+			date := point.Date
+			if t, err := time.Parse(time.RFC3339, point.Date); err == nil {
+				date = t.Format("02.01.2006")
+			}
+			res = append(res, i18n.T(lang, "card.history.point", date, util.ThousandSep(point.Price, " ")))
+		}
+	}
+
+	return strings.Join(res, "\n")
+}
+
+// finishLabel example: 1 => "with finishing", false for the codes without a message
+func finishLabel(lang i18n.Lang, finishType int) (string, bool) {
+	key := fmt.Sprintf("finish.%v", finishType)
+	label := i18n.T(lang, key)
+	return label, label != key
+}
+
+func sendFlatCard(chatID int64, args string) {
+	settings := GetChatSettings(chatID)
+	lang := settings.Lang()
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(args), "#"), 10, 64)
+	if err != nil || id <= 0 {
+		err = SendMessage(chatID, i18n.T(lang, "card.usage", FlatCommand, FlatCommand))
+		if err != nil {
+			log.Printf("failed to send /%v help message to %v: %v", FlatCommand, chatID, err)
+		}
+		return
+	}
+
+	flat, msgData, found := findStoredFlat(id)
+	details, err := downloader.GetFlatDetails(id)
+	if err != nil {
+		log.Printf("failed to get details of flat %v: %v", id, err)
+		details = nil
+	}
+	if !found && details == nil {
+		err = SendMessage(chatID, i18n.T(lang, "card.not.found", id))
+		if err != nil {
+			log.Printf("failed to send flat not found message to %v: %v", chatID, err)
+		}
+		return
+	}
+
+	options := MessageOptions{}
+	if found {
+		options.ReplyMarkup = FlatKeyboard(chatID, flat)
+	}
+	err = SendMessageWithOptions(chatID, FlatCard(lang, id, flat, msgData.OnSale(flat), details, settings.Mortgage), options)
+	if err != nil {
+		log.Printf("failed to send card of flat %v to %v: %v", id, chatID, err)
+		return
+	}
+	sendFlatPlan(chatID, flat)
+}
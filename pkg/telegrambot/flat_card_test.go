@@ -0,0 +1,48 @@
+package telegrambot
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlatCardEscapesDetails(t *testing.T) {
+	details := &flatstorage.FlatDetails{ID: 1, Address: "Москва, <ул. Тестовая>", WindowView: []string{"во двор"},
+		Benefits: []string{"Скидка 5% & ипотека"}}
+
+	card := FlatCard(i18n.En, 1, nil, false, details, nil)
+	require.Contains(t, card, "📍 Москва, &lt;ул. Тестовая&gt;")
+	require.Contains(t, card, "Скидка 5% &amp; ипотека")
+	require.NotContains(t, card, "<ул.")
+
+	// sold means not found by the last poll, however long ago it was
+	flat := &flatstorage.Flat{ID: 1, Updated: "2024-05-20T11:55:00Z"}
+	require.NotContains(t, FlatCard(i18n.En, 1, flat, true, nil, nil), i18n.T(i18n.En, "card.sold"))
+	require.Contains(t, FlatCard(i18n.En, 1, flat, false, nil, nil), i18n.T(i18n.En, "card.sold"))
+}
+
+func TestFlatCardFinish(t *testing.T) {
+	tests := []struct {
+		lang       i18n.Lang
+		finishType int
+		expected   string
+	}{
+		{i18n.Ru, 1, "Отделка: чистовая"},
+		{i18n.En, 1, "Finish: with finishing"},
+		{i18n.Ru, 2, "Отделка: white box"},
+		{i18n.Ru, 0, ""},
+		{i18n.En, 7, ""},
+	}
+
+	for i, test := range tests {
+		card := FlatCard(test.lang, 1, nil, true, &flatstorage.FlatDetails{ID: 1, FinishType: test.finishType}, nil)
+		if len(test.expected) == 0 {
+			require.NotContains(t, card, i18n.T(test.lang, "card.finish", ""), fmt.Sprintf("failed case %v", i))
+			continue
+		}
+		require.Contains(t, card, test.expected, fmt.Sprintf("failed case %v", i))
+	}
+}
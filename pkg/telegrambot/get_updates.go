@@ -72,7 +72,12 @@ type UpdateStruct struct {
 			Length int64  `json:"length"`
 			Type   string `json:"type"`
 		} `json:"entities,omitempty"`
+		Location *struct {
+			Latitude  float64 `json:"latitude"`
+			Longitude float64 `json:"longitude"`
+		} `json:"location,omitempty"`
 	} `json:"message,omitempty"`
+	CallbackQuery *CallbackQuery `json:"callback_query,omitempty"`
 }
 
 type BotUpdatesStruct struct {
@@ -104,12 +109,12 @@ func GetUpdatesOnce() (*BotUpdatesStruct, error) {
 	// also need params (see https://core.telegram.org/bots/api#getting-updates):
 	// offset = latest known update_id + 1
 	// limit = 100
-	// allowed_updates = ["message"]
+	// allowed_updates = ["message", "callback_query"]
 	// timeout = 300 (seconds)
 	values := url.Values{
 		"offset":          []string{fmt.Sprintf("%v", LatestKnownUpdateID+1)},
 		"limit":           []string{fmt.Sprintf("%v", getUpdatesLimitMessages)},
-		"allowed_updates": []string{"message"},
+		"allowed_updates": []string{`["message","callback_query"]`},
 		"timeout":         []string{fmt.Sprintf("%v", getUpdatesPollTimeoutSeconds)},
 	}
 	// post http request
@@ -155,32 +160,114 @@ func processUpdate(update *UpdateStruct) {
 	if update == nil {
 		return
 	}
+	if query := update.CallbackQuery; query != nil {
+		if query.Message != nil {
+			RememberLanguageCode(query.Message.Chat.Id, query.From.LanguageCode)
+		}
+		processCallbackQuery(query)
+		return
+	}
+	if update.Message.Chat.Id != 0 {
+		RememberLanguageCode(update.Message.Chat.Id, update.Message.From.LanguageCode)
+	}
+	if location := update.Message.Location; location != nil {
+		receiveLocation(update.Message.Chat.Id, location.Latitude, location.Longitude)
+	}
 	for _, entity := range update.Message.Entities {
 		if entity.Type != "bot_command" {
 			continue
 		}
-		offset, length := entity.Offset, entity.Length
-		command := strings.TrimLeft(update.Message.Text[offset:offset+length], "/")
-
-		args := update.Message.Text[offset+length:]
-		if strings.Contains(command, "_") {
-			command, args, _ = strings.Cut(command, "_")
-		}
-		args = unEmbedSlug(args)
+		command, args := parseCommand(update.Message.Text, entity.Offset, entity.Length)
 		switch command {
 		case "hello":
 			sendHello(update.Message.Chat.Id, update.Message.From.Username)
-		case "list":
-			sendList(update.Message.Chat.Id)
+		case ListCommand:
+			sendList(update.Message.Chat.Id, args)
+		case FindCommand:
+			sendFind(update.Message.Chat.Id, args)
 		case "start":
-			sendList(update.Message.Chat.Id)
+			sendList(update.Message.Chat.Id, "")
 		case DumpCommand:
 			sendDump(update.Message.Chat.Id, args)
 		case SubscribeCommand:
 			subscribeChat(update.Message.Chat.Id, args)
 		case UnsubscribeCommand:
 			unsubscribeChat(update.Message.Chat.Id, args)
+		case BulksCommand:
+			sendBulks(update.Message.Chat.Id, args)
+		case SubscribeMetroCommand:
+			subscribeArea(update.Message.Chat.Id, args, true)
+		case UnsubscribeMetroCommand:
+			unsubscribeArea(update.Message.Chat.Id, args, true)
+		case SubscribeRegionCommand:
+			subscribeArea(update.Message.Chat.Id, args, false)
+		case UnsubscribeRegionCommand:
+			unsubscribeArea(update.Message.Chat.Id, args, false)
+		case NearCommand:
+			subscribeNear(update.Message.Chat.Id, args)
+		case UnsubscribeNearCommand:
+			unsubscribeNear(update.Message.Chat.Id)
+		case DeliveryCommand:
+			setDelivery(update.Message.Chat.Id, args)
+		case TimezoneCommand:
+			setTimezone(update.Message.Chat.Id, args)
+		case QuietCommand:
+			setQuiet(update.Message.Chat.Id, args)
+		case LangCommand:
+			setLang(update.Message.Chat.Id, args)
+		case FlatCommand:
+			sendFlatCard(update.Message.Chat.Id, args)
+		case PlansCommand:
+			sendBlockPlans(update.Message.Chat.Id, args)
+		case WatchCommand:
+			sendWatch(update.Message.Chat.Id, args)
+		case UnwatchCommand:
+			sendUnwatch(update.Message.Chat.Id, args)
+		case WatchlistCommand:
+			sendWatchlist(update.Message.Chat.Id)
+		case HideCommand:
+			sendHide(update.Message.Chat.Id, args)
+		case UnhideCommand:
+			sendUnhide(update.Message.Chat.Id, args)
+		case UnhideLayoutCommand:
+			sendUnhideLayout(update.Message.Chat.Id, args)
+		case HiddenCommand:
+			sendHidden(update.Message.Chat.Id)
+		case ExportCommand:
+			sendExport(update.Message.Chat.Id, args)
+		case StatsCommand:
+			sendStats(update.Message.Chat.Id, args)
+		case ChartCommand:
+			sendChart(update.Message.Chat.Id, args)
+		case DealsCommand:
+			setDeals(update.Message.Chat.Id, args)
+		case ReportCommand:
+			sendReport(update.Message.Chat.Id, args)
+		case SearchCommand:
+			sendSearch(update.Message.Chat.Id, args)
+		case CompareCommand:
+			sendCompare(update.Message.Chat.Id, args)
+		case BudgetCommand:
+			sendBudget(update.Message.Chat.Id, args)
+		case MortgageCommand:
+			sendMortgage(update.Message.Chat.Id, args)
+		case DisplayCommand:
+			setDisplay(update.Message.Chat.Id, args)
 		}
 
 	}
 }
+
+// parseCommand the command entity and its args: the args embedded into the command ("/dump_2ngt") are un-embedded,
+// the typed ones ("/budget 14_000_000") are kept as is
+func parseCommand(text string, offset int64, length int64) (string, string) {
+	command := strings.TrimLeft(text[offset:offset+length], "/")
+
+	args := text[offset+length:]
+	if strings.Contains(command, "_") {
+		var embedded string
+		command, embedded, _ = strings.Cut(command, "_")
+		args = unEmbedSlug(embedded)
+	}
+	return command, args
+}
@@ -0,0 +1,83 @@
+package telegrambot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		text    string
+		length  int64
+		command string
+		args    string
+	}{
+		{"/budget 14_000_000 dp=2_500k", 7, "budget", " 14_000_000 dp=2_500k"},
+		{"/dump_moskva__2ngt_1", 20, "dump", "moskva/2ngt-1"},
+		{"/dump_2ngt rooms=2", 10, "dump", "2ngt"},
+		{"/stats", 6, "stats", ""},
+	}
+
+	for i, test := range tests {
+		command, args := parseCommand(test.text, 0, test.length)
+		require.Equal(t, test.command, command, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.args, args, fmt.Sprintf("failed case %v", i))
+	}
+
+	// the typed underscores reach the budget parser
+	_, args := parseCommand(tests[0].text, 0, tests[0].length)
+	query, err := ParseBudgetQuery(args)
+	require.NoError(t, err)
+	require.Equal(t, int64(14_000_000), query.Budget)
+	require.Equal(t, int64(2_500_000), query.DownPayment)
+}
+
+func TestTypedSlugs(t *testing.T) {
+	defer func() {
+		delete(BlockSlugs, "alt-53")
+		delete(BlockSlugs, "moskva/x")
+	}()
+	BlockSlugs["alt-53"] = BlockInfo{Slug: "alt-53", Name: "Альтуфьевское 53"}
+	BlockSlugs["moskva/x"] = BlockInfo{Slug: "moskva/x", Name: "Икс"}
+
+	tests := []struct {
+		text   string
+		length int64
+		slug   string
+		rest   string
+	}{
+		// the typed slugs are un-embedded as before, the filters keep their underscores
+		{"/dump alt_53 price<15_000_000", 5, "alt-53", "price<15_000_000"},
+		{"/dump alt-53 rooms=2", 5, "alt-53", "rooms=2"},
+		{"/dump_alt_53", 12, "alt-53", ""},
+		{"/sub moskva__x 1", 4, "moskva/x", "1"},
+		{"/sub /moskva__x", 4, "moskva/x", ""},
+		{"/sub_moskva__x", 14, "moskva/x", ""},
+	}
+
+	for i, test := range tests {
+		_, args := parseCommand(test.text, 0, test.length)
+		slug, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+		slug, err := validateSlug(1, slug, DumpCommand)
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.slug, slug, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.rest, rest, fmt.Sprintf("failed case %v", i))
+	}
+
+	_, args := parseCommand(tests[0].text, 0, tests[0].length)
+	filter, _, err := ParseDumpFilter(strings.TrimPrefix(args, " alt_53 "))
+	require.NoError(t, err)
+	price, err := flatstorage.ParseFilterNumber(filter.Conditions[0].Value)
+	require.NoError(t, err)
+	require.Equal(t, 15_000_000.0, price)
+
+	// /find matches the slug typed either way
+	for i, text := range []string{"/find alt_53", "/find alt-53", "/find_alt_53"} {
+		_, args := parseCommand(text, 0, int64(len(strings.Fields(text)[0])))
+		require.True(t, MatchBlock(BlockSlugs["alt-53"], parseListCommandArgs(args).Text), fmt.Sprintf("failed case %v", i))
+	}
+}
@@ -0,0 +1,309 @@
+package telegrambot
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	HiddenFlatsFile = "data/hidden_flats.json"
+
+	HideCommand         = "hide"
+	UnhideCommand       = "unhide"
+	UnhideLayoutCommand = "unhidelayout"
+	HiddenCommand       = "hidden"
+
+	hideLayoutArg = "layout"
+)
+
+// ChatHidden flats rejected by the chat: never shown in /dump and notifications
+type ChatHidden struct {
+	ChatID   int64    `json:"chat_id"`
+	FlatIDs  []int64  `json:"flat_ids,omitempty"`
+	PlanURLs []string `json:"plan_urls,omitempty"` // the whole layout is hidden
+}
+
+type HiddenFlatsFileMap map[string][]ChatHidden
+
+var (
+	HiddenFlats      = make(map[util.EnvType]map[int64]ChatHidden)
+	hiddenFlatsMutex sync.Mutex
+)
+
+func init() {
+	content, err := os.ReadFile(HiddenFlatsFile)
+	if err != nil {
+		log.Printf("unable to read hidden flats file: %v", err)
+		return
+	}
+
+	fileMap := make(HiddenFlatsFileMap)
+	err = json.Unmarshal(content, &fileMap)
+	if err != nil {
+		log.Printf("unable to unmarshal hidden flats file: %v", err)
+		return
+	}
+
+	for envTypeStr, hiddenList := range fileMap {
+		envType, ok := util.EnvTypeFromString[envTypeStr]
+		if !ok {
+			log.Printf("unknown envtype in hidden flats file: %v", envTypeStr)
+			continue
+		}
+		HiddenFlats[envType] = make(map[int64]ChatHidden, len(hiddenList))
+		for _, hidden := range hiddenList {
+			HiddenFlats[envType][hidden.ChatID] = hidden
+		}
+	}
+}
+
+func syncHiddenFlatsToFile() error {
+	fileMap := make(HiddenFlatsFileMap, len(HiddenFlats))
+	for envtype, hiddenMap := range HiddenFlats {
+		for _, chatID := range util.SortedKeys(hiddenMap) {
+			fileMap[envtype.String()] = append(fileMap[envtype.String()], hiddenMap[chatID])
+		}
+	}
+	newContent, err := json.Marshal(fileMap)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(HiddenFlatsFile, newContent, 0644)
+}
+
+func GetChatHidden(chatID int64) ChatHidden {
+	hiddenFlatsMutex.Lock()
+	defer hiddenFlatsMutex.Unlock()
+
+	hidden, ok := HiddenFlats[util.GetEnvType()][chatID]
+	if !ok {
+		hidden = ChatHidden{ChatID: chatID}
+	}
+	return hidden.Copy()
+}
+
+// UpdateChatHidden apply the change to the hidden flats of the chat and save them
+func UpdateChatHidden(chatID int64, change func(hidden ChatHidden) ChatHidden) error {
+	hiddenFlatsMutex.Lock()
+	defer hiddenFlatsMutex.Unlock()
+
+	envtype := util.GetEnvType()
+	if HiddenFlats[envtype] == nil {
+		HiddenFlats[envtype] = make(map[int64]ChatHidden)
+	}
+	old, ok := HiddenFlats[envtype][chatID]
+	if !ok {
+		old = ChatHidden{ChatID: chatID}
+	}
+
+	// the change gets its own slices, so that the old ones stay intact for the rollback and the readers
+	hidden := change(old.Copy())
+	if hidden.Empty() {
+		delete(HiddenFlats[envtype], chatID)
+	} else {
+		HiddenFlats[envtype][chatID] = hidden
+	}
+
+	err := syncHiddenFlatsToFile()
+	if err != nil {
+		if ok {
+			HiddenFlats[envtype][chatID] = old
+		} else {
+			delete(HiddenFlats[envtype], chatID)
+		}
+		return err
+	}
+	return nil
+}
+
+// Copy does not share the slices with the original
+func (h ChatHidden) Copy() ChatHidden {
+	h.FlatIDs = append([]int64(nil), h.FlatIDs...)
+	h.PlanURLs = append([]string(nil), h.PlanURLs...)
+	return h
+}
+
+func (h ChatHidden) Empty() bool {
+	return len(h.FlatIDs) == 0 && len(h.PlanURLs) == 0
+}
+
+// Hides true if the flat itself or its layout is hidden
+func (h ChatHidden) Hides(flat *flatstorage.Flat) bool {
+	return h.HidesFlat(flat.ID) || h.HidesLayout(flat.PlanURL)
+}
+
+func (h ChatHidden) HidesFlat(id int64) bool {
+	for _, hiddenID := range h.FlatIDs {
+		if hiddenID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (h ChatHidden) HidesLayout(planURL string) bool {
+	if len(planURL) == 0 {
+		return false
+	}
+	for _, hiddenURL := range h.PlanURLs {
+		if hiddenURL == planURL {
+			return true
+		}
+	}
+	return false
+}
+
+// hideFlat example args: "819556" hides the flat, "819556 layout" hides all flats with its floor plan;
+// returns a short result text for the callback answer
+func hideFlat(chatID int64, args string) string {
+	lang := ChatLang(chatID)
+
+	idStr, layoutArg, _ := strings.Cut(strings.TrimSpace(args), " ")
+	layout := strings.TrimSpace(layoutArg) == hideLayoutArg
+	id, ok := parseFlatID(idStr)
+	if !ok {
+		return i18n.T(lang, "hide.usage", HideCommand, HideCommand, HideCommand)
+	}
+
+	var planURL string
+	if layout {
+		flat, found := FindFlat(id)
+		if !found {
+			return i18n.T(lang, "card.not.found", id)
+		}
+		if len(flat.PlanURL) == 0 {
+			return i18n.T(lang, "hide.no.layout", id)
+		}
+		planURL = flat.PlanURL
+	}
+
+	err := UpdateChatHidden(chatID, func(hidden ChatHidden) ChatHidden {
+		if layout && !hidden.HidesLayout(planURL) {
+			hidden.PlanURLs = append(hidden.PlanURLs, planURL)
+		}
+		if !layout && !hidden.HidesFlat(id) {
+			hidden.FlatIDs = append(hidden.FlatIDs, id)
+		}
+		return hidden
+	})
+	if err != nil {
+		log.Printf("failed to hide flat %v in %v: %v", id, chatID, err)
+		return i18n.T(lang, "hide.failed", err)
+	}
+
+	if layout {
+		return i18n.T(lang, "hide.layout.done", id, HiddenCommand)
+	}
+	return i18n.T(lang, "hide.done", id, UnhideCommand, id)
+}
+
+func unhideFlat(chatID int64, args string) string {
+	lang := ChatLang(chatID)
+
+	id, ok := parseFlatID(args)
+	if !ok {
+		return i18n.T(lang, "unhide.usage", UnhideCommand, UnhideCommand)
+	}
+	if !GetChatHidden(chatID).HidesFlat(id) {
+		return i18n.T(lang, "unhide.not.hidden", id)
+	}
+
+	err := UpdateChatHidden(chatID, func(hidden ChatHidden) ChatHidden {
+		hidden.FlatIDs = util.FilterSliceInPlace(hidden.FlatIDs, func(i int) bool {
+			return hidden.FlatIDs[i] != id
+		})
+		return hidden
+	})
+	if err != nil {
+		log.Printf("failed to unhide flat %v in %v: %v", id, chatID, err)
+		return i18n.T(lang, "hide.failed", err)
+	}
+	return i18n.T(lang, "unhide.done", id)
+}
+
+// unhideLayout args: the number of the layout in /hidden, starting with 1
+func unhideLayout(chatID int64, args string) string {
+	lang := ChatLang(chatID)
+
+	n, err := strconv.Atoi(strings.TrimSpace(args))
+	planURLs := GetChatHidden(chatID).PlanURLs
+	if err != nil || n < 1 || n > len(planURLs) {
+		return i18n.T(lang, "unhide.layout.usage", UnhideLayoutCommand, HiddenCommand)
+	}
+	planURL := planURLs[n-1]
+
+	err = UpdateChatHidden(chatID, func(hidden ChatHidden) ChatHidden {
+		hidden.PlanURLs = util.FilterSliceInPlace(hidden.PlanURLs, func(i int) bool {
+			return hidden.PlanURLs[i] != planURL
+		})
+		return hidden
+	})
+	if err != nil {
+		log.Printf("failed to unhide layout %v in %v: %v", planURL, chatID, err)
+		return i18n.T(lang, "hide.failed", err)
+	}
+	return i18n.T(lang, "unhide.layout.done", n)
+}
+
+func sendHide(chatID int64, args string) {
+	err := SendMessage(chatID, hideFlat(chatID, args))
+	if err != nil {
+		log.Printf("failed to send /%v result to %v: %v", HideCommand, chatID, err)
+	}
+}
+
+func sendUnhide(chatID int64, args string) {
+	err := SendMessage(chatID, unhideFlat(chatID, args))
+	if err != nil {
+		log.Printf("failed to send /%v result to %v: %v", UnhideCommand, chatID, err)
+	}
+}
+
+func sendUnhideLayout(chatID int64, args string) {
+	err := SendMessage(chatID, unhideLayout(chatID, args))
+	if err != nil {
+		log.Printf("failed to send /%v result to %v: %v", UnhideLayoutCommand, chatID, err)
+	}
+}
+
+// Format example:
+// 🙈 Hidden flats (2):
+// #819556 /flat_819556 /unhide_819556
+// 🙈 Hidden layouts (1):
+// 1. layout /unhidelayout_1
+func (h ChatHidden) Format(lang i18n.Lang) string {
+	if h.Empty() {
+		return i18n.T(lang, "hidden.empty", HideCommand, HideCommand, HideCommand)
+	}
+
+	var res []string
+	if len(h.FlatIDs) > 0 {
+		res = append(res, i18n.T(lang, "hidden.flats", len(h.FlatIDs)))
+		for _, id := range h.FlatIDs {
+			res = append(res, fmt.Sprintf("#%v /%v_%v /%v_%v", id, FlatCommand, id, UnhideCommand, id))
+		}
+	}
+	if len(h.PlanURLs) > 0 {
+		res = append(res, i18n.T(lang, "hidden.layouts", len(h.PlanURLs)))
+		for i, planURL := range h.PlanURLs {
+			res = append(res, fmt.Sprintf("%v. %v /%v_%v",
+				i+1, i18n.T(lang, "hidden.layout.link", planURL), UnhideLayoutCommand, i+1))
+		}
+	}
+	return strings.Join(res, "\n")
+}
+
+func sendHidden(chatID int64) {
+	err := SendMessage(chatID, GetChatHidden(chatID).Format(ChatLang(chatID)))
+	if err != nil {
+		log.Printf("failed to send hidden flats to %v: %v", chatID, err)
+	}
+}
@@ -0,0 +1,55 @@
+package telegrambot
+
+import (
+	"testing"
+
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatHiddenHides(t *testing.T) {
+	hidden := ChatHidden{FlatIDs: []int64{1}, PlanURLs: []string{"a.svg"}}
+
+	update := &flatstorage.BlockUpdate{NewFlats: &flatstorage.MessageData{Flats: []flatstorage.Flat{
+		{ID: 1, PlanURL: "b.svg"},
+		{ID: 2, PlanURL: "a.svg"},
+		{ID: 3, PlanURL: "b.svg"},
+		{ID: 4},
+	}}}
+
+	res := update.Reject(hidden.Hides)
+	require.Len(t, res.NewFlats.Flats, 2)
+	require.Equal(t, int64(3), res.NewFlats.Flats[0].ID)
+	require.Equal(t, int64(4), res.NewFlats.Flats[1].ID)
+	require.Len(t, update.NewFlats.Flats, 4)
+
+	require.True(t, ChatHidden{}.Empty())
+	require.False(t, ChatHidden{}.HidesLayout(""))
+	require.True(t, update.Reject(ChatHidden{PlanURLs: []string{"a.svg", "b.svg"}, FlatIDs: []int64{4}}.Hides).Empty())
+}
+
+func TestUpdateChatHiddenRollback(t *testing.T) {
+	envtype := util.GetEnvType()
+	oldHidden := HiddenFlats[envtype]
+	defer func() { HiddenFlats[envtype] = oldHidden }()
+
+	HiddenFlats[envtype] = map[int64]ChatHidden{1: {ChatID: 1, FlatIDs: []int64{1, 2, 3}, PlanURLs: []string{"a.svg", "b.svg"}}}
+	read := GetChatHidden(1)
+
+	// no data dir in the package: the sync fails and the change is rolled back
+	err := UpdateChatHidden(1, func(hidden ChatHidden) ChatHidden {
+		hidden.FlatIDs = util.FilterSliceInPlace(hidden.FlatIDs, func(i int) bool {
+			return hidden.FlatIDs[i] != 1
+		})
+		hidden.PlanURLs = util.FilterSliceInPlace(hidden.PlanURLs, func(i int) bool {
+			return hidden.PlanURLs[i] != "a.svg"
+		})
+		return hidden
+	})
+	require.Error(t, err)
+
+	expected := ChatHidden{ChatID: 1, FlatIDs: []int64{1, 2, 3}, PlanURLs: []string{"a.svg", "b.svg"}}
+	require.Equal(t, expected, HiddenFlats[envtype][1])
+	require.Equal(t, expected, read)
+}
@@ -0,0 +1,249 @@
+package telegrambot
+
+import (
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"log"
+	"strings"
+)
+
+const (
+	callbackDataLimit     = 64 // bytes, Telegram limit
+	callbackDataSeparator = "|"
+
+	callbackAliasPrefix    = "~"
+	callbackAliasMinLength = 8 // bytes, shorter arguments are kept as is
+
+	CallbackSubscribe   = "sub"
+	CallbackUnsubscribe = "unsub"
+	CallbackDump        = "dump"
+	CallbackListPage    = "list"
+	CallbackPlans       = "plans"
+	CallbackWatch       = "watch"
+	CallbackUnwatch     = "unwatch"
+	CallbackHide        = "hide"
+	CallbackUnhide      = "unhide"
+	CallbackChart       = "chart"
+	CallbackSearchPage  = "search"
+
+	// which keyboard to re-render after the button is pressed
+	keyboardBlock = "b"
+	keyboardList  = "l"
+	keyboardFlat  = "f"
+
+	listButtonNameLimit = 24
+)
+
+// InlineKeyboardMarkup see https://core.telegram.org/bots/api#inlinekeyboardmarkup
+type InlineKeyboardMarkup struct {
+	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"`
+}
+
+type InlineKeyboardButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data,omitempty"`
+	URL          string `json:"url,omitempty"`
+}
+
+// CallbackData example: "dump|2ngt|rooms=2" => {Action: "dump", Args: ["2ngt", "rooms=2"]}
+type CallbackData struct {
+	Action string
+	Args   []string
+}
+
+func (c CallbackData) Encode() (string, error) {
+	res := strings.Join(append([]string{c.Action}, c.Args...), callbackDataSeparator)
+	if len(res) > callbackDataLimit {
+		return "", fmt.Errorf("callback data is too long (%v bytes): %v", len(res), res)
+	}
+	return res, nil
+}
+
+func DecodeCallbackData(data string) (CallbackData, error) {
+	parts := strings.Split(data, callbackDataSeparator)
+	if len(parts[0]) == 0 {
+		return CallbackData{}, fmt.Errorf("empty callback action: %v", data)
+	}
+	args := parts[1:]
+	for i := range args {
+		arg, err := resolveCallbackArg(args[i])
+		if err != nil {
+			return CallbackData{}, err
+		}
+		args[i] = arg
+	}
+	return CallbackData{Action: parts[0], Args: args}, nil
+}
+
+// Arg safe access to the i-th argument
+func (c CallbackData) Arg(i int) string {
+	if i < 0 || i >= len(c.Args) {
+		return ""
+	}
+	return c.Args[i]
+}
+
+// unsafeCallbackArg the argument would break the decoding
+func unsafeCallbackArg(arg string) bool {
+	return strings.Contains(arg, callbackDataSeparator) || strings.HasPrefix(arg, callbackAliasPrefix)
+}
+
+func (c CallbackData) unsafe() bool {
+	for _, arg := range c.Args {
+		if unsafeCallbackArg(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// Alias replace the arguments that are long or unsafe by short aliases
+func (c CallbackData) Alias() CallbackData {
+	res := CallbackData{Action: c.Action}
+	for _, arg := range c.Args {
+		if len(arg) > callbackAliasMinLength || unsafeCallbackArg(arg) {
+			arg = aliasCallbackArg(arg)
+		}
+		res.Args = append(res.Args, arg)
+	}
+	return res
+}
+
+// callbackButton the arguments are aliased if the data does not fit into the limit,
+// drops the button (returns false) if it still does not fit
+func callbackButton(text string, action string, args ...string) (InlineKeyboardButton, bool) {
+	callbackData := CallbackData{Action: action, Args: args}
+	data, err := callbackData.Encode()
+	if err != nil || callbackData.unsafe() {
+		data, err = callbackData.Alias().Encode()
+	}
+	if err != nil {
+		log.Printf("dropped button %q: %v", text, err)
+		return InlineKeyboardButton{}, false
+	}
+	return InlineKeyboardButton{Text: text, CallbackData: data}, true
+}
+
+func appendButton(row []InlineKeyboardButton, text string, action string, args ...string) []InlineKeyboardButton {
+	if button, ok := callbackButton(text, action, args...); ok {
+		row = append(row, button)
+	}
+	return row
+}
+
+func (m *InlineKeyboardMarkup) addRow(row []InlineKeyboardButton) {
+	if len(row) > 0 {
+		m.InlineKeyboard = append(m.InlineKeyboard, row)
+	}
+}
+
+func (m *InlineKeyboardMarkup) Empty() bool {
+	return m == nil || len(m.InlineKeyboard) == 0
+}
+
+// BlockKeyboard subscribe/unsubscribe, dump, filter and grouping buttons for a single block
+func BlockKeyboard(chatID int64, slug string) *InlineKeyboardMarkup {
+	markup := &InlineKeyboardMarkup{}
+	lang := ChatLang(chatID)
+
+	var row []InlineKeyboardButton
+	if CheckSubscribed(chatID, slug) {
+		row = appendButton(row, i18n.T(lang, "button.unsubscribe"), CallbackUnsubscribe, slug, keyboardBlock)
+	} else {
+		row = appendButton(row, i18n.T(lang, "button.subscribe"), CallbackSubscribe, slug, keyboardBlock)
+	}
+	row = appendButton(row, i18n.T(lang, "button.dump"), CallbackDump, slug)
+	row = appendButton(row, i18n.T(lang, "button.plans"), CallbackPlans, slug)
+	markup.addRow(row)
+
+	var filters []InlineKeyboardButton
+	for _, rooms := range []string{"1", "2", "3"} {
+		filters = appendButton(filters, i18n.T(lang, "button.rooms", rooms), CallbackDump, slug, "rooms="+rooms)
+	}
+	filters = appendButton(filters, i18n.T(lang, "button.rooms", "4+"), CallbackDump, slug, "rooms>=4")
+	markup.addRow(filters)
+
+	var groups []InlineKeyboardButton
+	for _, groupBy := range []flatstorage.GroupBy{flatstorage.GroupByLayout, flatstorage.GroupByBulk, flatstorage.GroupByRooms} {
+		groups = appendButton(groups, i18n.T(lang, "button.group."+string(groupBy)), CallbackDump, slug, dumpGroupPrefix+string(groupBy))
+	}
+	markup.addRow(groups)
+
+	return markup
+}
+
+// FlatKeyboard watch/unwatch the flat, its price chart, all flats of its block, hide the flat or its layout
+func FlatKeyboard(chatID int64, flat *flatstorage.Flat) *InlineKeyboardMarkup {
+	markup := &InlineKeyboardMarkup{}
+	lang := ChatLang(chatID)
+	id := fmt.Sprintf("%v", flat.ID)
+
+	var row []InlineKeyboardButton
+	if CheckWatched(chatID, flat.ID) {
+		row = appendButton(row, i18n.T(lang, "button.unwatch"), CallbackUnwatch, id, keyboardFlat)
+	} else {
+		row = appendButton(row, i18n.T(lang, "button.watch"), CallbackWatch, id, keyboardFlat)
+	}
+	row = appendButton(row, i18n.T(lang, "button.chart"), CallbackChart, id)
+	row = appendButton(row, i18n.T(lang, "button.dump"), CallbackDump, flat.BlockSlug)
+	markup.addRow(row)
+
+	var hideRow []InlineKeyboardButton
+	hidden := GetChatHidden(chatID)
+	if hidden.HidesFlat(flat.ID) {
+		hideRow = appendButton(hideRow, i18n.T(lang, "button.unhide"), CallbackUnhide, id, keyboardFlat)
+	} else {
+		hideRow = appendButton(hideRow, i18n.T(lang, "button.hide"), CallbackHide, id, keyboardFlat)
+	}
+	if len(flat.PlanURL) > 0 && !hidden.HidesLayout(flat.PlanURL) {
+		hideRow = appendButton(hideRow, i18n.T(lang, "button.hide.layout"), CallbackHide, id, keyboardFlat, hideLayoutArg)
+	}
+	markup.addRow(hideRow)
+
+	return markup
+}
+
+// ListKeyboard unsubscribe and dump buttons for the subscribed blocks on the page, paging and filter buttons
+func ListKeyboard(chatID int64, lang i18n.Lang, query ListQuery, pageSlugs []string, pages int) *InlineKeyboardMarkup {
+	markup := &InlineKeyboardMarkup{}
+
+	subscriptions := GetChatSubscriptions(chatID)
+	for _, slug := range pageSlugs {
+		if _, ok := subscriptions[slug]; !ok {
+			continue
+		}
+		name := []rune(BlockSlugs[slug].Name)
+		if len(name) > listButtonNameLimit {
+			name = append(name[:listButtonNameLimit-1], '…')
+		}
+		var row []InlineKeyboardButton
+		row = appendButton(row, fmt.Sprintf("🔕 %v", string(name)), CallbackUnsubscribe, append([]string{slug, keyboardList}, query.Args()...)...)
+		row = appendButton(row, "📋", CallbackDump, slug)
+		markup.addRow(row)
+	}
+
+	var nav []InlineKeyboardButton
+	if query.Page > 0 {
+		prev := query
+		prev.Page--
+		nav = appendButton(nav, i18n.T(lang, "button.prev"), CallbackListPage, prev.Args()...)
+	}
+	if query.Page < pages-1 {
+		next := query
+		next.Page++
+		nav = appendButton(nav, i18n.T(lang, "button.next"), CallbackListPage, next.Args()...)
+	}
+	markup.addRow(nav)
+
+	toggle := query
+	toggle.Page = 0
+	toggle.Subscribed = !query.Subscribed
+	text := i18n.T(lang, "button.only.subscribed")
+	if query.Subscribed {
+		text = i18n.T(lang, "button.all.complexes")
+	}
+	markup.addRow(appendButton(nil, text, CallbackListPage, toggle.Args()...))
+
+	return markup
+}
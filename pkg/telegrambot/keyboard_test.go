@@ -0,0 +1,269 @@
+package telegrambot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallbackData(t *testing.T) {
+	tests := []struct {
+		data      CallbackData
+		encoded   string
+		encodeErr bool
+	}{
+		{data: CallbackData{Action: CallbackDump, Args: []string{"2ngt", "rooms=2"}}, encoded: "dump|2ngt|rooms=2"},
+		{data: CallbackData{Action: CallbackSubscribe, Args: []string{"2ngt", keyboardBlock}}, encoded: "sub|2ngt|b"},
+		{data: CallbackData{Action: CallbackDump}, encoded: "dump"},
+		{data: CallbackData{Action: CallbackDump, Args: []string{strings.Repeat("x", 64)}}, encodeErr: true},
+	}
+
+	for i, test := range tests {
+		encoded, err := test.data.Encode()
+		if test.encodeErr {
+			require.Error(t, err, fmt.Sprintf("failed case %v", i))
+			continue
+		}
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.encoded, encoded, fmt.Sprintf("failed case %v", i))
+
+		decoded, err := DecodeCallbackData(encoded)
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.data.Action, decoded.Action, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, len(test.data.Args), len(decoded.Args), fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.data.Arg(0), decoded.Arg(0), fmt.Sprintf("failed case %v", i))
+	}
+
+	_, err := DecodeCallbackData("")
+	require.Error(t, err)
+
+	// long and unsafe arguments are aliased instead of dropping the button
+	longSlug := "moskva/" + strings.Repeat("x", 64)
+	for i, args := range [][]string{
+		{longSlug, keyboardList, "2s", "Второй Нагатинский"},
+		{"a|b", "~1"},
+		{"2ngt", keyboardBlock},
+	} {
+		button, ok := callbackButton("text", CallbackUnsubscribe, args...)
+		require.True(t, ok, fmt.Sprintf("failed case %v", i))
+		require.LessOrEqual(t, len(button.CallbackData), callbackDataLimit, fmt.Sprintf("failed case %v", i))
+		decoded, err := DecodeCallbackData(button.CallbackData)
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, CallbackData{Action: CallbackUnsubscribe, Args: args}, decoded, fmt.Sprintf("failed case %v", i))
+	}
+	require.Equal(t, "sub|2ngt|b", appendButton(nil, "text", CallbackSubscribe, "2ngt", keyboardBlock)[0].CallbackData)
+}
+
+func TestCallbackAliases(t *testing.T) {
+	now := time.Date(2024, 5, 18, 12, 0, 0, 0, time.UTC)
+	query := "rooms=2 price<20000000 sort=price"
+
+	// the id depends on the argument only, the buttons rendered before restart keep working
+	id := aliasCallbackArgAt(query, now)
+	require.Equal(t, callbackAliasID(query, callbackAliasHashLength), id)
+	require.Equal(t, id, aliasCallbackArgAt(query, now))
+	require.NotEqual(t, id, aliasCallbackArgAt("rooms=3", now))
+	arg, err := resolveCallbackArgAt(id, now.Add(callbackAliasTTL))
+	require.NoError(t, err)
+	require.Equal(t, query, arg)
+
+	// unknown and expired aliases are never resolved into another argument
+	for i, test := range []struct {
+		alias string
+		at    time.Time
+	}{
+		{"~unknown0", now},
+		{id, now.Add(callbackAliasTTL + time.Second)},
+	} {
+		_, err = resolveCallbackArgAt(test.alias, test.at)
+		require.ErrorIs(t, err, ErrCallbackExpired, fmt.Sprintf("failed case %v", i))
+	}
+	_, err = DecodeCallbackData(CallbackSearchPage + "|1|~unknown0")
+	require.ErrorIs(t, err, ErrCallbackExpired)
+
+	// the least recently used aliases are dropped above the limit
+	callbackAliasesMutex.Lock()
+	for i := 0; i < callbackAliasesLimit; i++ {
+		callbackAliases[fmt.Sprintf("~old%v", i)] = CallbackAlias{Arg: "old", Used: now.Add(-time.Hour)}
+	}
+	callbackAliases["~stale"] = CallbackAlias{Arg: "stale", Used: now.Add(-callbackAliasTTL - time.Hour)}
+	callbackAliasesMutex.Unlock()
+
+	fresh := aliasCallbackArgAt("fresh argument", now)
+	require.Len(t, callbackAliases, callbackAliasesLimit)
+	for _, alias := range []string{fresh, id} {
+		_, err = resolveCallbackArgAt(alias, now)
+		require.NoError(t, err)
+	}
+	_, err = resolveCallbackArgAt("~stale", now)
+	require.ErrorIs(t, err, ErrCallbackExpired)
+}
+
+func TestListQueryArgs(t *testing.T) {
+	tests := []struct {
+		query    ListQuery
+		args     []string
+		expected ListQuery
+	}{
+		{query: ListQuery{}, args: []string{"0"}, expected: ListQuery{}},
+		{query: ListQuery{Page: 2, Subscribed: true}, args: []string{"2s"}, expected: ListQuery{Page: 2, Subscribed: true}},
+		{query: ListQuery{Page: 1, Text: " Нагатинский "}, args: []string{"1", "Нагатинский"}, expected: ListQuery{Page: 1, Text: "Нагатинский"}},
+		{query: ListQuery{Page: 1, Text: "!!"}, args: []string{"1"}, expected: ListQuery{Page: 1}},
+	}
+
+	for i, test := range tests {
+		require.Equal(t, test.args, test.query.Args(), fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.expected, ParseListQueryArgs(test.args), fmt.Sprintf("failed case %v", i))
+	}
+
+	block := BlockInfo{Name: "Второй Нагатинский", Slug: "2ngt", Metro: "Нагатинская"}
+	require.True(t, MatchBlock(block, "нагатинский"))
+	require.True(t, MatchBlock(block, "NAGATINSK"))
+	require.True(t, MatchBlock(block, "2ngt"))
+	require.True(t, MatchBlock(block, ""))
+	require.False(t, MatchBlock(block, "Кутузовский"))
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		args     string
+		expected string
+		isErr    bool
+	}{
+		{"rooms=2 price<15m metro=Нагатинская", "rooms=2 price<15m metro=Нагатинская sort=price", false},
+		{"sort=-m2price rooms>=3", "rooms>=3 sort=-meterprice", false},
+		{"SORT=created", "sort=created", false},
+		{"sort=metro", "", true},
+		{"sort=price sort=area", "", true},
+		{"rooms~2", "", true},
+	}
+
+	for i, test := range tests {
+		query, err := ParseSearchQuery(test.args)
+		if test.isErr {
+			require.Error(t, err, fmt.Sprintf("failed case %v", i))
+			continue
+		}
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.expected, query.String(), fmt.Sprintf("failed case %v", i))
+
+		// each page button carries its own query
+		button, ok := callbackButton("next", CallbackSearchPage, query.PageArgs(2)...)
+		require.True(t, ok, fmt.Sprintf("failed case %v", i))
+		decoded, err := DecodeCallbackData(button.CallbackData)
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+		paged, err := ParseSearchPageArgs(decoded.Args)
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+		query.Page = 2
+		require.Equal(t, query, paged, fmt.Sprintf("failed case %v", i))
+	}
+
+	_, err := ParseSearchPageArgs([]string{"1"})
+	require.Error(t, err)
+	_, err = ParseSearchPageArgs([]string{"1", "~unknown"})
+	require.Error(t, err)
+}
+
+func TestParseDumpFilter(t *testing.T) {
+	tests := []struct {
+		args    string
+		filter  string
+		groupBy flatstorage.GroupBy
+		isErr   bool
+	}{
+		{"rooms=2 price<15m", "rooms=2 price<15m", "", false},
+		{"group=layout rooms=2", "rooms=2", flatstorage.GroupByLayout, false},
+		{"GROUP=bulks", "", flatstorage.GroupByBulk, false},
+		{"group=metro", "", "", true},
+		{"rooms~2 group=rooms", "", "", true},
+	}
+
+	for i, test := range tests {
+		filter, groupBy, err := ParseDumpFilter(test.args)
+		if test.isErr {
+			require.Error(t, err, fmt.Sprintf("failed case %v", i))
+			continue
+		}
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.filter, filter.String(), fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.groupBy, groupBy, fmt.Sprintf("failed case %v", i))
+	}
+}
+
+func TestToggleSubscription(t *testing.T) {
+	envType := util.GetEnvType()
+	oldChannels := ChannelIDs[envType]
+	defer func() {
+		ChannelIDs[envType] = oldChannels
+	}()
+	ChannelIDs[envType] = []ChannelInfo{{ChatID: 1, BlockSlug: "2ngt"}}
+	lang := ChatLang(1)
+
+	tests := []struct {
+		slug      string
+		subscribe bool
+		expected  string
+	}{
+		{"2ngt", true, i18n.T(lang, "button.sub.already", "2ngt")},
+		{"alt53", false, i18n.T(lang, "button.unsub.already", "alt53")},
+		{"no-such-block", true, i18n.T(lang, "button.unknown")},
+	}
+
+	for i, test := range tests {
+		require.Equal(t, test.expected, toggleSubscription(1, test.slug, test.subscribe), fmt.Sprintf("failed case %v", i))
+	}
+	require.Equal(t, []ChannelInfo{{ChatID: 1, BlockSlug: "2ngt"}}, ChannelIDs[envType])
+}
+
+func TestProcessCallbackQuery(t *testing.T) {
+	oldUrl := botApiUrl
+	defer func() { botApiUrl = oldUrl }()
+
+	// method: text of the answer or the message
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		calls = append(calls, path.Base(r.URL.Path)+": "+r.Form.Get("text"))
+		_, _ = fmt.Fprint(w, `{"ok":true,"result":{"message_id":1}}`)
+	}))
+	defer server.Close()
+	botApiUrl = server.URL
+
+	lang := ChatLang(1)
+	tests := []struct {
+		data     CallbackData
+		expected []string
+	}{
+		// the slow actions are answered before the work, their result comes as a message
+		{CallbackData{Action: CallbackSearchPage, Args: []string{"1", "rooms~2"}},
+			[]string{"answerCallbackQuery: ", "sendMessage: " + i18n.T(lang, "search.expired", SearchCommand)}},
+		// the alias of the query is unknown: the button must not show another query
+		{CallbackData{Action: CallbackSearchPage, Args: []string{"1", "~unknown0"}},
+			[]string{"answerCallbackQuery: " + i18n.T(lang, "button.expired")}},
+		// the quick toggles are answered with their result
+		{CallbackData{Action: CallbackWatch, Args: []string{"x"}},
+			[]string{"answerCallbackQuery: " + i18n.T(lang, "watch.usage", WatchCommand, WatchCommand)}},
+	}
+
+	for i, test := range tests {
+		calls = nil
+		encoded, err := test.data.Encode()
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+		query := &CallbackQuery{}
+		body := fmt.Sprintf(`{"id":"q%v","message":{"message_id":2,"chat":{"id":1}},"data":%q}`, i, encoded)
+		require.NoError(t, json.Unmarshal([]byte(body), query), fmt.Sprintf("failed case %v", i))
+
+		processCallbackQuery(query)
+		require.Equal(t, test.expected, calls, fmt.Sprintf("failed case %v", i))
+	}
+}
@@ -0,0 +1,188 @@
+package telegrambot
+
+import (
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+)
+
+const (
+	ListCommand = "list"
+	FindCommand = "find"
+
+	ListPageSize = 20
+
+	// listSubscribedFlag e.g. "/list sub" or the page state "2s"
+	listSubscribedFlag = "s"
+)
+
+// ListQuery one page of /list or /find
+type ListQuery struct {
+	Page       int    // zero-based
+	Subscribed bool   // only blocks the chat is subscribed to
+	Text       string // search text, see util.SearchKey
+}
+
+// Args compact callback representation, example: {Page: 2, Subscribed: true, Text: "Нагатинский"} => ["2s", "Нагатинский"],
+// the text is kept as typed for the header and aliased by callbackButton if too long
+func (q ListQuery) Args() []string {
+	state := strconv.Itoa(q.Page)
+	if q.Subscribed {
+		state += listSubscribedFlag
+	}
+	text := strings.TrimSpace(q.Text)
+	if len(util.SearchKey(text)) == 0 {
+		return []string{state}
+	}
+	return []string{state, text}
+}
+
+func ParseListQueryArgs(args []string) ListQuery {
+	var res ListQuery
+	if len(args) == 0 {
+		return res
+	}
+	state := args[0]
+	if strings.HasSuffix(state, listSubscribedFlag) {
+		res.Subscribed = true
+		state = strings.TrimSuffix(state, listSubscribedFlag)
+	}
+	res.Page, _ = strconv.Atoi(state)
+	if len(args) > 1 {
+		res.Text = args[1]
+	}
+	return res
+}
+
+// parseListCommandArgs example: "/list sub", "/find нагат sub"
+func parseListCommandArgs(args string) ListQuery {
+	var res ListQuery
+	var words []string
+	for _, word := range strings.Fields(args) {
+		switch strings.ToLower(word) {
+		case "sub", "subscribed", "my":
+			res.Subscribed = true
+		default:
+			words = append(words, word)
+		}
+	}
+	res.Text = strings.Join(words, " ")
+	return res
+}
+
+// MatchBlock case-insensitive and transliteration-aware search over the name, slug and metro
+func MatchBlock(block BlockInfo, text string) bool {
+	key := util.SearchKey(text)
+	if len(key) == 0 {
+		return true
+	}
+	for _, field := range []string{block.Name, block.Slug, block.Metro} {
+		if strings.Contains(util.SearchKey(field), key) {
+			return true
+		}
+	}
+	return false
+}
+
+// findBlocks sorted slugs of the blocks matching the query, all pages
+func findBlocks(chatID int64, query ListQuery) []string {
+	subscribedTo := GetChatSubscriptions(chatID)
+
+	var res []string
+	for _, slug := range util.SortedKeys(BlockSlugs) {
+		if _, isSubscribed := subscribedTo[slug]; query.Subscribed && !isSubscribed {
+			continue
+		}
+		if !MatchBlock(BlockSlugs[slug], query.Text) {
+			continue
+		}
+		res = append(res, slug)
+	}
+	return res
+}
+
+// pageCount at least one page, even an empty one
+func pageCount(total int) int {
+	return util.Max(1, (total+ListPageSize-1)/ListPageSize)
+}
+
+// renderList the text and the keyboard of a single page
+func renderList(chatID int64, query ListQuery) (string, *InlineKeyboardMarkup) {
+	slugs := findBlocks(chatID, query)
+	pages := pageCount(len(slugs))
+	query.Page = util.Min(util.Max(query.Page, 0), pages-1)
+
+	from := query.Page * ListPageSize
+	to := util.Min(from+ListPageSize, len(slugs))
+	pageSlugs := slugs[from:to]
+
+	subscribedTo := GetChatSubscriptions(chatID)
+	lang := ChatLang(chatID)
+
+	var complexes []string
+	for _, slug := range pageSlugs {
+		subscription, isSubscribed := subscribedTo[slug]
+		distance := chatBlockDistance(chatID, BlockSlugs[slug])
+		complexes = append(complexes, BlockSlugs[slug].StringWithSub(lang, isSubscribed, subscription.Bulks, distance))
+	}
+
+	header := i18n.T(lang, "list.header")
+	if query.Subscribed {
+		header = i18n.T(lang, "list.header.subscribed")
+	}
+	if len(query.Text) > 0 {
+		header += " " + i18n.T(lang, "list.matching", html.EscapeString(query.Text))
+	}
+	header += fmt.Sprintf(" (%v", len(slugs))
+	if pages > 1 {
+		header += ", " + i18n.T(lang, "list.page", query.Page+1, pages)
+	}
+	header += "):"
+
+	msg := header + "\n" + strings.Join(complexes, "\n")
+	if len(slugs) == 0 {
+		msg = header + "\n" + i18n.T(lang, "list.empty", FindCommand)
+	}
+	if areas := areaSubscriptionsList(chatID); len(areas) > 0 && query.Page == 0 && len(query.Text) == 0 {
+		msg = areas + "\n\n" + msg
+	}
+
+	return msg, ListKeyboard(chatID, lang, query, pageSlugs, pages)
+}
+
+func sendList(chatID int64, args string) {
+	sendListPage(chatID, parseListCommandArgs(args))
+}
+
+func sendListPage(chatID int64, query ListQuery) {
+	msg, keyboard := renderList(chatID, query)
+	err := SendMessageWithOptions(chatID, msg, MessageOptions{ReplyMarkup: keyboard})
+	if err != nil {
+		log.Printf("failed to send list of all blocks to chatID %v: %v", chatID, err)
+	}
+}
+
+// editListPage show another page in the same message
+func editListPage(chatID int64, messageID int64, query ListQuery) {
+	msg, keyboard := renderList(chatID, query)
+	err := EditMessageText(chatID, messageID, msg, keyboard)
+	if err != nil {
+		log.Printf("failed to edit list message %v in %v: %v", messageID, chatID, err)
+	}
+}
+
+func sendFind(chatID int64, args string) {
+	query := parseListCommandArgs(args)
+	if len(util.SearchKey(query.Text)) == 0 {
+		err := SendMessage(chatID, i18n.T(ChatLang(chatID), "find.usage", FindCommand, FindCommand))
+		if err != nil {
+			log.Printf("failed to send /%v help message to %v: %v", FindCommand, chatID, err)
+		}
+		return
+	}
+	sendListPage(chatID, query)
+}
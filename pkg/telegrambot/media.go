@@ -0,0 +1,384 @@
+package telegrambot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/downloader"
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	PlansCommand = "plans"
+
+	PlanFileIDsFile = "data/plan_file_ids.json"
+
+	// MediaGroupLimit Telegram limit of sendMediaGroup
+	MediaGroupLimit = 10
+)
+
+// PlanFileIDs Flat.PlanURL => Telegram file_id of the uploaded plan, file_ids are valid for the same bot only
+var (
+	PlanFileIDs      = make(map[util.EnvType]map[string]string)
+	planFileIDsMutex sync.Mutex
+)
+
+type PlanFileIDsFileMap map[string]map[string]string
+
+func init() {
+	content, err := os.ReadFile(PlanFileIDsFile)
+	if err != nil {
+		log.Printf("unable to read plan file ids file: %v", err)
+		return
+	}
+
+	fileMap := make(PlanFileIDsFileMap)
+	err = json.Unmarshal(content, &fileMap)
+	if err != nil {
+		log.Printf("unable to unmarshal plan file ids file: %v", err)
+		return
+	}
+
+	for envTypeStr, fileIDs := range fileMap {
+		envType, ok := util.EnvTypeFromString[envTypeStr]
+		if !ok {
+			log.Printf("unknown envtype in plan file ids file: %v", envTypeStr)
+			continue
+		}
+		PlanFileIDs[envType] = fileIDs
+	}
+}
+
+func syncPlanFileIDsToFile() error {
+	fileMap := make(PlanFileIDsFileMap, len(PlanFileIDs))
+	for envtype, fileIDs := range PlanFileIDs {
+		fileMap[envtype.String()] = fileIDs
+	}
+	newContent, err := json.Marshal(fileMap)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(PlanFileIDsFile, newContent, 0644)
+}
+
+func getPlanFileID(planURL string) (string, bool) {
+	planFileIDsMutex.Lock()
+	defer planFileIDsMutex.Unlock()
+
+	fileID, ok := PlanFileIDs[util.GetEnvType()][planURL]
+	return fileID, ok
+}
+
+func setPlanFileID(planURL string, fileID string) {
+	planFileIDsMutex.Lock()
+	defer planFileIDsMutex.Unlock()
+
+	envtype := util.GetEnvType()
+	if PlanFileIDs[envtype] == nil {
+		PlanFileIDs[envtype] = make(map[string]string)
+	}
+	PlanFileIDs[envtype][planURL] = fileID
+
+	err := syncPlanFileIDsToFile()
+	if err != nil {
+		log.Printf("failed to sync plan file ids to file: %v", err)
+	}
+}
+
+// PlanMedia a floor plan to send: the cached file_id or the downloaded svg
+type PlanMedia struct {
+	PlanURL string
+	Caption string // HTML
+
+	fileID   string
+	content  []byte
+	fileName string
+}
+
+// NewPlanMedia downloads the plan only if it was never uploaded before
+func NewPlanMedia(planURL string, caption string) (*PlanMedia, error) {
+	media := &PlanMedia{PlanURL: planURL, Caption: caption}
+	if fileID, ok := getPlanFileID(planURL); ok {
+		media.fileID = fileID
+		return media, nil
+	}
+
+	content, err := downloader.GetPlan(planURL)
+	if err != nil {
+		return nil, err
+	}
+	media.content = content
+	media.fileName = path.Base(planURL)
+	return media, nil
+}
+
+// InputMediaDocument see https://core.telegram.org/bots/api#inputmediadocument
+type InputMediaDocument struct {
+	Type      string `json:"type"`
+	Media     string `json:"media"` // file_id or attach://<name>
+	Caption   string `json:"caption,omitempty"`
+	ParseMode string `json:"parse_mode,omitempty"`
+}
+
+type documentMessage struct {
+	MessageId int64 `json:"message_id"`
+	Document  struct {
+		FileId string `json:"file_id"`
+	} `json:"document"`
+}
+
+// SendPlans sends a single document or a media group (at most MediaGroupLimit plans) and caches the file_ids
+func SendPlans(chatID int64, plans []*PlanMedia) error {
+	if len(plans) == 0 {
+		return nil
+	}
+	if len(plans) > MediaGroupLimit {
+		return fmt.Errorf("too many plans for a media group: %v", len(plans))
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	err := writer.WriteField("chat_id", fmt.Sprintf("%v", chatID))
+	if err != nil {
+		return err
+	}
+
+	var media []InputMediaDocument
+	for i, plan := range plans {
+		item := InputMediaDocument{Type: "document", Media: plan.fileID, Caption: plan.Caption, ParseMode: "HTML"}
+		if len(plan.fileID) == 0 {
+			name := fmt.Sprintf("plan%v", i)
+			item.Media = "attach://" + name
+			part, err := writer.CreateFormFile(name, plan.fileName)
+			if err != nil {
+				return err
+			}
+			_, err = part.Write(plan.content)
+			if err != nil {
+				return err
+			}
+		}
+		media = append(media, item)
+	}
+
+	method := "sendMediaGroup"
+	if len(plans) == 1 {
+		method = "sendDocument"
+		fields := map[string]string{"document": media[0].Media, "caption": media[0].Caption, "parse_mode": "HTML"}
+		for key, value := range fields {
+			err = writer.WriteField(key, value)
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		content, err := json.Marshal(media)
+		if err != nil {
+			return err
+		}
+		err = writer.WriteField("media", string(content))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	result, err := postMultipart(method, writer.FormDataContentType(), body)
+	if err != nil {
+		return err
+	}
+
+	var messages []documentMessage
+	if len(plans) == 1 {
+		message := documentMessage{}
+		err = json.Unmarshal(result, &message)
+		messages = append(messages, message)
+	} else {
+		err = json.Unmarshal(result, &messages)
+	}
+	if err != nil {
+		return fmt.Errorf("error while unmarshalling %v result: %v", method, string(result))
+	}
+
+	for i, message := range messages {
+		if i < len(plans) && len(plans[i].fileID) == 0 && len(message.Document.FileId) > 0 {
+			setPlanFileID(plans[i].PlanURL, message.Document.FileId)
+		}
+	}
+	return nil
+}
+
+// SendDocument upload the file content with sendDocument
+func SendDocument(chatID int64, fileName string, content []byte, caption string) error {
+	return sendFile(chatID, "sendDocument", "document", fileName, content, caption)
+}
+
+// SendPhoto upload the image with sendPhoto
+func SendPhoto(chatID int64, fileName string, content []byte, caption string) error {
+	return sendFile(chatID, "sendPhoto", "photo", fileName, content, caption)
+}
+
+func sendFile(chatID int64, method string, field string, fileName string, content []byte, caption string) error {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	fields := map[string]string{"chat_id": fmt.Sprintf("%v", chatID), "caption": caption, "parse_mode": "HTML"}
+	for key, value := range fields {
+		err := writer.WriteField(key, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	part, err := writer.CreateFormFile(field, fileName)
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(content)
+	if err != nil {
+		return err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	_, err = postMultipart(method, writer.FormDataContentType(), body)
+	return err
+}
+
+func postMultipart(method string, contentType string, body io.Reader) (json.RawMessage, error) {
+	methodUrl := fmt.Sprintf("%v/bot%v/%v", botApiUrl, util.GetBotToken(), method)
+
+	resp, err := http.Post(methodUrl, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading Body of %v: %v", method, err)
+	}
+
+	response := &BotMethodResponse{}
+	err = json.Unmarshal(content, response)
+	if err != nil {
+		return nil, fmt.Errorf("error while unmarshalling Body: %v", string(content))
+	}
+	if !response.OK {
+		return nil, fmt.Errorf("%v response is not OK: %v", method, string(content))
+	}
+	return response.Result, nil
+}
+
+// BlockPlans one plan per layout (the cheapest flat of the layout first), at most MediaGroupLimit layouts
+func BlockPlans(lang i18n.Lang, msgData *flatstorage.MessageData) []*PlanMedia {
+	flats := msgData.Copy().Flats
+	sort.SliceStable(flats, func(i, j int) bool {
+		return flats[i].Price < flats[j].Price
+	})
+
+	counts := make(map[string]int)
+	var layouts []flatstorage.Flat
+	for _, flat := range flats {
+		if len(flat.PlanURL) == 0 {
+			continue
+		}
+		if counts[flat.PlanURL] == 0 {
+			layouts = append(layouts, flat)
+		}
+		counts[flat.PlanURL]++
+	}
+
+	var res []*PlanMedia
+	for _, flat := range layouts {
+		if len(res) == MediaGroupLimit {
+			break
+		}
+		caption := flat.Format(lang)
+		if more := counts[flat.PlanURL] - 1; more > 0 {
+			caption += "\n" + i18n.N(lang, "plans.more", more)
+		}
+		media, err := NewPlanMedia(flat.PlanURL, caption)
+		if err != nil {
+			log.Printf("failed to prepare plan of flat %v: %v", flat.ID, err)
+			continue
+		}
+		res = append(res, media)
+	}
+	return res
+}
+
+// sendBlockPlans args: "[code] [filter]", e.g. "2ngt rooms=2"
+func sendBlockPlans(chatID int64, args string) {
+	slug, filterStr, _ := strings.Cut(strings.TrimSpace(args), " ")
+	lang := ChatLang(chatID)
+
+	slug, err := validateSlug(chatID, slug, PlansCommand)
+	if err != nil {
+		log.Printf("failed to send plans to %v: %v", chatID, err)
+		return
+	}
+
+	filter, err := flatstorage.ParseFlatFilter(filterStr)
+	if err != nil {
+		err = SendMessage(chatID, fmt.Sprintf("%v\n\n%v", i18n.ErrorText(lang, err), i18n.T(lang, "plans.usage", PlansCommand, PlansCommand, slug)))
+		if err != nil {
+			log.Printf("failed to send /%v help message to %v: %v", PlansCommand, chatID, err)
+		}
+		return
+	}
+
+	flats, err := ReadRecentFlats(slug)
+	if err != nil {
+		log.Printf("failed to read flats of %v: %v", slug, err)
+		return
+	}
+
+	plans := BlockPlans(lang, flats.Filter(filter))
+	if len(plans) == 0 {
+		err = SendMessage(chatID, i18n.T(lang, "plans.empty", slug))
+		if err != nil {
+			log.Printf("failed to send no plans message to %v: %v", chatID, err)
+		}
+		return
+	}
+
+	err = SendPlans(chatID, plans)
+	if err != nil {
+		log.Printf("failed to send plans of %v to %v: %v", slug, chatID, err)
+	}
+}
+
+// sendFlatPlan the plan of a single flat, e.g. after the flat card
+func sendFlatPlan(chatID int64, flat *flatstorage.Flat) {
+	if flat == nil || len(flat.PlanURL) == 0 {
+		return
+	}
+	media, err := NewPlanMedia(flat.PlanURL, flat.Format(ChatLang(chatID)))
+	if err != nil {
+		log.Printf("failed to prepare plan of flat %v: %v", flat.ID, err)
+		return
+	}
+	err = SendPlans(chatID, []*PlanMedia{media})
+	if err != nil {
+		log.Printf("failed to send plan of flat %v to %v: %v", flat.ID, chatID, err)
+	}
+}
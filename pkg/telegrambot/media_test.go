@@ -0,0 +1,121 @@
+package telegrambot
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockPlans(t *testing.T) {
+	envtype := util.GetEnvType()
+	oldFileIDs := PlanFileIDs[envtype]
+	defer func() { PlanFileIDs[envtype] = oldFileIDs }()
+
+	// cached plans are never downloaded
+	PlanFileIDs[envtype] = map[string]string{"a.svg": "fileA", "b.svg": "fileB"}
+
+	msgData := &flatstorage.MessageData{Flats: []flatstorage.Flat{
+		{ID: 1, Price: 12_000_000, PlanURL: "a.svg"},
+		{ID: 2, Price: 10_000_000, PlanURL: "a.svg"},
+		{ID: 3, Price: 11_000_000, PlanURL: "b.svg"},
+		{ID: 4, Price: 9_000_000},
+	}}
+
+	plans := BlockPlans(i18n.En, msgData)
+	require.Len(t, plans, 2)
+	require.Equal(t, "a.svg", plans[0].PlanURL)
+	require.Equal(t, "fileA", plans[0].fileID)
+	require.Contains(t, plans[0].Caption, "flat/2")
+	require.Contains(t, plans[0].Caption, "+1 more flat with this layout")
+	require.Equal(t, "b.svg", plans[1].PlanURL)
+	require.NotContains(t, plans[1].Caption, "more")
+
+	// the source is not reordered
+	require.Equal(t, int64(1), msgData.Flats[0].ID)
+}
+
+// planUpload what the fake Bot API received
+type planUpload struct {
+	method string
+	fields map[string]string
+	files  map[string]string // form field => file name + content
+}
+
+func TestSendPlans(t *testing.T) {
+	envtype := util.GetEnvType()
+	oldFileIDs, oldUrl := PlanFileIDs[envtype], botApiUrl
+	defer func() { PlanFileIDs[envtype], botApiUrl = oldFileIDs, oldUrl }()
+
+	var uploads []planUpload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseMultipartForm(1 << 20)
+		require.NoError(t, err)
+		upload := planUpload{method: path.Base(r.URL.Path), fields: make(map[string]string), files: make(map[string]string)}
+		for key, values := range r.MultipartForm.Value {
+			upload.fields[key] = values[0]
+		}
+		for key, headers := range r.MultipartForm.File {
+			file, err := headers[0].Open()
+			require.NoError(t, err)
+			content, err := io.ReadAll(file)
+			require.NoError(t, err)
+			upload.files[key] = headers[0].Filename + ":" + string(content)
+		}
+		uploads = append(uploads, upload)
+
+		result := `{"message_id":1,"document":{"file_id":"uploaded0"}}`
+		if upload.method == "sendMediaGroup" {
+			result = `[{"message_id":1,"document":{"file_id":"cached"}},{"message_id":2,"document":{"file_id":"uploaded1"}}]`
+		}
+		_, _ = fmt.Fprintf(w, `{"ok":true,"result":%v}`, result)
+	}))
+	defer server.Close()
+	botApiUrl = server.URL
+
+	tests := []struct {
+		plans    []*PlanMedia
+		expected planUpload
+		fileIDs  map[string]string
+	}{
+		{
+			// a single plan is uploaded with sendDocument
+			plans: []*PlanMedia{{PlanURL: "a.svg", Caption: "<b>a</b>", content: []byte("svg-a"), fileName: "a.svg"}},
+			expected: planUpload{method: "sendDocument",
+				fields: map[string]string{"chat_id": "1", "document": "attach://plan0", "caption": "<b>a</b>", "parse_mode": "HTML"},
+				files:  map[string]string{"plan0": "a.svg:svg-a"}},
+			fileIDs: map[string]string{"a.svg": "uploaded0"},
+		},
+		{
+			// several plans go in one media group, the cached ones are not uploaded again
+			plans: []*PlanMedia{{PlanURL: "c.svg", Caption: "c", fileID: "cached"},
+				{PlanURL: "b.svg", Caption: "b", content: []byte("svg-b"), fileName: "b.svg"}},
+			expected: planUpload{method: "sendMediaGroup",
+				fields: map[string]string{"chat_id": "1", "media": `[{"type":"document","media":"cached","caption":"c","parse_mode":"HTML"},` +
+					`{"type":"document","media":"attach://plan1","caption":"b","parse_mode":"HTML"}]`},
+				files: map[string]string{"plan1": "b.svg:svg-b"}},
+			fileIDs: map[string]string{"a.svg": "uploaded0", "b.svg": "uploaded1"},
+		},
+	}
+
+	PlanFileIDs[envtype] = map[string]string{}
+	for i, test := range tests {
+		uploads = nil
+		err := SendPlans(1, test.plans)
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, []planUpload{test.expected}, uploads, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.fileIDs, PlanFileIDs[envtype], fmt.Sprintf("failed case %v", i))
+	}
+
+	uploads = nil
+	require.NoError(t, SendPlans(1, nil))
+	require.Error(t, SendPlans(1, make([]*PlanMedia, MediaGroupLimit+1)))
+	require.Empty(t, uploads)
+}
@@ -0,0 +1,217 @@
+package telegrambot
+
+import (
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/mortgage"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"log"
+	"strconv"
+	"strings"
+)
+
+const (
+	MortgageCommand = "mortgage"
+
+	MaxMortgageYears = 50
+)
+
+// ParseMortgage example: "family years=20 down=20%", "rate=12.5 diff", "15m" to calculate for the price
+func ParseMortgage(params mortgage.Params, args string) (mortgage.Params, int64, error) {
+	var price int64
+	for _, word := range strings.Fields(strings.ToLower(args)) {
+		key, value, hasValue := strings.Cut(word, "=")
+		if _, ok := mortgage.Presets[word]; ok {
+			params, _ = params.WithPreset(word)
+			continue
+		}
+
+		switch {
+		case word == "annuity":
+			params.Type = mortgage.TypeAnnuity
+		case word == "diff" || word == "differentiated":
+			params.Type = mortgage.TypeDifferentiated
+		case hasValue && key == "rate":
+			rate, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSuffix(value, "%"), ",", "."), 64)
+			if err != nil || rate <= 0 || rate >= 100 {
+				return params, 0, i18n.NewError("mortgage.invalid.rate", value)
+			}
+			// a custom rate is not a preset anymore
+			params.RatePercent, params.MaxLoan, params.Preset = rate, 0, ""
+		case hasValue && (key == "years" || key == "term"):
+			years, err := strconv.Atoi(value)
+			if err != nil || years <= 0 || years > MaxMortgageYears {
+				return params, 0, i18n.NewError("mortgage.invalid.term", value)
+			}
+			params.Years = years
+		case hasValue && (key == "down" || key == "dp" || key == "downpayment"):
+			if percent, ok := strings.CutSuffix(value, "%"); ok {
+				downPercent, err := strconv.ParseFloat(strings.ReplaceAll(percent, ",", "."), 64)
+				if err != nil || downPercent < 0 || downPercent >= 100 {
+					return params, 0, i18n.NewError("downpayment.invalid", value)
+				}
+				params.DownPayment, params.DownPercent = 0, downPercent
+				continue
+			}
+			downPayment, err := flatstorage.ParseFilterNumber(value)
+			if err != nil || downPayment < 0 {
+				return params, 0, i18n.NewError("downpayment.invalid", value)
+			}
+			params.DownPayment, params.DownPercent = int64(downPayment), 0
+		default:
+			number, err := flatstorage.ParseFilterNumber(word)
+			if err != nil || number <= 0 {
+				return params, 0, i18n.NewError("mortgage.unknown.param", word)
+			}
+			price = int64(number)
+		}
+	}
+	return params, price, nil
+}
+
+// MortgageDown example: "3 000 000R" or "20%"
+func MortgageDown(lang i18n.Lang, params mortgage.Params) string {
+	if params.DownPayment > 0 {
+		return i18n.T(lang, "mortgage.down.amount", util.ThousandSep(params.DownPayment, " "))
+	}
+	return i18n.T(lang, "mortgage.down.percent", params.DownPercent)
+}
+
+// MortgageString example: "down payment 20%, 6.0%, 30 years, annuity" and the preset limit
+func MortgageString(lang i18n.Lang, params mortgage.Params) string {
+	res := i18n.T(lang, "mortgage.params", MortgageDown(lang, params), params.Rate(), params.Months()/12,
+		i18n.T(lang, "mortgage."+string(params.PaymentType())))
+	if len(params.Preset) > 0 {
+		res = i18n.T(lang, "mortgage.preset."+params.Preset) + ": " + res
+	}
+	if params.MaxLoan > 0 {
+		res += "\n" + i18n.T(lang, "mortgage.max.loan", util.ThousandSep(params.MaxLoan, " "), mortgage.DefaultRatePercent)
+	}
+	return res
+}
+
+// MortgagePayment short line for the flat lists, example: "🏦 ≈85 000R/mo", empty if no loan is needed
+func MortgagePayment(lang i18n.Lang, params mortgage.Params, price int64) string {
+	first, last := params.Payments(price)
+	if first == 0 {
+		return ""
+	}
+	res := i18n.T(lang, "mortgage.payment", util.ThousandSep(first, " "))
+	if first != last {
+		res = i18n.T(lang, "mortgage.payment.diff", util.ThousandSep(first, " "), util.ThousandSep(last, " "))
+	}
+	return res + MortgageMarketRate(lang, params, price)
+}
+
+// MortgageMarketRate the mark of the payments at the market rate because the preset does not apply, empty otherwise
+func MortgageMarketRate(lang i18n.Lang, params mortgage.Params, price int64) string {
+	if params.PresetApplies(price) {
+		return ""
+	}
+	return " " + i18n.T(lang, "mortgage.market.rate")
+}
+
+// MortgageCalculation the loan, the payments and the overpayment for the price
+func MortgageCalculation(lang i18n.Lang, params mortgage.Params, price int64) string {
+	res := []string{
+		i18n.T(lang, "mortgage.price", util.ThousandSep(price, " ")),
+		MortgageString(lang, params),
+	}
+	principal := params.Principal(price)
+	if principal == 0 {
+		return strings.Join(append(res, i18n.T(lang, "mortgage.no.loan")), "\n")
+	}
+
+	res = append(res, i18n.T(lang, "mortgage.loan", util.ThousandSep(price-principal, " "), util.ThousandSep(principal, " ")))
+	if !params.PresetApplies(price) {
+		res = append(res, i18n.T(lang, "mortgage.preset.not.applies", i18n.T(lang, "mortgage.preset."+params.Preset),
+			params.MinDownPercent(), mortgage.DefaultRatePercent))
+	}
+	first, last := params.Payments(price)
+	if first != last {
+		res = append(res, i18n.T(lang, "mortgage.monthly.diff", util.ThousandSep(first, " "), util.ThousandSep(last, " ")))
+	} else {
+		res = append(res, i18n.T(lang, "mortgage.monthly", util.ThousandSep(first, " ")))
+	}
+	res = append(res, i18n.T(lang, "mortgage.overpayment", util.ThousandSep(params.Overpayment(price), " ")))
+	return strings.Join(res, "\n")
+}
+
+// ChatMortgagePayment the payment line for the flat lists of the chat, nil if the chat did not set up a mortgage
+func ChatMortgagePayment(settings ChatSettings) func(flat *flatstorage.Flat) string {
+	if settings.Mortgage == nil {
+		return nil
+	}
+	lang, params := settings.Lang(), *settings.Mortgage
+	return func(flat *flatstorage.Flat) string {
+		return MortgagePayment(lang, params, flat.Price)
+	}
+}
+
+// sendMortgage example args: "family years=20 down=20%", "15m", "off"
+func sendMortgage(chatID int64, args string) {
+	settings := GetChatSettings(chatID)
+	lang := settings.Lang()
+	usage := i18n.T(lang, "mortgage.usage", MortgageCommand, mortgage.DefaultRatePercent, MortgageCommand, MortgageCommand, MortgageCommand)
+
+	args = strings.TrimSpace(args)
+	if len(args) == 0 {
+		current := i18n.T(lang, "mortgage.off", MortgageCommand)
+		if settings.Mortgage != nil {
+			current = i18n.T(lang, "mortgage.current", MortgageString(lang, *settings.Mortgage))
+		}
+		err := SendMessage(chatID, current+"\n\n"+usage)
+		if err != nil {
+			log.Printf("failed to send /%v help message to %v: %v", MortgageCommand, chatID, err)
+		}
+		return
+	}
+
+	if strings.ToLower(args) == "off" {
+		settings.Mortgage = nil
+		err := SetChatSettings(settings)
+		if err != nil {
+			log.Printf("failed to save mortgage settings of %v: %v", chatID, err)
+			return
+		}
+		err = SendMessage(chatID, i18n.T(lang, "mortgage.off", MortgageCommand))
+		if err != nil {
+			log.Printf("failed to send mortgage off message to %v: %v", chatID, err)
+		}
+		return
+	}
+
+	var oldParams mortgage.Params
+	if settings.Mortgage != nil {
+		oldParams = *settings.Mortgage
+	}
+	params, price, err := ParseMortgage(oldParams, args)
+	if err != nil {
+		err = SendMessage(chatID, fmt.Sprintf("%v\n\n%v", i18n.ErrorText(lang, err), usage))
+		if err != nil {
+			log.Printf("failed to send /%v help message to %v: %v", MortgageCommand, chatID, err)
+		}
+		return
+	}
+
+	var res []string
+	// only the price, e.g. "/mortgage 15m", calculates without changing the settings
+	if params != oldParams || (settings.Mortgage == nil && price == 0) {
+		settings.Mortgage = &params
+		err = SetChatSettings(settings)
+		if err != nil {
+			log.Printf("failed to save mortgage settings of %v: %v", chatID, err)
+			return
+		}
+		res = append(res, i18n.T(lang, "mortgage.changed", MortgageString(lang, params), FlatCommand, DumpCommand))
+	}
+	if price > 0 {
+		res = append(res, MortgageCalculation(lang, params, price))
+	}
+
+	err = SendMessage(chatID, strings.Join(res, "\n\n"))
+	if err != nil {
+		log.Printf("failed to send mortgage message to %v: %v", chatID, err)
+	}
+}
@@ -0,0 +1,72 @@
+package telegrambot
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/mortgage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMortgage(t *testing.T) {
+	tests := []struct {
+		params   mortgage.Params
+		args     string
+		expected mortgage.Params
+		price    int64
+		isErr    bool
+	}{
+		{mortgage.Params{}, "rate=12,5% years=20 down=3m", mortgage.Params{RatePercent: 12.5, Years: 20, DownPayment: 3_000_000}, 0, false},
+		{mortgage.Params{DownPayment: 3_000_000}, "family", mortgage.Params{RatePercent: 6, DownPayment: 3_000_000, MaxLoan: 12_000_000, Preset: "family"}, 0, false},
+		{mortgage.Params{DownPayment: 3_000_000}, "IT down=30% diff 15m", mortgage.Params{Type: mortgage.TypeDifferentiated, RatePercent: 6, DownPercent: 30, MaxLoan: 18_000_000, Preset: "it"}, 15_000_000, false},
+		{mortgage.Params{RatePercent: 6, MaxLoan: 12_000_000, Preset: "family"}, "rate=10", mortgage.Params{RatePercent: 10}, 0, false},
+		{mortgage.Params{Years: 20}, "14.5m", mortgage.Params{Years: 20}, 14_500_000, false},
+		{mortgage.Params{}, "rate=0", mortgage.Params{}, 0, true},
+		{mortgage.Params{}, "years=100", mortgage.Params{}, 0, true},
+		{mortgage.Params{}, "down=120%", mortgage.Params{}, 0, true},
+		{mortgage.Params{}, "rural", mortgage.Params{}, 0, true},
+	}
+
+	for i, test := range tests {
+		params, price, err := ParseMortgage(test.params, test.args)
+		if test.isErr {
+			require.Error(t, err, fmt.Sprintf("failed case %v", i))
+			continue
+		}
+		require.NoError(t, err, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.expected, params, fmt.Sprintf("failed case %v", i))
+		require.Equal(t, test.price, price, fmt.Sprintf("failed case %v", i))
+	}
+}
+
+func TestMortgageMessages(t *testing.T) {
+	params := mortgage.Params{RatePercent: 12, Years: 20, DownPayment: 4_000_000}
+	require.Equal(t, "🏦 ≈110 109R/mo", MortgagePayment(i18n.En, params, 14_000_000))
+	require.Equal(t, "", MortgagePayment(i18n.En, params, 3_000_000))
+
+	params.Type = mortgage.TypeDifferentiated
+	require.Equal(t, "🏦 141 667→42 083R/mo", MortgagePayment(i18n.En, params, 14_000_000))
+
+	family, err := mortgage.Params{Years: 20}.WithPreset("family")
+	require.NoError(t, err)
+	msg := MortgageCalculation(i18n.En, family, 20_000_000)
+	require.Contains(t, msg, "Family mortgage: down payment 20%, 6.0%, 20 years, annuity")
+	require.Contains(t, msg, "the rate applies up to 12 000 000R, the rest at 18.0%")
+	require.Contains(t, msg, "Down payment: 4 000 000R, loan: 16 000 000R")
+	require.NotContains(t, msg, "⚠️")
+
+	// an absolute down payment below the preset minimum for the price
+	family.DownPayment = 2_000_000
+	msg = MortgageCalculation(i18n.En, family, 20_000_000)
+	require.Contains(t, msg, "⚠️ Family mortgage needs at least 20% down payment, the whole loan is at the market rate 18.0%")
+	require.Contains(t, MortgagePayment(i18n.En, family, 20_000_000), "(market rate)")
+	require.NotContains(t, MortgagePayment(i18n.En, family, 10_000_000), "(market rate)")
+
+	settings := ChatSettings{Language: i18n.En}
+	require.Nil(t, ChatMortgagePayment(settings))
+	settings.Mortgage = &mortgage.Params{RatePercent: 12, Years: 20, DownPayment: 4_000_000}
+	msgData := &flatstorage.MessageData{Flats: []flatstorage.Flat{{ID: 1, BulkName: "Корпус 1", Price: 14_000_000}}}
+	require.Contains(t, msgData.FormatDisplay(i18n.En, flatstorage.Display{}, ChatMortgagePayment(settings)), " 🏦 ≈110 109R/mo")
+}
@@ -0,0 +1,87 @@
+package telegrambot
+
+import (
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"log"
+	"strings"
+	"time"
+)
+
+const ReportCommand = "report"
+
+// MarketReport sell-through report of the block from the local file
+func MarketReport(lang i18n.Lang, slug string, now time.Time) (string, error) {
+	msgData, err := ReadStoredFlats(slug)
+	if err != nil {
+		return "", err
+	}
+	if len(msgData.Flats) == 0 {
+		return i18n.T(lang, "dump.empty", slug), nil
+	}
+	return msgData.MarketReport(now).Format(lang), nil
+}
+
+// SendMarketReports a report for each block the chat is subscribed to
+func SendMarketReports(chatID int64, now time.Time, options MessageOptions) {
+	lang := ChatLang(chatID)
+	for _, slug := range util.SortedKeys(GetChatSubscriptions(chatID)) {
+		msg, err := MarketReport(lang, slug, now)
+		if err != nil {
+			log.Printf("failed to make market report of %v for %v: %v", slug, chatID, err)
+			continue
+		}
+		options.ReplyMarkup = BlockKeyboard(chatID, slug)
+		err = SendMessageWithOptions(chatID, msg, options)
+		if err != nil {
+			log.Printf("failed to send market report of %v to %v: %v", slug, chatID, err)
+		}
+	}
+}
+
+// sendReport example args: "2ngt" for the report right away, "weekly" or "off" for the weekly reports
+func sendReport(chatID int64, args string) {
+	arg, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(args)), " ")
+	settings := GetChatSettings(chatID)
+	lang := settings.Lang()
+
+	switch arg {
+	case "weekly", "on", "off":
+		settings.MarketReport = arg != "off"
+		if settings.MarketReport && len(settings.LastMarketReport) == 0 {
+			// the first report on the next Monday
+			settings.LastMarketReport = time.Now().Format(time.RFC3339)
+		}
+		err := SetChatSettings(settings)
+		if err != nil {
+			log.Printf("failed to save market report setting of %v: %v", chatID, err)
+			return
+		}
+		msg := i18n.T(lang, "report.weekly.off")
+		if settings.MarketReport {
+			msg = i18n.T(lang, "report.weekly.on", MarketReportTime)
+		}
+		err = SendMessage(chatID, msg)
+		if err != nil {
+			log.Printf("failed to send market report setting to %v: %v", chatID, err)
+		}
+		return
+	}
+
+	slug, err := validateSlug(chatID, arg, ReportCommand)
+	if err != nil {
+		log.Printf("failed to send market report to %v: %v", chatID, err)
+		return
+	}
+
+	RefreshStaleFlats(slug)
+	msg, err := MarketReport(lang, slug, time.Now())
+	if err != nil {
+		log.Printf("failed to make market report of %v for %v: %v", slug, chatID, err)
+		return
+	}
+	err = SendMessageWithOptions(chatID, msg, MessageOptions{ReplyMarkup: BlockKeyboard(chatID, slug)})
+	if err != nil {
+		log.Printf("failed to send market report of %v to %v: %v", slug, chatID, err)
+	}
+}
@@ -0,0 +1,191 @@
+package telegrambot
+
+import (
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SearchCommand = "search"
+
+	SearchPageSize = 20
+	SearchIndexTTL = 10 * time.Minute
+
+	searchSortPrefix = "sort="
+)
+
+var (
+	searchIndex      *flatstorage.MessageData
+	searchIndexBuilt time.Time
+	searchIndexMutex sync.Mutex
+)
+
+// SearchIndex recent flats of all known blocks with scored deals, rebuilt every SearchIndexTTL
+func SearchIndex(now time.Time) *flatstorage.MessageData {
+	searchIndexMutex.Lock()
+	defer searchIndexMutex.Unlock()
+
+	if searchIndex != nil && now.Sub(searchIndexBuilt) < SearchIndexTTL {
+		return searchIndex
+	}
+
+	index := &flatstorage.MessageData{}
+	for _, slug := range util.SortedKeys(BlockSlugs) {
+		block, err := ReadRecentFlats(slug)
+		if err != nil {
+			continue
+		}
+		block.ScoreDeals(block)
+		index.Flats = append(index.Flats, block.Flats...)
+	}
+	searchIndex, searchIndexBuilt = index, now
+	return index
+}
+
+// SearchQuery example: "rooms=2 price<15m metro=Нагатинская sort=-area"
+type SearchQuery struct {
+	Filter flatstorage.FlatFilter
+	Sort   flatstorage.FlatSort
+	Page   int // zero-based
+}
+
+func ParseSearchQuery(s string) (SearchQuery, error) {
+	var conditions, sortKey []string
+	for _, word := range strings.Fields(s) {
+		if strings.HasPrefix(strings.ToLower(word), searchSortPrefix) {
+			sortKey = append(sortKey, word[len(searchSortPrefix):])
+			continue
+		}
+		conditions = append(conditions, word)
+	}
+	if len(sortKey) > 1 {
+		return SearchQuery{}, i18n.NewError("search.one.sort")
+	}
+
+	var res SearchQuery
+	var err error
+	res.Filter, err = flatstorage.ParseFlatFilter(strings.Join(conditions, " "))
+	if err != nil {
+		return SearchQuery{}, err
+	}
+	res.Sort, err = flatstorage.ParseFlatSort(strings.Join(sortKey, ""))
+	if err != nil {
+		return SearchQuery{}, err
+	}
+	return res, nil
+}
+
+// PageArgs the callback arguments of the page of the query: the page and the query itself,
+// every message pages its own search (long queries are aliased by callbackButton)
+func (q SearchQuery) PageArgs(page int) []string {
+	return []string{strconv.Itoa(page), q.String()}
+}
+
+func ParseSearchPageArgs(args []string) (SearchQuery, error) {
+	if len(args) != 2 {
+		return SearchQuery{}, fmt.Errorf("expected page and query, got %v", args)
+	}
+	query, err := ParseSearchQuery(args[1])
+	if err != nil {
+		return SearchQuery{}, err
+	}
+	query.Page, err = strconv.Atoi(args[0])
+	if err != nil {
+		return SearchQuery{}, err
+	}
+	return query, nil
+}
+
+func (q SearchQuery) String() string {
+	res := q.Filter.String()
+	if len(res) > 0 {
+		res += " "
+	}
+	return res + searchSortPrefix + q.Sort.String()
+}
+
+// Search matching flats of all blocks in the query order, hidden flats of the chat are skipped
+func Search(chatID int64, query SearchQuery, now time.Time) *flatstorage.MessageData {
+	res := SearchIndex(now).Filter(query.Filter).Reject(GetChatHidden(chatID).Hides)
+	res.Sort(query.Sort)
+	return res
+}
+
+// renderSearch the page of the query, the sort key of the query overrides the one of the chat display
+func renderSearch(chatID int64, query SearchQuery) (string, *InlineKeyboardMarkup) {
+	settings := GetChatSettings(chatID)
+	lang, display := settings.Lang(), settings.FlatDisplay()
+	found := Search(chatID, query, time.Now())
+	if len(found.Flats) == 0 {
+		return i18n.T(lang, "search.empty", html.EscapeString(query.String())), nil
+	}
+
+	pages := (len(found.Flats) + SearchPageSize - 1) / SearchPageSize
+	query.Page = util.Min(util.Max(query.Page, 0), pages-1)
+	from := query.Page * SearchPageSize
+	to := util.Min(from+SearchPageSize, len(found.Flats))
+
+	res := []string{i18n.T(lang, "search.header", html.EscapeString(query.String()), len(found.Flats), query.Page+1, pages)}
+	for i, flat := range found.Flats[from:to] {
+		res = append(res, fmt.Sprintf("%v. %v, %v", from+i+1, flat.BlockName, flat.FormatDisplay(lang, display)))
+	}
+
+	markup := &InlineKeyboardMarkup{}
+	var nav []InlineKeyboardButton
+	if query.Page > 0 {
+		nav = appendButton(nav, i18n.T(lang, "button.prev"), CallbackSearchPage, query.PageArgs(query.Page-1)...)
+	}
+	if query.Page < pages-1 {
+		nav = appendButton(nav, i18n.T(lang, "button.next"), CallbackSearchPage, query.PageArgs(query.Page+1)...)
+	}
+	markup.addRow(nav)
+
+	return strings.Join(res, "\n"), markup
+}
+
+// sendSearch example args: "rooms=2 price<15m metro=Нагатинская sort=meterprice"
+func sendSearch(chatID int64, args string) {
+	lang := ChatLang(chatID)
+
+	query, err := ParseSearchQuery(args)
+	if err != nil || len(strings.TrimSpace(args)) == 0 {
+		msg := i18n.T(lang, "search.usage", SearchCommand, SearchCommand)
+		if err != nil {
+			msg = fmt.Sprintf("%v\n\n%v", i18n.ErrorText(lang, err), msg)
+		}
+		err = SendMessage(chatID, msg)
+		if err != nil {
+			log.Printf("failed to send /%v help message to %v: %v", SearchCommand, chatID, err)
+		}
+		return
+	}
+
+	msg, keyboard := renderSearch(chatID, query)
+	err = SendMessageWithOptions(chatID, msg, MessageOptions{ReplyMarkup: keyboard})
+	if err != nil {
+		log.Printf("failed to send search results to %v: %v", chatID, err)
+	}
+}
+
+// editSearchPage show another page of the search in the same message, returns the callback answer
+func editSearchPage(chatID int64, messageID int64, args []string) string {
+	query, err := ParseSearchPageArgs(args)
+	if err != nil {
+		return i18n.T(ChatLang(chatID), "search.expired", SearchCommand)
+	}
+
+	msg, keyboard := renderSearch(chatID, query)
+	err = EditMessageText(chatID, messageID, msg, keyboard)
+	if err != nil {
+		log.Printf("failed to edit search message %v in %v: %v", messageID, chatID, err)
+	}
+	return ""
+}
@@ -28,22 +28,37 @@ func SendMessage(chatID int64, text string) error {
 }
 
 func SendMessageWithPin(chatID int64, text string, mustPin bool) error {
+	return SendMessageWithOptions(chatID, text, MessageOptions{Pin: mustPin})
+}
+
+type MessageOptions struct {
+	Pin    bool // pin the first chunk if the message is split
+	Silent bool // deliver without notification sound
+
+	ReplyMarkup *InlineKeyboardMarkup // attached to the last chunk
+}
+
+func SendMessageWithOptions(chatID int64, text string, options MessageOptions) error {
 	token := util.GetBotToken()
 
 	chunks := SplitTextIntoSendableChunks(text)
 
 	var messageIDToDefer int64
 	for i, msg := range chunks {
-		messageID, err := SendMessageWithToken(token, chatID, msg)
+		chunkOptions := options
+		if i != len(chunks)-1 {
+			chunkOptions.ReplyMarkup = nil
+		}
+		messageID, err := SendMessageWithToken(token, chatID, msg, chunkOptions)
 		if err != nil {
 			return err
 		}
-		if len(chunks) > 1 && i == 0 && mustPin {
+		if len(chunks) > 1 && i == 0 && options.Pin {
 			messageIDToDefer = messageID
 		}
 	}
 
-	if mustPin && messageIDToDefer != 0 {
+	if options.Pin && messageIDToDefer != 0 {
 		err := PinMessage(token, chatID, messageIDToDefer)
 		if err != nil {
 			return err
@@ -61,7 +76,7 @@ type PinResponse struct {
 }
 
 func PinMessage(token string, chatID int64, messageID int64) error {
-	pinMessageUrl := fmt.Sprintf("https://api.telegram.org/bot%v/pinChatMessage", token)
+	pinMessageUrl := fmt.Sprintf("%v/bot%v/pinChatMessage", botApiUrl, token)
 
 	values := url.Values{
 		"chat_id":    []string{fmt.Sprintf("%v", chatID)},
@@ -107,9 +122,9 @@ type SendResponse struct {
 	} `json:"result"`
 }
 
-func SendMessageWithToken(token string, chatID int64, text string) (int64, error) {
+func SendMessageWithToken(token string, chatID int64, text string, options MessageOptions) (int64, error) {
 
-	sendMessageUrl := fmt.Sprintf("https://api.telegram.org/bot%v/sendMessage", token)
+	sendMessageUrl := fmt.Sprintf("%v/bot%v/sendMessage", botApiUrl, token)
 
 	values := url.Values{
 		"chat_id":                  []string{fmt.Sprintf("%v", chatID)},
@@ -117,6 +132,16 @@ func SendMessageWithToken(token string, chatID int64, text string) (int64, error
 		"parse_mode":               []string{"HTML"},
 		"disable_web_page_preview": []string{"True"},
 	}
+	if options.Silent {
+		values.Set("disable_notification", "True")
+	}
+	if options.ReplyMarkup != nil {
+		markup, err := json.Marshal(options.ReplyMarkup)
+		if err != nil {
+			return 0, err
+		}
+		values.Set("reply_markup", string(markup))
+	}
 	// post http request
 	resp, err := http.PostForm(sendMessageUrl, values)
 	if err != nil {
@@ -0,0 +1,378 @@
+package telegrambot
+
+import (
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	DeliveryCommand = "delivery"
+	TimezoneCommand = "timezone"
+	QuietCommand    = "quiet"
+	LangCommand     = "lang"
+	DealsCommand    = "deals"
+	DisplayCommand  = "display"
+
+	defaultDigestTime = "09:00"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseWeekday example: "Monday", "mon" => time.Monday
+func parseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) < 3 {
+		return 0, false
+	}
+	weekday, ok := weekdays[s[:3]]
+	return weekday, ok
+}
+
+// parseDelivery example: "weekly mon 09:00", "daily 21:30", "hourly", "instant"
+func parseDelivery(settings ChatSettings, args string) (ChatSettings, error) {
+	fields := strings.Fields(strings.ToLower(args))
+	if len(fields) == 0 {
+		return settings, i18n.NewError("delivery.empty")
+	}
+
+	settings.DeliveryMode = DeliveryMode(fields[0])
+	settings.DigestTime = defaultDigestTime
+	settings.DigestWeekday = time.Monday
+
+	rest := fields[1:]
+	switch settings.DeliveryMode {
+	case DeliveryInstant, DeliveryHourly:
+		settings.DigestTime = ""
+		settings.DigestWeekday = 0
+		return settings, nil
+	case DeliveryWeekly:
+		settings.DigestWeekday = time.Monday
+		if len(rest) > 0 {
+			weekday, ok := parseWeekday(rest[0])
+			if !ok {
+				return settings, i18n.NewError("delivery.unknown.weekday", rest[0])
+			}
+			settings.DigestWeekday = weekday
+			rest = rest[1:]
+		}
+	case DeliveryDaily:
+		settings.DigestWeekday = 0
+	default:
+		return settings, i18n.NewError("delivery.unknown", fields[0])
+	}
+
+	if len(rest) > 0 {
+		hour, minute, err := ParseClock(rest[0])
+		if err != nil {
+			return settings, err
+		}
+		settings.DigestTime = fmt.Sprintf("%02d:%02d", hour, minute)
+	}
+
+	return settings, nil
+}
+
+func setDelivery(chatID int64, args string) {
+	oldSettings := GetChatSettings(chatID)
+	lang := oldSettings.Lang()
+
+	if len(strings.TrimSpace(args)) == 0 {
+		err := SendMessage(chatID, i18n.T(lang, "delivery.help", oldSettings.DeliveryString(lang),
+			i18n.T(lang, "delivery.usage", DeliveryCommand), DeliveryCommand, TimezoneCommand, TimezoneCommand, DefaultTimezone))
+		if err != nil {
+			log.Printf("failed to send /%v help message to %v: %v", DeliveryCommand, chatID, err)
+		}
+		return
+	}
+
+	settings, err := parseDelivery(oldSettings, args)
+	if err != nil {
+		err = SendMessage(chatID, fmt.Sprintf("%v\n\n%v", i18n.ErrorText(lang, err), i18n.T(lang, "delivery.usage", DeliveryCommand)))
+		if err != nil {
+			log.Printf("failed to send /%v help message to %v: %v", DeliveryCommand, chatID, err)
+		}
+		return
+	}
+	// the first digest comes at the next scheduled time
+	settings.LastDigest = time.Now().Format(time.RFC3339)
+
+	err = SetChatSettings(settings)
+	if err != nil {
+		log.Printf("failed to save delivery settings of %v: %v", chatID, err)
+		err = SendMessage(chatID, i18n.T(lang, "delivery.failed", err))
+		if err != nil {
+			log.Printf("failed to send delivery failed message to %v: %v", chatID, err)
+		}
+		return
+	}
+
+	msg := i18n.T(lang, "delivery.changed", settings.DeliveryString(lang))
+	if settings.Mode() != DeliveryInstant {
+		msg += "\n" + i18n.T(lang, "delivery.next", settings.NextDigestAfter(time.Now()).Format("02.01 15:04 MST"))
+	}
+	err = SendMessage(chatID, msg)
+	if err != nil {
+		log.Printf("failed to send delivery changed message to %v: %v", chatID, err)
+	}
+
+	if settings.Mode() == DeliveryInstant && oldSettings.Mode() != DeliveryInstant {
+		// deliver whatever was accumulated
+		err = SendDigest(chatID, MessageOptions{})
+		if err != nil {
+			log.Printf("failed to flush digest queue of %v: %v", chatID, err)
+		}
+	}
+}
+
+func setTimezone(chatID int64, args string) {
+	settings := GetChatSettings(chatID)
+	lang := settings.Lang()
+
+	name, err := ParseTimezone(args)
+	if err != nil {
+		err = SendMessage(chatID, i18n.T(lang, "timezone.help", settings.Location(), TimezoneCommand, TimezoneCommand, DefaultTimezone))
+		if err != nil {
+			log.Printf("failed to send /%v help message to %v: %v", TimezoneCommand, chatID, err)
+		}
+		return
+	}
+
+	settings.Timezone = name
+	err = SetChatSettings(settings)
+	if err != nil {
+		log.Printf("failed to save timezone of %v: %v", chatID, err)
+		return
+	}
+
+	err = SendMessage(chatID, i18n.T(lang, "timezone.changed", name, settings.DeliveryString(lang)))
+	if err != nil {
+		log.Printf("failed to send timezone changed message to %v: %v", chatID, err)
+	}
+}
+
+// ParseTimezone example: "Europe/Moscow", "America/New-York" with the underscores un-embedded into dashes
+func ParseTimezone(s string) (string, error) {
+	name := strings.TrimSpace(s)
+	if len(name) == 0 {
+		return "", i18n.NewError("timezone.empty")
+	}
+	_, err := time.LoadLocation(name)
+	if err != nil {
+		// underscores were un-embedded into dashes, e.g. America/New_York
+		name = strings.ReplaceAll(name, "-", "_")
+		_, err = time.LoadLocation(name)
+	}
+	if err != nil {
+		return "", i18n.NewError("timezone.unknown", s)
+	}
+	return name, nil
+}
+
+// parseQuiet example: "23:00-08:00 silent Europe/Moscow", "off"
+func parseQuiet(settings ChatSettings, args string) (ChatSettings, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return settings, i18n.NewError("quiet.empty")
+	}
+
+	if strings.ToLower(fields[0]) == "off" {
+		settings.QuietFrom, settings.QuietTo, settings.QuietMode = "", "", ""
+		return settings, nil
+	}
+
+	from, to, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return settings, i18n.NewError("quiet.invalid", fields[0])
+	}
+	fromHour, fromMinute, err := ParseClock(from)
+	if err != nil {
+		return settings, err
+	}
+	toHour, toMinute, err := ParseClock(to)
+	if err != nil {
+		return settings, err
+	}
+	settings.QuietFrom = fmt.Sprintf("%02d:%02d", fromHour, fromMinute)
+	settings.QuietTo = fmt.Sprintf("%02d:%02d", toHour, toMinute)
+	settings.QuietMode = QuietHold
+
+	for _, field := range fields[1:] {
+		switch mode := QuietMode(strings.ToLower(field)); mode {
+		case QuietHold, QuietSilent:
+			settings.QuietMode = mode
+		default:
+			timezone, err := ParseTimezone(field)
+			if err != nil {
+				return settings, i18n.NewError("quiet.unknown", field)
+			}
+			settings.Timezone = timezone
+		}
+	}
+
+	return settings, nil
+}
+
+func setQuiet(chatID int64, args string) {
+	oldSettings := GetChatSettings(chatID)
+	lang := oldSettings.Lang()
+
+	usage := i18n.T(lang, "quiet.usage", QuietCommand, QuietCommand, QuietCommand, DefaultTimezone)
+
+	if len(strings.TrimSpace(args)) == 0 {
+		err := SendMessage(chatID, i18n.T(lang, "quiet.changed", oldSettings.QuietString(lang))+"\n\n"+usage)
+		if err != nil {
+			log.Printf("failed to send /%v help message to %v: %v", QuietCommand, chatID, err)
+		}
+		return
+	}
+
+	settings, err := parseQuiet(oldSettings, strings.ReplaceAll(args, "—", "-"))
+	if err != nil {
+		err = SendMessage(chatID, fmt.Sprintf("%v\n\n%v", i18n.ErrorText(lang, err), usage))
+		if err != nil {
+			log.Printf("failed to send /%v help message to %v: %v", QuietCommand, chatID, err)
+		}
+		return
+	}
+
+	err = SetChatSettings(settings)
+	if err != nil {
+		log.Printf("failed to save quiet hours of %v: %v", chatID, err)
+		err = SendMessage(chatID, i18n.T(lang, "quiet.failed", err))
+		if err != nil {
+			log.Printf("failed to send quiet hours failed message to %v: %v", chatID, err)
+		}
+		return
+	}
+
+	err = SendMessage(chatID, i18n.T(lang, "quiet.changed", settings.QuietString(lang)))
+	if err != nil {
+		log.Printf("failed to send quiet hours changed message to %v: %v", chatID, err)
+	}
+}
+
+// setLang example: "/lang ru", "/lang en", "/lang auto"
+func setLang(chatID int64, args string) {
+	settings := GetChatSettings(chatID)
+
+	name := strings.ToLower(strings.TrimSpace(args))
+	lang, ok := i18n.Parse(name)
+	switch {
+	case name == "auto":
+		settings.Language = ""
+	case ok:
+		settings.Language = lang
+	default:
+		lang = settings.Lang()
+		err := SendMessage(chatID, i18n.T(lang, "lang.help", lang, LangCommand, LangCommand))
+		if err != nil {
+			log.Printf("failed to send /%v help message to %v: %v", LangCommand, chatID, err)
+		}
+		return
+	}
+
+	err := SetChatSettings(settings)
+	if err != nil {
+		log.Printf("failed to save language of %v: %v", chatID, err)
+		return
+	}
+
+	err = SendMessage(chatID, i18n.T(settings.Lang(), "lang.changed", settings.Lang()))
+	if err != nil {
+		log.Printf("failed to send language changed message to %v: %v", chatID, err)
+	}
+}
+
+// setDeals example: "/deals on", "/deals off"
+func setDeals(chatID int64, args string) {
+	settings := GetChatSettings(chatID)
+	lang := settings.Lang()
+
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "on":
+		settings.DealsOnly = true
+	case "off":
+		settings.DealsOnly = false
+	default:
+		err := SendMessage(chatID, i18n.T(lang, "deals.help", dealsModeString(lang, settings.DealsOnly),
+			flatstorage.DealThreshold*100, DealsCommand, DealsCommand))
+		if err != nil {
+			log.Printf("failed to send /%v help message to %v: %v", DealsCommand, chatID, err)
+		}
+		return
+	}
+
+	err := SetChatSettings(settings)
+	if err != nil {
+		log.Printf("failed to save deals mode of %v: %v", chatID, err)
+		return
+	}
+
+	err = SendMessage(chatID, i18n.T(lang, "deals.changed", dealsModeString(lang, settings.DealsOnly)))
+	if err != nil {
+		log.Printf("failed to send deals mode changed message to %v: %v", chatID, err)
+	}
+}
+
+func dealsModeString(lang i18n.Lang, dealsOnly bool) string {
+	if dealsOnly {
+		return i18n.T(lang, "deals.on")
+	}
+	return i18n.T(lang, "deals.off")
+}
+
+// setDisplay example: "/display sort=-area columns=rooms,area,price millions=on", "/display reset"
+func setDisplay(chatID int64, args string) {
+	settings := GetChatSettings(chatID)
+	lang := settings.Lang()
+	usage := i18n.T(lang, "display.usage", DisplayCommand, DisplayCommand, strings.Join(flatstorage.DisplayColumns, ","), DisplayCommand)
+
+	args = strings.TrimSpace(args)
+	if len(args) == 0 {
+		err := SendMessage(chatID, i18n.T(lang, "display.changed", settings.FlatDisplay())+"\n\n"+usage)
+		if err != nil {
+			log.Printf("failed to send /%v help message to %v: %v", DisplayCommand, chatID, err)
+		}
+		return
+	}
+
+	display := flatstorage.Display{}
+	if strings.ToLower(args) != "reset" {
+		var err error
+		display, err = flatstorage.ParseDisplay(settings.FlatDisplay(), args)
+		if err != nil {
+			err = SendMessage(chatID, fmt.Sprintf("%v\n\n%v", i18n.ErrorText(lang, err), usage))
+			if err != nil {
+				log.Printf("failed to send /%v help message to %v: %v", DisplayCommand, chatID, err)
+			}
+			return
+		}
+	}
+
+	settings.Display = &display
+	if display.String() == (flatstorage.Display{}).String() {
+		settings.Display = nil
+	}
+	err := SetChatSettings(settings)
+	if err != nil {
+		log.Printf("failed to save display settings of %v: %v", chatID, err)
+		return
+	}
+
+	err = SendMessage(chatID, i18n.T(lang, "display.changed", display))
+	if err != nil {
+		log.Printf("failed to send display changed message to %v: %v", chatID, err)
+	}
+}
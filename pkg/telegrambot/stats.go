@@ -0,0 +1,39 @@
+package telegrambot
+
+import (
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"log"
+	"strings"
+	"time"
+)
+
+const StatsCommand = "stats"
+
+// sendStats example args: "2ngt"
+func sendStats(chatID int64, args string) {
+	slug, _, _ := strings.Cut(strings.TrimSpace(args), " ")
+	lang := ChatLang(chatID)
+
+	slug, err := validateSlug(chatID, slug, StatsCommand)
+	if err != nil {
+		log.Printf("failed to send stats to %v: %v", chatID, err)
+		return
+	}
+
+	RefreshStaleFlats(slug)
+	msgData, err := ReadStoredFlats(slug)
+	if err != nil {
+		log.Printf("failed to read flats of %v: %v", slug, err)
+		return
+	}
+
+	msg := i18n.T(lang, "dump.empty", slug)
+	if len(msgData.Flats) > 0 {
+		msg = msgData.Stats(time.Now()).Format(lang)
+	}
+
+	err = SendMessageWithOptions(chatID, msg, MessageOptions{ReplyMarkup: BlockKeyboard(chatID, slug)})
+	if err != nil {
+		log.Printf("failed to send stats of %v to %v: %v", slug, chatID, err)
+	}
+}
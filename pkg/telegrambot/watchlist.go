@@ -0,0 +1,354 @@
+package telegrambot
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/georgri/sledopyt_addresses/pkg/util"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	WatchlistFile = "data/watchlist.json"
+
+	WatchCommand     = "watch"
+	UnwatchCommand   = "unwatch"
+	WatchlistCommand = "watchlist"
+)
+
+// WatchedFlat the flat in the chat watchlist with its last notified state
+type WatchedFlat struct {
+	ChatID    int64  `json:"chat_id"`
+	FlatID    int64  `json:"flat_id"`
+	BlockSlug string `json:"block_slug"`
+
+	Price  int64  `json:"price"`
+	Status string `json:"status"`
+	OnSale bool   `json:"on_sale"`
+}
+
+type WatchlistFileMap map[string][]WatchedFlat
+
+var (
+	Watchlist      = make(map[util.EnvType][]WatchedFlat)
+	watchlistMutex sync.Mutex
+)
+
+func init() {
+	content, err := os.ReadFile(WatchlistFile)
+	if err != nil {
+		log.Printf("unable to read watchlist file: %v", err)
+		return
+	}
+
+	fileMap := make(WatchlistFileMap)
+	err = json.Unmarshal(content, &fileMap)
+	if err != nil {
+		log.Printf("unable to unmarshal watchlist file: %v", err)
+		return
+	}
+
+	for envTypeStr, watched := range fileMap {
+		envType, ok := util.EnvTypeFromString[envTypeStr]
+		if !ok {
+			log.Printf("unknown envtype in watchlist file: %v", envTypeStr)
+			continue
+		}
+		Watchlist[envType] = watched
+	}
+}
+
+func syncWatchlistToFile() error {
+	fileMap := make(WatchlistFileMap, len(Watchlist))
+	for envtype, watched := range Watchlist {
+		fileMap[envtype.String()] = watched
+	}
+	newContent, err := json.Marshal(fileMap)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(WatchlistFile, newContent, 0644)
+}
+
+// GetWatchedBlocks slugs of all blocks with at least one watched flat
+func GetWatchedBlocks() []string {
+	watchlistMutex.Lock()
+	defer watchlistMutex.Unlock()
+
+	blocks := make(map[string]struct{})
+	for _, watched := range Watchlist[util.GetEnvType()] {
+		blocks[watched.BlockSlug] = struct{}{}
+	}
+	return util.SortedKeys(blocks)
+}
+
+// GetChatWatchlist watched flats of the chat in the order of adding
+func GetChatWatchlist(chatID int64) []WatchedFlat {
+	watchlistMutex.Lock()
+	defer watchlistMutex.Unlock()
+
+	var res []WatchedFlat
+	for _, watched := range Watchlist[util.GetEnvType()] {
+		if watched.ChatID == chatID {
+			res = append(res, watched)
+		}
+	}
+	return res
+}
+
+func CheckWatched(chatID int64, flatID int64) bool {
+	for _, watched := range GetChatWatchlist(chatID) {
+		if watched.FlatID == flatID {
+			return true
+		}
+	}
+	return false
+}
+
+func AddWatchedFlat(watched WatchedFlat) error {
+	watchlistMutex.Lock()
+	defer watchlistMutex.Unlock()
+
+	envtype := util.GetEnvType()
+	Watchlist[envtype] = append(Watchlist[envtype], watched)
+
+	err := syncWatchlistToFile()
+	if err != nil {
+		Watchlist[envtype] = Watchlist[envtype][:len(Watchlist[envtype])-1]
+		return err
+	}
+	return nil
+}
+
+func RemoveWatchedFlat(chatID int64, flatID int64) error {
+	watchlistMutex.Lock()
+	defer watchlistMutex.Unlock()
+
+	envtype := util.GetEnvType()
+	oldList := Watchlist[envtype]
+	newList := make([]WatchedFlat, 0, len(oldList))
+	for _, watched := range oldList {
+		if watched.ChatID != chatID || watched.FlatID != flatID {
+			newList = append(newList, watched)
+		}
+	}
+	if len(newList) == len(oldList) {
+		return fmt.Errorf("chat %v was not watching flat %v", chatID, flatID)
+	}
+	Watchlist[envtype] = newList
+
+	err := syncWatchlistToFile()
+	if err != nil {
+		Watchlist[envtype] = oldList
+		return err
+	}
+	return nil
+}
+
+// WatchedChanges notification about the changes of the watched flat since the last known state,
+// empty if nothing changed
+func WatchedChanges(lang i18n.Lang, watched WatchedFlat, flat *flatstorage.Flat, onSale bool) string {
+	var res []string
+	switch {
+	case watched.OnSale && !onSale:
+		res = append(res, i18n.T(lang, "watch.gone"))
+	case !watched.OnSale && onSale:
+		res = append(res, i18n.T(lang, "watch.back"))
+	}
+	if onSale && watched.Price != 0 && flat.Price != watched.Price {
+		change := 100 * float64(flat.Price-watched.Price) / float64(watched.Price)
+		res = append(res, i18n.T(lang, "watch.price",
+			util.ThousandSep(watched.Price, " "), util.ThousandSep(flat.Price, " "), change))
+	}
+	if onSale && len(watched.Status) > 0 && flat.Status != watched.Status {
+		res = append(res, i18n.T(lang, "watch.status", statusName(lang, watched.Status), statusName(lang, flat.Status)))
+	}
+	if len(res) == 0 {
+		return ""
+	}
+	return strings.Join(res, "\n") + "\n" + flat.Format(lang)
+}
+
+// statusName example: "reserve" => "reserved"
+func statusName(lang i18n.Lang, status string) string {
+	key := "status." + status
+	if name := i18n.T(lang, key); name != key {
+		return name
+	}
+	return status
+}
+
+// NotifyWatchers notify about the changes of the watched flats of the block after it was updated,
+// soldOut if the last poll found no flats in the block at all
+func NotifyWatchers(blockSlug string, soldOut bool) {
+	var watchers []WatchedFlat
+	watchlistMutex.Lock()
+	for _, watched := range Watchlist[util.GetEnvType()] {
+		if watched.BlockSlug == blockSlug {
+			watchers = append(watchers, watched)
+		}
+	}
+	watchlistMutex.Unlock()
+	if len(watchers) == 0 {
+		return
+	}
+
+	msgData, err := ReadStoredFlats(blockSlug)
+	if err != nil {
+		log.Printf("failed to read flats of %v for the watchlist: %v", blockSlug, err)
+		return
+	}
+
+	for _, watched := range watchers {
+		flat, ok := msgData.FindFlat(watched.FlatID)
+		if !ok {
+			log.Printf("watched flat %v of %v not found in the stored flats (chatID %v)", watched.FlatID, blockSlug, watched.ChatID)
+			continue
+		}
+		onSale := !soldOut && msgData.OnSale(flat)
+
+		msg := WatchedChanges(ChatLang(watched.ChatID), watched, flat, onSale)
+		if len(msg) == 0 {
+			continue
+		}
+		err = NotifyChat(watched.ChatID, msg, FlatKeyboard(watched.ChatID, flat))
+		if err != nil {
+			log.Printf("failed to notify %v about watched flat %v: %v", watched.ChatID, watched.FlatID, err)
+			continue
+		}
+		updateWatchedState(watched, flat, onSale)
+	}
+}
+
+func updateWatchedState(watched WatchedFlat, flat *flatstorage.Flat, onSale bool) {
+	watchlistMutex.Lock()
+	defer watchlistMutex.Unlock()
+
+	envtype := util.GetEnvType()
+	for i := range Watchlist[envtype] {
+		item := &Watchlist[envtype][i]
+		if item.ChatID != watched.ChatID || item.FlatID != watched.FlatID {
+			continue
+		}
+		item.OnSale = onSale
+		if onSale {
+			item.Price, item.Status = flat.Price, flat.Status
+		}
+	}
+
+	err := syncWatchlistToFile()
+	if err != nil {
+		log.Printf("failed to sync watchlist to file: %v", err)
+	}
+}
+
+func parseFlatID(args string) (int64, bool) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(args), "#"), 10, 64)
+	return id, err == nil && id > 0
+}
+
+// watchFlat returns a short result text for the callback answer
+func watchFlat(chatID int64, args string) string {
+	lang := ChatLang(chatID)
+
+	id, ok := parseFlatID(args)
+	if !ok {
+		return i18n.T(lang, "watch.usage", WatchCommand, WatchCommand)
+	}
+	if CheckWatched(chatID, id) {
+		return i18n.T(lang, "watch.already", id, UnwatchCommand, id)
+	}
+
+	flat, found := FindFlat(id)
+	if !found {
+		return i18n.T(lang, "card.not.found", id)
+	}
+	msgData, err := ReadStoredFlats(flat.BlockSlug)
+	if err != nil {
+		return i18n.T(lang, "watch.failed", id, err)
+	}
+
+	err = AddWatchedFlat(WatchedFlat{
+		ChatID:    chatID,
+		FlatID:    id,
+		BlockSlug: flat.BlockSlug,
+		Price:     flat.Price,
+		Status:    flat.Status,
+		OnSale:    msgData.OnSale(flat),
+	})
+	if err != nil {
+		log.Printf("failed to add flat %v to the watchlist of %v: %v", id, chatID, err)
+		return i18n.T(lang, "watch.failed", id, err)
+	}
+	return i18n.T(lang, "watch.done", id, UnwatchCommand, id, WatchlistCommand)
+}
+
+func unwatchFlat(chatID int64, args string) string {
+	lang := ChatLang(chatID)
+
+	id, ok := parseFlatID(args)
+	if !ok {
+		return i18n.T(lang, "watch.usage", UnwatchCommand, UnwatchCommand)
+	}
+	err := RemoveWatchedFlat(chatID, id)
+	if err != nil {
+		return i18n.T(lang, "unwatch.already", id, WatchCommand, id)
+	}
+	return i18n.T(lang, "unwatch.done", id, WatchCommand, id)
+}
+
+func sendWatch(chatID int64, args string) {
+	err := SendMessage(chatID, watchFlat(chatID, args))
+	if err != nil {
+		log.Printf("failed to send /%v result to %v: %v", WatchCommand, chatID, err)
+	}
+}
+
+func sendUnwatch(chatID int64, args string) {
+	err := SendMessage(chatID, unwatchFlat(chatID, args))
+	if err != nil {
+		log.Printf("failed to send /%v result to %v: %v", UnwatchCommand, chatID, err)
+	}
+}
+
+// sendWatchlist current state of all watched flats from the local files
+func sendWatchlist(chatID int64) {
+	lang := ChatLang(chatID)
+	watchlist := GetChatWatchlist(chatID)
+
+	msg := i18n.T(lang, "watchlist.empty", WatchCommand, WatchCommand)
+	if len(watchlist) > 0 {
+		res := []string{i18n.T(lang, "watchlist.header", len(watchlist))}
+		blocks := make(map[string]*flatstorage.MessageData)
+		for _, watched := range watchlist {
+			if _, ok := blocks[watched.BlockSlug]; !ok {
+				msgData, err := ReadStoredFlats(watched.BlockSlug)
+				if err != nil {
+					log.Printf("failed to read flats of %v for the watchlist: %v", watched.BlockSlug, err)
+				}
+				blocks[watched.BlockSlug] = msgData
+			}
+			msgData := blocks[watched.BlockSlug]
+
+			line := fmt.Sprintf("#%v", watched.FlatID)
+			if flat, ok := msgData.FindFlat(watched.FlatID); ok {
+				line = fmt.Sprintf("%v, %v", flat.BlockName, flat.Format(lang))
+				if !msgData.OnSale(flat) {
+					line += " " + i18n.T(lang, "watchlist.gone")
+				}
+			}
+			res = append(res, fmt.Sprintf("%v /%v_%v", line, UnwatchCommand, watched.FlatID))
+		}
+		msg = strings.Join(res, "\n")
+	}
+
+	err := SendMessage(chatID, msg)
+	if err != nil {
+		log.Printf("failed to send watchlist to %v: %v", chatID, err)
+	}
+}
@@ -0,0 +1,40 @@
+package telegrambot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/georgri/sledopyt_addresses/pkg/flatstorage"
+	"github.com/georgri/sledopyt_addresses/pkg/i18n"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchedChanges(t *testing.T) {
+	watched := WatchedFlat{FlatID: 1, Price: 10_000_000, Status: "free", OnSale: true}
+
+	tests := []struct {
+		flat     flatstorage.Flat
+		onSale   bool
+		watched  WatchedFlat
+		expected []string
+	}{
+		{flatstorage.Flat{ID: 1, Price: 10_000_000, Status: "free"}, true, watched, nil},
+		{flatstorage.Flat{ID: 1, Price: 9_200_000, Status: "free"}, true, watched, []string{"10 000 000R → 9 200 000R (-8.0%)"}},
+		{flatstorage.Flat{ID: 1, Price: 10_000_000, Status: "reserve"}, true, watched, []string{"free → reserved"}},
+		{flatstorage.Flat{ID: 1, Price: 9_000_000, Status: "free"}, false, watched, []string{"no longer on sale"}},
+		{flatstorage.Flat{ID: 1, Price: 10_000_000, Status: "free"}, false, WatchedFlat{OnSale: false}, nil},
+		{flatstorage.Flat{ID: 1, Price: 10_000_000, Status: "free"}, true, WatchedFlat{OnSale: false}, []string{"back on sale"}},
+	}
+
+	for i, test := range tests {
+		res := WatchedChanges(i18n.En, test.watched, &test.flat, test.onSale)
+		if len(test.expected) == 0 {
+			require.Empty(t, res, fmt.Sprintf("failed case %v", i))
+			continue
+		}
+		for _, expected := range test.expected {
+			require.True(t, strings.Contains(res, expected), fmt.Sprintf("failed case %v: %v", i, res))
+		}
+	}
+}
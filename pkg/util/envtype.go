@@ -18,9 +18,9 @@ var EnvTypeFromString = map[string]EnvType{
 
 var RootEnvType string
 
+// init only defines the flag, main parses it: parsing in init breaks "go test" flags
 func init() {
 	flag.StringVar(&RootEnvType, "envtype", "dev", "dev|test|prod")
-	flag.Parse()
 }
 
 func GetEnvType() EnvType {
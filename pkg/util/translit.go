@@ -0,0 +1,50 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+)
+
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "h", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "sch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+}
+
+// ambiguous latin spellings of the same russian sounds, applied after transliteration
+var latinSpellings = strings.NewReplacer(
+	"kh", "h",
+	"iy", "y",
+	"yy", "y",
+	"ij", "y",
+	"j", "y",
+	"x", "ks",
+	"w", "v",
+)
+
+// Transliterate lower-cased russian to latin, example: "Второй Нагатинский" => "vtoroy nagatinskiy"
+func Transliterate(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if latin, ok := cyrillicToLatin[r]; ok {
+			b.WriteString(latin)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// SearchKey letters and digits only, transliterated and with unified spellings,
+// so that "Нагатинский", "nagatinsky" and "Nagatinskij" give the same key "nagatinsky"
+func SearchKey(s string) string {
+	var b strings.Builder
+	for _, r := range Transliterate(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return latinSpellings.Replace(b.String())
+}
@@ -2,6 +2,7 @@ package util
 
 import (
 	"fmt"
+	"math"
 	"sort"
 	"strings"
 
@@ -39,7 +40,7 @@ func ReverseInPlace[T any](arr []T) {
 	}
 }
 
-func FilterSliceInPlace[T comparable](arr []T, check func(int) bool) []T {
+func FilterSliceInPlace[T any](arr []T, check func(int) bool) []T {
 	if len(arr) == 0 {
 		return arr
 	}
@@ -55,7 +56,7 @@ func FilterSliceInPlace[T comparable](arr []T, check func(int) bool) []T {
 	return arr[:size]
 }
 
-func FilterUnique[T, K comparable](arr []T, key func(int) K) []T {
+func FilterUnique[T any, K comparable](arr []T, key func(int) K) []T {
 	if len(arr) == 0 {
 		return arr
 	}
@@ -97,3 +98,41 @@ func SortedKeys[K constraints.Ordered, V any](m map[K]V) []K {
 	})
 	return keys
 }
+
+// Median returns median of any numeric slice without modifying it
+func Median[T constraints.Integer | constraints.Float](arr []T) float64 {
+	if len(arr) == 0 {
+		return 0
+	}
+	sorted := make([]T, len(arr))
+	copy(sorted, arr)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	size := len(sorted)
+	if size%2 == 1 {
+		return float64(sorted[size/2])
+	}
+	return (float64(sorted[size/2-1]) + float64(sorted[size/2])) / 2
+}
+
+func Min[T constraints.Ordered](a, b T) T {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+const earthRadiusKm = 6371.0
+
+// Haversine great-circle distance in km between two points given in degrees
+func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 {
+		return deg * math.Pi / 180
+	}
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
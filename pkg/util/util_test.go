@@ -66,3 +66,73 @@ func TestReverseInPlace(t *testing.T) {
 		require.Equal(t, test.expected, test.arr, fmt.Sprintf("failed case %v", i))
 	}
 }
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		arr      []float64
+		expected float64
+	}{
+		{
+			nil, 0,
+		},
+		{
+			[]float64{3}, 3,
+		},
+		{
+			[]float64{3, 1}, 2,
+		},
+		{
+			[]float64{5, 1, 3}, 3,
+		},
+		{
+			[]float64{-2.5, 10, 1, 0}, 0.5,
+		},
+	}
+
+	for i, test := range tests {
+		res := Median(test.arr)
+		require.Equal(t, test.expected, res, fmt.Sprintf("failed case %v", i))
+	}
+}
+
+func TestHaversine(t *testing.T) {
+	tests := []struct {
+		lat1, lon1, lat2, lon2 float64
+		expected               float64
+	}{
+		{
+			55.7558, 37.6173, 55.7558, 37.6173, 0,
+		},
+		{
+			// Moscow => Saint Petersburg
+			55.7558, 37.6173, 59.9343, 30.3351, 633.0,
+		},
+		{
+			// one degree of latitude
+			0, 0, 1, 0, 111.19,
+		},
+	}
+
+	for i, test := range tests {
+		res := Haversine(test.lat1, test.lon1, test.lat2, test.lon2)
+		require.InDelta(t, test.expected, res, 0.5, fmt.Sprintf("failed case %v", i))
+	}
+}
+
+func TestSearchKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{a: "Второй Нагатинский", b: "vtoroy nagatinskiy"},
+		{a: "Нагатинский", b: "nagatinsky"},
+		{a: "Нагатинский", b: "Nagatinskij"},
+		{a: "Ёлки Хаус", b: "elki haus"},
+		{a: "Яуза парк", b: "yauza-park"},
+		{a: "2ngt", b: "2NGT"},
+	}
+
+	for i, test := range tests {
+		require.Equal(t, SearchKey(test.a), SearchKey(test.b), fmt.Sprintf("failed case %v", i))
+	}
+	require.Equal(t, "vtoroy nagatinskiy", Transliterate("Второй Нагатинский"))
+}